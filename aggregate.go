@@ -12,19 +12,26 @@ import (
 // This can be built by calling the [NewAggregateOptions] function or via [AggregateOptionsBuilder.Options]
 // using the Builder API.
 type AggregateOptions struct {
-	Verbatim bool             // Set to true if stemming should not be used
-	Load     []AggregateLoad  // Values for the LOAD subcommand; use the [LoadAll] variable to represent "LOAD *"
-	Timeout  time.Duration    // Sets the query timeout. If zero, no TIMEOUT subcommmand is used
-	Cursor   *AggregateCursor // nil means no cursor
-	Params   map[string]interface{}
-	Dialect  uint8
-	Steps    []AggregateStep // The steps to be executed in order
+	Verbatim  bool             // Set to true if stemming should not be used
+	Load      []AggregateLoad  // Values for the LOAD subcommand; use the [LoadAll] variable to represent "LOAD *"
+	Timeout   time.Duration    // Sets the query timeout. If zero, no TIMEOUT subcommmand is used
+	Cursor    *AggregateCursor // nil means no cursor
+	Scorer    string           // Scoring function used for the __score property, the server default if empty
+	AddScores bool             // Make the score of each document available as the __score property
+	Params    map[string]interface{}
+	Dialect   uint8
+	Steps     []AggregateStep // The steps to be executed in order
+
+	err error // set by the builder if an expression is invalid
 }
 
-// AggregateGroupBy represents a single GROUPBY statement in a
+// AggregateGroupBy represents a single GROUPBY statement in a pipeline. Properties are sent
+// with an @ prefix, which is added if it is missing, as for reducer arguments and sort keys.
 type AggregateGroupBy struct {
 	Properties []string
 	Reducers   []AggregateReducer
+
+	err error // set by the builder if a reducer is invalid
 }
 
 type AggregateReducer struct {
@@ -55,8 +62,15 @@ type AggregateSort struct {
 	Max  int64
 }
 type AggregateSortKey struct {
-	Name  string
-	Order string
+	Name       string
+	Order      string
+	Expression string // If set, the key is computed with APPLY Expression AS Name before sorting
+}
+
+// AggregateLoadStep loads document attributes part way through the pipeline, for example to
+// read attributes only needed by the results left after a FILTER.
+type AggregateLoadStep struct {
+	Load []AggregateLoad
 }
 
 type AggregateStep interface {
@@ -85,6 +99,10 @@ func (a *AggregateOptions) serialize() []interface{} {
 	if a.Verbatim {
 		args = append(args, "verbatim")
 	}
+	if a.AddScores {
+		args = append(args, "addscores")
+	}
+	args = internal.AppendStringArg(args, "scorer", a.Scorer)
 	if a.Timeout != 0 {
 		args = internal.AppendStringArg(args, "timeout", fmt.Sprintf("%d", a.Timeout.Milliseconds()))
 	}
@@ -98,12 +116,7 @@ func (a *AggregateOptions) serialize() []interface{} {
 		args = append(args, a.Cursor.serialize()...)
 	}
 
-	if len(a.Params) != 0 {
-		args = append(args, "params", len(a.Params))
-		for n, v := range a.Params {
-			args = append(args, n, v)
-		}
-	}
+	args = append(args, serializeParams(a.Params)...)
 
 	if a.Dialect != defaultDialect {
 		args = append(args, "dialect", a.Dialect)
@@ -114,20 +127,28 @@ func (a *AggregateOptions) serialize() []interface{} {
 }
 
 func (f AggregateFilter) serializeStep() []interface{} {
-	return []interface{}{f}
+	return []interface{}{"filter", string(f)}
 }
 
 func (a *AggregateOptions) serializeLoad() []interface{} {
+	return serializeLoads(a.Load)
+}
+
+func (l *AggregateLoadStep) serializeStep() []interface{} {
+	return serializeLoads(l.Load)
+}
+
+func serializeLoads(load []AggregateLoad) []interface{} {
 
-	if len(a.Load) == 0 {
+	if len(load) == 0 {
 		return []interface{}{}
 	}
 
-	if len(a.Load) == 1 && a.Load[0].Name == "*" {
+	if len(load) == 1 && load[0].Name == "*" {
 		return []interface{}{"load", "*"}
 	}
 	loads := []interface{}{"load", 0}
-	for _, l := range a.Load {
+	for _, l := range load {
 		loads = append(loads, l.serialize()...)
 	}
 	loads[1] = len(loads) - 2
@@ -153,16 +174,18 @@ func (l AggregateLoad) serialize() []interface{} {
 
 func (s AggregateSort) serializeStep() []interface{} {
 
+	// keys computed from expressions are applied first
+	applied := []interface{}{}
 	keys := []interface{}{"SORTBY", 0}
-	for _, k := range s.Keys {
-		namePrefix := ""
-		if !strings.HasPrefix(k.Name, "@") {
-			namePrefix = "@"
+	for n, k := range s.Keys {
+		name := k.property(n)
+		if k.Expression != "" {
+			applied = append(applied, "apply", k.Expression, "as", name)
 		}
 		if k.Order == "" {
-			keys = append(keys, namePrefix+k.Name, "ASC")
+			keys = append(keys, "@"+name, "ASC")
 		} else {
-			keys = append(keys, namePrefix+k.Name, k.Order)
+			keys = append(keys, "@"+name, k.Order)
 		}
 
 	}
@@ -173,7 +196,16 @@ func (s AggregateSort) serializeStep() []interface{} {
 		keys = append(keys, "MAX", s.Max)
 	}
 
-	return keys
+	return append(applied, keys...)
+}
+
+// property returns the name of the property sorted on by the nth key.
+func (k AggregateSortKey) property(n int) string {
+	name := strings.TrimPrefix(k.Name, "@")
+	if name == "" && k.Expression != "" {
+		name = fmt.Sprintf("__sortkey%d", n)
+	}
+	return name
 }
 
 func (c *AggregateCursor) serialize() []interface{} {
@@ -205,7 +237,7 @@ func (r *AggregateReducer) serialize() []interface{} {
 func (g *AggregateGroupBy) serializeStep() []interface{} {
 	args := []interface{}{"GROUPBY", len(g.Properties)}
 	for _, arg := range g.Properties {
-		args = append(args, arg)
+		args = append(args, "@"+strings.TrimPrefix(arg, "@"))
 	}
 	for _, r := range g.Reducers {
 		args = append(args, r.serialize()...)
@@ -1,9 +1,16 @@
 package grsearch_test
 
 import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+
 	grsearch "github.com/goslogan/grsearch"
+	"github.com/goslogan/grsearch/expr"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
 )
 
 var _ = Describe("Aggregate", Label("ft.aggregate"), func() {
@@ -67,4 +74,202 @@ var _ = Describe("Aggregate", Label("ft.aggregate"), func() {
 
 	})
 
+	It("sends FILTER steps with their keyword", func() {
+		cmd := offline.FTAggregate(ctx, "hcustomers", "*", grsearch.NewAggregateBuilder().
+			Filter("@balance > 0").
+			Options())
+		Expect(cmd.Err()).To(MatchError(errOffline))
+		Expect(cmd.Args()).To(Equal([]interface{}{"FT.AGGREGATE", "hcustomers", "*", "filter", "@balance > 0"}))
+	})
+
+	It("can filter results", func() {
+		opts := grsearch.NewAggregateBuilder().
+			Load("balance", "").
+			Filter("@balance < 0")
+		cmd := client.FTAggregate(ctx, "hcustomers", "*", opts.Options())
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		Expect(cmd.Val()).NotTo(BeEmpty())
+		for _, row := range cmd.Val() {
+			Expect(fmt.Sprint(row["balance"])).To(HavePrefix("-"))
+		}
+	})
+
+	It("can scan rows into structs", func() {
+		type ownerTotals struct {
+			Owner     string   `redis:"owner"`
+			Customers int      `redis:"customers"`
+			Balance   float64  `redis:"total_balance"`
+			Countries []string `redis:"countries"`
+		}
+		opts := grsearch.NewAggregateBuilder().
+			GroupBy(grsearch.NewGroupByBuilder().
+				Property("@owner").
+				Reduce(grsearch.ReduceCount("customers")).
+				Reduce(grsearch.ReduceSum("@balance", "total_balance")).
+				Reduce(grsearch.ReduceToList("@country", "countries")).
+				GroupBy())
+		cmd := client.FTAggregate(ctx, "hcustomers", "*", opts.Options())
+		Expect(cmd.Err()).ToNot(HaveOccurred())
+
+		rows := []ownerTotals{}
+		Expect(cmd.Scan(&rows)).To(Succeed())
+		Expect(rows).To(HaveLen(3))
+		customers := 0
+		for _, row := range rows {
+			Expect(row.Owner).NotTo(BeEmpty())
+			Expect(row.Countries).NotTo(BeEmpty())
+			customers += row.Customers
+		}
+		Expect(customers).To(Equal(25))
+	})
+
+	It("reports values which can't be scanned", func() {
+		opts := grsearch.NewAggregateBuilder().
+			GroupBy(grsearch.NewGroupByBuilder().
+				Property("@owner").
+				GroupBy())
+		cmd := client.FTAggregate(ctx, "hcustomers", "*", opts.Options())
+		Expect(cmd.Err()).ToNot(HaveOccurred())
+
+		rows := []struct {
+			Owner int
+		}{}
+		Expect(cmd.Scan(&rows)).NotTo(Succeed())
+	})
+
+	It("can reduce all the results with GROUPBY 0", func() {
+		opts := grsearch.NewAggregateBuilder().
+			GroupAll(grsearch.ReduceCount("customers"), grsearch.ReduceMax("balance", "richest"))
+		cmd := client.FTAggregate(ctx, "hcustomers", "*", opts.Options())
+		Expect(cmd.Err()).ToNot(HaveOccurred())
+		Expect(cmd.Args()[3:5]).To(Equal([]interface{}{"GROUPBY", 0}))
+		Expect(cmd.Val()).To(HaveLen(1))
+		Expect(fmt.Sprint(cmd.Val()[0]["customers"])).To(Equal("25"))
+	})
+
+	It("can load attributes part way through the pipeline", func() {
+		opts := grsearch.NewAggregateBuilder().
+			Load("balance", "").
+			FilterExpr(expr.Property("balance").Lt(expr.Int(0))).
+			LoadStep("owner", "").
+			LoadStep("customer", "name").
+			Limit(0, 100)
+		cmd := client.FTAggregate(ctx, "hcustomers", "*", opts.Options())
+		Expect(cmd.Err()).ToNot(HaveOccurred())
+		Expect(cmd.Args()[3:12]).To(Equal([]interface{}{"load", 1, "balance", "filter", "@balance < 0", "load", 4, "owner", "customer"}))
+		Expect(cmd.Val()).NotTo(BeEmpty())
+		for _, row := range cmd.Val() {
+			Expect(row).To(HaveKey("owner"))
+			Expect(row).To(HaveKey("name"))
+		}
+	})
+
+	It("can sort by an expression", func() {
+		opts := grsearch.NewAggregateBuilder().
+			Load("balance", "").
+			SortByExpr("magnitude", expr.Abs(expr.Property("balance")), grsearch.SortDesc, 5)
+		cmd := client.FTAggregate(ctx, "hcustomers", "*", opts.Options())
+		Expect(cmd.Err()).ToNot(HaveOccurred())
+		Expect(cmd.Args()[6:]).To(Equal([]interface{}{"apply", "abs(@balance)", "as", "magnitude", "SORTBY", 2, "@magnitude", "DESC", "MAX", int64(5)}))
+		Expect(cmd.Val()).To(HaveLen(5))
+		previous := math.Inf(1)
+		for _, row := range cmd.Val() {
+			magnitude, err := strconv.ParseFloat(fmt.Sprint(row["magnitude"]), 64)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(magnitude).To(BeNumerically("<=", previous))
+			previous = magnitude
+		}
+	})
+
+	It("can pass parameters", func() {
+		opts := grsearch.NewAggregateBuilder().
+			Param("min", 0).
+			Param("owner", "lara.croft").
+			GroupAll(grsearch.ReduceCount("customers")).
+			Dialect(2)
+		cmd := client.FTAggregate(ctx, "hcustomers", "@balance:[$min +inf] @owner:{$owner}", opts.Options())
+		Expect(cmd.Err()).ToNot(HaveOccurred())
+		Expect(cmd.Args()[10:]).To(Equal([]interface{}{"params", 4, "min", 0, "owner", "lara.croft"}))
+		Expect(fmt.Sprint(cmd.Val()[0]["customers"])).To(Equal(fmt.Sprint(client.FTSearchHash(ctx, "hcustomers", `@balance:[0 +inf] @owner:{lara\.croft}`, nil).TotalResults())))
+	})
+
+	It("can add scores", func() {
+		caps, err := client.Capabilities(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		opts := grsearch.NewAggregateBuilder().
+			AddScores().
+			Scorer("BM25").
+			SortBy([]grsearch.AggregateSortKey{{Name: "__score", Order: grsearch.SortDesc}})
+		cmd := client.FTAggregate(ctx, "hcustomers", "@customer:davon", opts.Options())
+		Expect(cmd.Args()[3:6]).To(Equal([]interface{}{"addscores", "scorer", "BM25"}))
+		if caps.Supports(grsearch.FeatureAddScores) {
+			Expect(cmd.Err()).NotTo(HaveOccurred())
+			Expect(cmd.Val()).NotTo(BeEmpty())
+			Expect(cmd.Val()[0]).To(HaveKey("__score"))
+		} else {
+			Expect(cmd.Err()).To(BeAssignableToTypeOf(&grsearch.UnsupportedFeatureError{}))
+		}
+	})
+
+})
+
+// replyHook answers FT.AGGREGATE with a fixed reply instead of sending it, and fails every
+// other command with errOffline.
+type replyHook struct {
+	offlineHook
+	reply interface{}
+}
+
+func (h replyHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if aggregate, ok := cmd.(*grsearch.AggregateCmd); ok {
+			aggregate.Cmd.SetVal(h.reply)
+			return nil
+		}
+		cmd.SetErr(errOffline)
+		return errOffline
+	}
+}
+
+var _ = Describe("RESP3 aggregate replies", Label("ft.aggregate"), func() {
+
+	It("keeps warnings and errors apart", func() {
+		replying := grsearch.NewClient(&redis.Options{})
+		DeferCleanup(replying.Close)
+		replying.AddHook(replyHook{reply: map[interface{}]interface{}{
+			"format":        "STRING",
+			"total_results": int64(1),
+			"warning":       []interface{}{"Timeout limit was reached"},
+			"error":         []interface{}{"Partial results"},
+			"results": []interface{}{
+				map[interface{}]interface{}{
+					"extra_attributes": map[interface{}]interface{}{"owner": "lara.croft"},
+				},
+			},
+		}})
+
+		cmd := replying.FTAggregate(ctx, "hcustomers", "*", grsearch.NewAggregateOptions())
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		Expect(cmd.Val()).To(Equal([]map[string]interface{}{{"owner": "lara.croft"}}))
+		Expect(cmd.RESP3Data().Format).To(Equal("STRING"))
+		Expect(cmd.RESP3Data().Warnings).To(Equal([]interface{}{"Timeout limit was reached"}))
+		Expect(cmd.RESP3Data().Errors).To(Equal([]interface{}{"Partial results"}))
+	})
+
+	It("accepts replies with only warnings", func() {
+		replying := grsearch.NewClient(&redis.Options{})
+		DeferCleanup(replying.Close)
+		replying.AddHook(replyHook{reply: map[interface{}]interface{}{
+			"format":  "STRING",
+			"warning": []interface{}{"Timeout limit was reached"},
+			"results": []interface{}{},
+		}})
+
+		cmd := replying.FTAggregate(ctx, "hcustomers", "*", grsearch.NewAggregateOptions())
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		Expect(cmd.Val()).To(BeEmpty())
+		Expect(cmd.RESP3Data().Warnings).To(Equal([]interface{}{"Timeout limit was reached"}))
+		Expect(cmd.RESP3Data().Errors).To(BeNil())
+	})
 })
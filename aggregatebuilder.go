@@ -1,6 +1,10 @@
 package grsearch
 
-import "time"
+import (
+	"time"
+
+	"github.com/goslogan/grsearch/expr"
+)
 
 type AggregateBuilder struct {
 	opts AggregateOptions
@@ -73,6 +77,39 @@ func (a *AggregateBuilder) Apply(expression, name string) *AggregateBuilder {
 	return a
 }
 
+// AddScores makes the score of each document available to the pipeline as the __score property.
+func (a *AggregateBuilder) AddScores() *AggregateBuilder {
+	a.opts.AddScores = true
+	return a
+}
+
+// Scorer sets the scoring function used for __score
+func (a *AggregateBuilder) Scorer(scorer string) *AggregateBuilder {
+	a.opts.Scorer = scorer
+	return a
+}
+
+// FilterExpr adds a result filter built with the [expr] package. If the expression is
+// invalid the aggregate fails with its error without being sent.
+func (a *AggregateBuilder) FilterExpr(e expr.Expr) *AggregateBuilder {
+	a.setErr(e.Err())
+	return a.Filter(e.String())
+}
+
+// ApplyExpr appends a transform built with the [expr] package to the apply list. If the
+// expression is invalid the aggregate fails with its error without being sent.
+func (a *AggregateBuilder) ApplyExpr(e expr.Expr, name string) *AggregateBuilder {
+	a.setErr(e.Err())
+	return a.Apply(e.String(), name)
+}
+
+// setErr records the first error found building the aggregate.
+func (a *AggregateBuilder) setErr(err error) {
+	if a.opts.err == nil {
+		a.opts.err = err
+	}
+}
+
 // WithCursor creates a cursor for the aggregate to scan parts of the result
 func (a *AggregateBuilder) Cursor(count uint64, timeout time.Duration) *AggregateBuilder {
 	a.opts.Cursor = &AggregateCursor{
@@ -90,6 +127,20 @@ func (a *AggregateBuilder) Load(name string, as string) *AggregateBuilder {
 	return a
 }
 
+// LoadStep loads a field at this point in the pipeline rather than before the first step. Fields
+// loaded by consecutive calls are loaded together. The alias can be the empty string.
+func (a *AggregateBuilder) LoadStep(name string, as string) *AggregateBuilder {
+	l := AggregateLoad{Name: name, As: as}
+	if n := len(a.opts.Steps); n > 0 {
+		if step, ok := a.opts.Steps[n-1].(*AggregateLoadStep); ok {
+			step.Load = append(step.Load, l)
+			return a
+		}
+	}
+	a.opts.Steps = append(a.opts.Steps, &AggregateLoadStep{Load: []AggregateLoad{l}})
+	return a
+}
+
 // LoadAll sets the load list for this aggregate to "LOAD *".
 func (a *AggregateBuilder) LoadAll() *AggregateBuilder {
 	a.opts.Load = []AggregateLoad{LoadAll}
@@ -116,8 +167,27 @@ func (a *AggregateBuilder) SortByMax(keys []AggregateSortKey, max int64) *Aggreg
 	return a
 }
 
-// GroupBy adds a new group by statement (constructed with a GroupByBuilder)
+// SortByExpr adds a sorting step ordering the results by the value of an expression, which is
+// computed with an APPLY step and kept as the property name. If the expression is invalid the
+// aggregate fails with its error without being sent. A max of zero uses the server default.
+func (a *AggregateBuilder) SortByExpr(name string, e expr.Expr, order string, max int64) *AggregateBuilder {
+	a.setErr(e.Err())
+	return a.SortByMax([]AggregateSortKey{{Name: name, Order: order, Expression: e.String()}}, max)
+}
+
+// GroupAll adds a GROUPBY 0 statement, reducing all the results to a single row.
+func (a *AggregateBuilder) GroupAll(reducers ...AggregateReducer) *AggregateBuilder {
+	g := NewGroupByBuilder()
+	for _, r := range reducers {
+		g.Reduce(r)
+	}
+	return a.GroupBy(g.GroupBy())
+}
+
+// GroupBy adds a new group by statement (constructed with a GroupByBuilder). If one of its
+// reducers is invalid the aggregate fails with a [ReducerError] without being sent.
 func (a *AggregateBuilder) GroupBy(g AggregateGroupBy) *AggregateBuilder {
+	a.setErr(g.err)
 	a.opts.Steps = append(a.opts.Steps, &g)
 	return a
 }
@@ -139,24 +209,35 @@ func (g *GroupByBuilder) GroupBy() AggregateGroupBy {
 }
 
 // Property appends a property to the properties list, not adding it if
-// it already exists
+// it already exists. @ is added to the name when it is sent if it is missing.
 func (g *GroupByBuilder) Property(name string) *GroupByBuilder {
 	g.group.Properties = append(g.group.Properties, name)
 	return g
 }
 
-// Properties sets all the property for a group by at one time.
+// Properties sets all the property for a group by at one time. @ is added to names which
+// don't have it when they are sent.
 func (g *GroupByBuilder) Properties(properties []string) *GroupByBuilder {
 	g.group.Properties = properties
 	return g
 }
 
-// Reduce adds a reducer function to the group by.
+// Reduce adds a reducer function to the group by. The reducer is checked against the reducers
+// the server supports and @ is added to property names that don't have it.
 func (g *GroupByBuilder) Reduce(r AggregateReducer) *GroupByBuilder {
+	r, err := r.normalize()
+	if err != nil && g.group.err == nil {
+		g.group.err = err
+	}
 	g.group.Reducers = append(g.group.Reducers, r)
 	return g
 }
 
+// Err returns the error from the first invalid reducer added with Reduce, if any.
+func (g *GroupByBuilder) Err() error {
+	return g.group.err
+}
+
 /*******************************************************************************
 *   Reducer shortcuts
 *******************************************************************************/
@@ -196,14 +277,15 @@ func ReduceAvg(property, as string) AggregateReducer {
 	return AggregateReducer{Name: "avg", Args: []interface{}{property}, As: as}
 }
 
-// ReduceAvg returns a Reducer configured to return the mean value of the given property.
+// ReduceStdDev returns a Reducer configured to return the standard deviation of the values of the given property.
 func ReduceStdDev(property, as string) AggregateReducer {
 	return AggregateReducer{Name: "stddev", Args: []interface{}{property}, As: as}
 }
 
-// ReduceAvg returns a Reducer configured to return the mean value of the given property.
+// ReduceQuantile returns a Reducer configured to return the value of the given property at a quantile
+// (from 0 to 1) of the group, 0.5 being the median.
 func ReduceQuantile(property string, quantile float64, as string) AggregateReducer {
-	return AggregateReducer{Name: "stddev", Args: []interface{}{property, quantile}, As: as}
+	return AggregateReducer{Name: "quantile", Args: []interface{}{property, quantile}, As: as}
 }
 
 // ReduceToList returns a reducer configured to merge all distinct values of the property into an array
@@ -212,16 +294,15 @@ func ReduceToList(property, as string) AggregateReducer {
 }
 
 // ReduceFirstValue returns a Reducer configured to get the first value of a given property with optional
-// sorting.
+// sorting by the property itself.
 func ReduceFirstValue(property, order, as string) AggregateReducer {
-	reduceFn := AggregateReducer{Name: "first_value", Args: []interface{}{property}, As: as}
 	if order != SortNone {
-		reduceFn.Args = append(reduceFn.Args, order)
+		return ReduceFirstValueBy(property, property, order, as)
 	}
-	return reduceFn
+	return AggregateReducer{Name: "first_value", Args: []interface{}{property}, As: as}
 }
 
-// ReduceFirstValue returns a Reducer configured to get the first value of a given property with optional
+// ReduceFirstValueBy returns a Reducer configured to get the first value of a given property with optional
 // sorting using another property as the comparator
 func ReduceFirstValueBy(property, comparator, order, as string) AggregateReducer {
 	reduceFn := AggregateReducer{Name: "first_value", Args: []interface{}{property, "BY", comparator}, As: as}
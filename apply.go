@@ -0,0 +1,267 @@
+package grsearch
+
+// applying definitions - Apply compares index definitions with the indexes on the server. Missing
+// indexes are created. FT.ALTER can only add attributes so an existing index is altered when the
+// only difference is new attributes; anything else is reported as drift and left alone as the only
+// fix is to drop and recreate the index. FT.INFO reports defaults (an empty prefix, weight 1, a
+// comma separator and so on) which are normalised before comparing.
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ApplyAction describes what [Client.Apply] did, or would do, to an index.
+type ApplyAction string
+
+const (
+	ApplyCreated   ApplyAction = "created"   // the index did not exist and was created
+	ApplyAltered   ApplyAction = "altered"   // attributes were added with FT.ALTER
+	ApplyUnchanged ApplyAction = "unchanged" // the index matches its definition
+	ApplyDrift     ApplyAction = "drift"     // the index differs in a way that FT.ALTER can't fix
+)
+
+// ApplyOptions configures [Client.Apply].
+type ApplyOptions struct {
+	DryRun          bool // Report what would be done without changing anything
+	SkipInitialScan bool // Don't index existing documents for attributes added with FT.ALTER
+}
+
+// IndexChange reports the outcome of applying a single definition.
+type IndexChange struct {
+	Index       string
+	File        string
+	Action      ApplyAction
+	Added       []SchemaAttribute // Attributes added (or to be added) with FT.ALTER
+	Differences []string          // Why the index has drifted from its definition
+}
+
+// Apply reads the index definitions in a directory (see [ReadIndexDefinitions]) and
+// applies them with [Client.ApplyDefinitions].
+func (c *Client) Apply(ctx context.Context, dir string, options *ApplyOptions) ([]IndexChange, error) {
+	definitions, err := ReadIndexDefinitions(dir)
+	if err != nil {
+		return nil, err
+	}
+	return c.ApplyDefinitions(ctx, definitions, options)
+}
+
+// ApplyDefinitions creates the indexes which don't exist, adds new attributes to those that
+// do and reports any other differences. The changes made before an error are returned with it.
+func (c *Client) ApplyDefinitions(ctx context.Context, definitions []*IndexDefinition, options *ApplyOptions) ([]IndexChange, error) {
+	if options == nil {
+		options = &ApplyOptions{}
+	}
+
+	indexes, err := c.FTList(ctx).Result()
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(indexes))
+	for _, index := range indexes {
+		existing[index] = true
+	}
+
+	changes := make([]IndexChange, 0, len(definitions))
+	for _, def := range definitions {
+		change := IndexChange{Index: def.Name, File: def.File}
+
+		if !existing[def.Name] {
+			change.Action = ApplyCreated
+			if !options.DryRun {
+				if err := c.FTCreate(ctx, def.Name, def.Options).Err(); err != nil {
+					return changes, fmt.Errorf("grsearch: unable to create %s: %w", def.Name, err)
+				}
+			}
+			changes = append(changes, change)
+			continue
+		}
+
+		info, err := c.FTInfo(ctx, def.Name).Result()
+		if err != nil {
+			return changes, err
+		}
+
+		change.Differences, change.Added = diffIndexOptions(def.Options, info.Index)
+		switch {
+		case len(change.Differences) > 0:
+			change.Action = ApplyDrift
+		case len(change.Added) > 0:
+			change.Action = ApplyAltered
+			if !options.DryRun {
+				if err := c.FTAlter(ctx, def.Name, options.SkipInitialScan, change.Added...).Err(); err != nil {
+					return changes, fmt.Errorf("grsearch: unable to alter %s: %w", def.Name, err)
+				}
+			}
+		default:
+			change.Action = ApplyUnchanged
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// diffIndexOptions compares a definition with the options read from FT.INFO, returning the
+// differences that can't be applied and the attributes that only exist in the definition.
+func diffIndexOptions(want, have *IndexOptions) ([]string, []SchemaAttribute) {
+	differences := []string{}
+
+	if !strings.EqualFold(want.On, have.On) {
+		differences = append(differences, fmt.Sprintf("on: %s, index has %s", strings.ToUpper(want.On), strings.ToUpper(have.On)))
+	}
+	if wantPrefix, havePrefix := normalizePrefixes(want.Prefix), normalizePrefixes(have.Prefix); !reflect.DeepEqual(wantPrefix, havePrefix) {
+		differences = append(differences, fmt.Sprintf("prefix: %q, index has %q", wantPrefix, havePrefix))
+	}
+	if want.Score != have.Score {
+		differences = append(differences, fmt.Sprintf("score: %g, index has %g", want.Score, have.Score))
+	}
+
+	values := []struct {
+		name       string
+		want, have string
+	}{
+		{"filter", want.Filter, have.Filter},
+		{"language", strings.ToLower(want.Language), strings.ToLower(have.Language)},
+		{"language_field", want.LanguageField, have.LanguageField},
+		{"score_field", want.ScoreField, have.ScoreField},
+		{"payload_field", want.PayloadField, have.PayloadField},
+	}
+	for _, option := range values {
+		if option.want != option.have {
+			differences = append(differences, fmt.Sprintf("%s: %q, index has %q", option.name, option.want, option.have))
+		}
+	}
+
+	flags := []struct {
+		name       string
+		want, have bool
+	}{
+		{"max_text_fields", want.MaxTextFields, have.MaxTextFields},
+		{"no_offsets", want.NoOffsets, have.NoOffsets},
+		{"no_highlight", want.NoHighlight || want.NoOffsets, have.NoHighlight || have.NoOffsets},
+		{"no_fields", want.NoFields, have.NoFields},
+		{"no_freqs", want.NoFreqs, have.NoFreqs},
+	}
+	for _, option := range flags {
+		if option.want != option.have {
+			differences = append(differences, fmt.Sprintf("%s: %t, index has %t", option.name, option.want, option.have))
+		}
+	}
+
+	if want.UseStopWords != have.UseStopWords || (want.UseStopWords && !reflect.DeepEqual(normalizePrefixes(want.StopWords), normalizePrefixes(have.StopWords))) {
+		differences = append(differences, fmt.Sprintf("stopwords: %q, index has %q", stopWords(want), stopWords(have)))
+	}
+
+	existing := make(map[string]SchemaAttribute, len(have.Schema))
+	for _, a := range have.Schema {
+		existing[attributeKey(a)] = normalizeAttribute(a)
+	}
+
+	added := []SchemaAttribute{}
+	for _, a := range want.Schema {
+		key := attributeKey(a)
+		current, ok := existing[key]
+		if !ok {
+			added = append(added, a)
+			continue
+		}
+		delete(existing, key)
+		if wanted := normalizeAttribute(a); !reflect.DeepEqual(wanted, current) {
+			differences = append(differences, fmt.Sprintf("attribute %s: %s, index has %s", key, describeAttribute(wanted), describeAttribute(current)))
+		}
+	}
+
+	for _, a := range have.Schema {
+		if key := attributeKey(a); existing[key] != nil {
+			differences = append(differences, fmt.Sprintf("attribute %s: not in the definition", key))
+		}
+	}
+
+	return differences, added
+}
+
+// stopWords describes the stopwords of an index, nil meaning the server defaults.
+func stopWords(options *IndexOptions) []string {
+	if !options.UseStopWords {
+		return nil
+	}
+	return normalizePrefixes(options.StopWords)
+}
+
+// attributeKey returns the name used to refer to an attribute in queries.
+func attributeKey(a SchemaAttribute) string {
+	name, alias, _ := attributeIdentity(a)
+	if alias != "" {
+		return alias
+	}
+	return name
+}
+
+// describeAttribute returns the FT.CREATE form of an attribute.
+func describeAttribute(a SchemaAttribute) string {
+	return strings.TrimSpace(fmt.Sprintln(a.serialize()...))
+}
+
+// normalizePrefixes removes the empty prefix FT.INFO reports for indexes without one. It is
+// also used to compare stopword lists.
+func normalizePrefixes(prefixes []string) []string {
+	normalized := []string{}
+	for _, p := range prefixes {
+		if p != "" {
+			normalized = append(normalized, p)
+		}
+	}
+	return normalized
+}
+
+// normalizeAttribute returns a copy of an attribute with defaults filled in and
+// the alias removed if it is the same as the name.
+func normalizeAttribute(a SchemaAttribute) SchemaAttribute {
+	switch v := a.(type) {
+	case *TagAttribute:
+		c := *v
+		c.Alias = normalizeAlias(c.Name, c.Alias)
+		if c.Separator == "" {
+			c.Separator = ","
+		}
+		return &c
+	case *TextAttribute:
+		c := *v
+		c.Alias = normalizeAlias(c.Name, c.Alias)
+		if c.Weight == 0 {
+			c.Weight = 1
+		}
+		return &c
+	case *NumericAttribute:
+		c := *v
+		c.Alias = normalizeAlias(c.Name, c.Alias)
+		return &c
+	case *GeoAttribute:
+		c := *v
+		c.Alias = normalizeAlias(c.Name, c.Alias)
+		return &c
+	case *GeometryAttribute:
+		c := *v
+		c.Alias = normalizeAlias(c.Name, c.Alias)
+		return &c
+	case *VectorAttribute:
+		c := *v
+		c.Alias = normalizeAlias(c.Name, c.Alias)
+		c.Algorithm = strings.ToUpper(c.Algorithm)
+		c.Type = strings.ToUpper(c.Type)
+		c.DistanceMetric = strings.ToUpper(c.DistanceMetric)
+		return &c
+	default:
+		return a
+	}
+}
+
+func normalizeAlias(name, alias string) string {
+	if alias == name {
+		return ""
+	}
+	return alias
+}
@@ -0,0 +1,167 @@
+// Package arrowexport writes grsearch search and aggregate exports as [Apache Arrow] IPC streams.
+// It is kept separate from grsearch so that the Arrow dependency is only needed if it is used.
+//
+// [Apache Arrow]: https://arrow.apache.org/
+package arrowexport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"github.com/goslogan/grsearch"
+)
+
+// DefaultBatchSize is the number of rows in each record batch if none is given.
+const DefaultBatchSize = 1024
+
+// Schema returns the Arrow schema for the columns. NUMERIC columns are float64, TAG
+// and LIST columns are lists of strings, GEO columns are structs of lon and lat and
+// VECTOR columns are lists of float32 or float64. Everything else is a string.
+func Schema(columns []grsearch.ExportColumn) *arrow.Schema {
+	fields := make([]arrow.Field, len(columns))
+	for n, column := range columns {
+		fields[n] = arrow.Field{Name: column.Name, Type: dataType(column), Nullable: true}
+	}
+	return arrow.NewSchema(fields, nil)
+}
+
+func dataType(column grsearch.ExportColumn) arrow.DataType {
+	switch strings.ToUpper(column.Type) {
+	case "NUMERIC":
+		return arrow.PrimitiveTypes.Float64
+	case "TAG", grsearch.ExportListType:
+		return arrow.ListOf(arrow.BinaryTypes.String)
+	case "GEO":
+		return arrow.StructOf(
+			arrow.Field{Name: "lon", Type: arrow.PrimitiveTypes.Float64},
+			arrow.Field{Name: "lat", Type: arrow.PrimitiveTypes.Float64},
+		)
+	case "VECTOR":
+		if column.VectorType == "FLOAT64" {
+			return arrow.ListOf(arrow.PrimitiveTypes.Float64)
+		}
+		return arrow.ListOf(arrow.PrimitiveTypes.Float32)
+	default:
+		return arrow.BinaryTypes.String
+	}
+}
+
+// Write writes the rows to w as an Arrow IPC stream in record batches of batchSize rows
+// (or [DefaultBatchSize] if batchSize is not positive). The number of rows written is returned
+// and rows is closed.
+func Write(ctx context.Context, w io.Writer, columns []grsearch.ExportColumn, rows grsearch.ExportRows, batchSize int) (int64, error) {
+	defer rows.Close()
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	mem := memory.NewGoAllocator()
+	schema := Schema(columns)
+	writer := ipc.NewWriter(w, ipc.WithSchema(schema), ipc.WithAllocator(mem))
+	builder := array.NewRecordBuilder(mem, schema)
+	defer builder.Release()
+
+	flush := func() error {
+		record := builder.NewRecord()
+		defer record.Release()
+		if record.NumRows() == 0 {
+			return nil
+		}
+		return writer.Write(record)
+	}
+
+	var count int64
+	pending := 0
+	for rows.Next(ctx) {
+		row := rows.Row()
+		for n, column := range columns {
+			value, err := column.Value(row)
+			if err != nil {
+				writer.Close()
+				return count, err
+			}
+			if err := appendValue(builder.Field(n), value); err != nil {
+				writer.Close()
+				return count, fmt.Errorf("arrowexport: %s: %w", column.Name, err)
+			}
+		}
+		count++
+		pending++
+		if pending == batchSize {
+			if err := flush(); err != nil {
+				writer.Close()
+				return count, err
+			}
+			pending = 0
+		}
+	}
+
+	if err := flush(); err != nil {
+		writer.Close()
+		return count, err
+	}
+	if err := writer.Close(); err != nil {
+		return count, err
+	}
+	return count, rows.Err()
+}
+
+// appendValue appends a converted value (see [grsearch.ExportColumn.Value]) to a builder.
+func appendValue(builder array.Builder, value interface{}) error {
+	if value == nil {
+		builder.AppendNull()
+		return nil
+	}
+
+	switch b := builder.(type) {
+	case *array.StringBuilder:
+		b.Append(fmt.Sprint(value))
+	case *array.Float64Builder:
+		f, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("%v is not a number", value)
+		}
+		b.Append(f)
+	case *array.ListBuilder:
+		b.Append(true)
+		switch values := b.ValueBuilder().(type) {
+		case *array.StringBuilder:
+			tags, ok := value.([]string)
+			if !ok {
+				return fmt.Errorf("%v is not a list", value)
+			}
+			values.AppendValues(tags, nil)
+		case *array.Float32Builder:
+			floats, ok := value.([]float64)
+			if !ok {
+				return fmt.Errorf("%v is not a vector", value)
+			}
+			for _, f := range floats {
+				values.Append(float32(f))
+			}
+		case *array.Float64Builder:
+			floats, ok := value.([]float64)
+			if !ok {
+				return fmt.Errorf("%v is not a vector", value)
+			}
+			values.AppendValues(floats, nil)
+		}
+	case *array.StructBuilder:
+		point, ok := value.(grsearch.GeoPoint)
+		if !ok {
+			return fmt.Errorf("%v is not a geo value", value)
+		}
+		b.Append(true)
+		b.FieldBuilder(0).(*array.Float64Builder).Append(point.Lon)
+		b.FieldBuilder(1).(*array.Float64Builder).Append(point.Lat)
+	default:
+		return fmt.Errorf("unsupported column type %s", builder.Type())
+	}
+	return nil
+}
@@ -1,9 +1,11 @@
 package grsearch_test
 
 import (
+	"errors"
 	"time"
 
 	"github.com/goslogan/grsearch"
+	"github.com/goslogan/grsearch/expr"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -112,6 +114,27 @@ var _ = Describe("We can build aggregate options", Label("builders", "ft.aggrega
 
 	})
 
+	It("can construct pipelines with scores, mid-pipeline loads and GROUPBY 0", func() {
+		base := grsearch.NewAggregateOptions()
+		base.AddScores = true
+		base.Scorer = "BM25"
+		base.Steps = append(base.Steps,
+			&grsearch.AggregateLoadStep{Load: []grsearch.AggregateLoad{{Name: "owner"}, {Name: "customer", As: "name"}}},
+			&grsearch.AggregateGroupBy{Reducers: []grsearch.AggregateReducer{{Name: "count", As: "n"}}},
+			&grsearch.AggregateSort{Keys: []grsearch.AggregateSortKey{{Name: "n", Order: grsearch.SortDesc, Expression: "@n * 2"}}},
+		)
+
+		built := grsearch.NewAggregateBuilder().
+			AddScores().
+			Scorer("BM25").
+			LoadStep("owner", "").
+			LoadStep("customer", "name").
+			GroupAll(grsearch.ReduceCount("n")).
+			SortByExpr("n", expr.Property("n").Mul(expr.Int(2)), grsearch.SortDesc, 0)
+
+		Expect(base).To(Equal(built.Options()))
+	})
+
 	It("can construct queries with parameters", func() {
 		base := grsearch.NewAggregateOptions()
 		base.Params = map[string]interface{}{
@@ -183,3 +206,71 @@ var _ = Describe("We can build aggregate options", Label("builders", "ft.aggrega
 	})
 
 })
+
+var _ = Describe("We can build reducers", Label("builders", "ft.aggregate"), func() {
+
+	// aggregate builds the aggregate with the offline client so that its arguments can be
+	// checked without a server.
+	aggregate := func(reducers ...grsearch.AggregateReducer) *grsearch.AggregateCmd {
+		group := grsearch.NewGroupByBuilder().Property("@owner")
+		for _, r := range reducers {
+			group.Reduce(r)
+		}
+		return offline.FTAggregate(ctx, "hcustomers", "*", grsearch.NewAggregateBuilder().GroupBy(group.GroupBy()).Options())
+	}
+
+	It("adds @ to group by properties", func() {
+		cmd := offline.FTAggregate(ctx, "hcustomers", "*", grsearch.NewAggregateBuilder().
+			GroupBy(grsearch.NewGroupByBuilder().Property("owner").Property("@country").GroupBy()).
+			Options())
+		Expect(cmd.Err()).To(MatchError(errOffline))
+		Expect(cmd.Args()[3:]).To(Equal([]interface{}{"GROUPBY", 2, "@owner", "@country"}))
+	})
+
+	DescribeTable("serializes every reducer",
+		func(r grsearch.AggregateReducer, expected ...interface{}) {
+			cmd := aggregate(r)
+			Expect(cmd.Err()).To(MatchError(errOffline))
+			Expect(cmd.Args()[6:]).To(Equal(expected))
+		},
+		Entry("count", grsearch.ReduceCount("n"), "reduce", "count", 0, "as", "n"),
+		Entry("count_distinct", grsearch.ReduceCountDistinct("country", "n"), "reduce", "count_distinct", 1, "@country", "as", "n"),
+		Entry("count_distinctish", grsearch.ReduceCountDistinctIsh("@country", "n"), "reduce", "count_distinctish", 1, "@country", "as", "n"),
+		Entry("sum", grsearch.ReduceSum("balance", "n"), "reduce", "sum", 1, "@balance", "as", "n"),
+		Entry("min", grsearch.ReduceMin("balance", "n"), "reduce", "min", 1, "@balance", "as", "n"),
+		Entry("max", grsearch.ReduceMax("balance", "n"), "reduce", "max", 1, "@balance", "as", "n"),
+		Entry("avg", grsearch.ReduceAvg("balance", "n"), "reduce", "avg", 1, "@balance", "as", "n"),
+		Entry("stddev", grsearch.ReduceStdDev("balance", "n"), "reduce", "stddev", 1, "@balance", "as", "n"),
+		Entry("quantile", grsearch.ReduceQuantile("balance", 0.5, "n"), "reduce", "quantile", 2, "@balance", 0.5, "as", "n"),
+		Entry("tolist", grsearch.ReduceToList("country", "n"), "reduce", "tolist", 1, "@country", "as", "n"),
+		Entry("first_value", grsearch.ReduceFirstValue("customer", grsearch.SortNone, "n"), "reduce", "first_value", 1, "@customer", "as", "n"),
+		Entry("first_value sorted", grsearch.ReduceFirstValue("balance", grsearch.SortDesc, "n"), "reduce", "first_value", 4, "@balance", "BY", "@balance", "DESC", "as", "n"),
+		Entry("first_value by", grsearch.ReduceFirstValueBy("customer", "balance", "asc", "n"), "reduce", "first_value", 4, "@customer", "BY", "@balance", "ASC", "as", "n"),
+		Entry("random_sample", grsearch.ReduceRandomSample("country", 2, "n"), "reduce", "random_sample", 2, "@country", int64(2), "as", "n"),
+		Entry("hand built", grsearch.AggregateReducer{Name: "QUANTILE", Args: []interface{}{"balance", "0.9"}}, "reduce", "quantile", 2, "@balance", 0.9),
+	)
+
+	DescribeTable("rejects invalid reducers without sending them",
+		func(r grsearch.AggregateReducer, message string) {
+			cmd := aggregate(grsearch.ReduceCount("n"), r)
+			var reducerErr *grsearch.ReducerError
+			Expect(errors.As(cmd.Err(), &reducerErr)).To(BeTrue())
+			Expect(reducerErr.Error()).To(Equal(message))
+		},
+		Entry("unknown", grsearch.AggregateReducer{Name: "median", Args: []interface{}{"@balance"}}, "grsearch: invalid median reducer: unknown reducer"),
+		Entry("too many arguments", grsearch.AggregateReducer{Name: "count", Args: []interface{}{"@balance"}}, "grsearch: invalid count reducer: takes 0 arguments, got 1"),
+		Entry("missing property", grsearch.ReduceSum("@", "n"), "grsearch: invalid sum reducer: argument 1 must be a property name, got @"),
+		Entry("quantile out of range", grsearch.ReduceQuantile("balance", 1.5, "n"), "grsearch: invalid quantile reducer: quantile must be a number from 0 to 1, got 1.5"),
+		Entry("sample too small", grsearch.ReduceRandomSample("country", 0, "n"), "grsearch: invalid random_sample reducer: sample size must be an integer from 1 to 1000, got 0"),
+		Entry("sample too large", grsearch.ReduceRandomSample("country", 1001, "n"), "grsearch: invalid random_sample reducer: sample size must be an integer from 1 to 1000, got 1001"),
+		Entry("bad order", grsearch.ReduceFirstValueBy("customer", "balance", "up", "n"), "grsearch: invalid first_value reducer: order must be ASC or DESC, got up"),
+		Entry("first_value arguments", grsearch.AggregateReducer{Name: "first_value", Args: []interface{}{"@customer", "BY"}}, "grsearch: invalid first_value reducer: takes 1, 3 or 4 arguments, got 2"),
+	)
+
+	It("reports the first invalid reducer added to a group", func() {
+		group := grsearch.NewGroupByBuilder().Property("owner").Reduce(grsearch.ReduceCount("n"))
+		Expect(group.Err()).NotTo(HaveOccurred())
+		group.Reduce(grsearch.ReduceQuantile("balance", 2, "q")).Reduce(grsearch.AggregateReducer{Name: "median"})
+		Expect(group.Err()).To(MatchError("grsearch: invalid quantile reducer: quantile must be a number from 0 to 1, got 2"))
+	})
+})
@@ -0,0 +1,364 @@
+package grsearch
+
+// bulk loading - documents are written with HSET or JSON.SET (depending on the index type) in
+// pipelines of up to batchSize commands. A fixed number of workers execute the pipelines and the
+// caller is blocked whenever they are all busy so memory use is bounded however large the source.
+// Field names are mapped to hash fields or JSON paths using the index schema so documents can use
+// either the attribute name or its alias.
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DefaultBulkBatchSize   = 500 // default number of documents written per pipeline
+	DefaultBulkConcurrency = 4   // default number of pipelines executed concurrently
+)
+
+// BulkDocument is a single document to be loaded by a [BulkLoader].
+type BulkDocument struct {
+	Key    string                 // The full key. If empty the loader prefix is prepended to Id
+	Id     string                 // The document id, appended to the loader prefix
+	Fields map[string]interface{} // Values keyed by attribute name or alias
+	Raw    interface{}            // A complete JSON document (JSON indexes only). Fields is ignored if set
+	line   int64                  // source line, set by the importers
+}
+
+// BulkLoadFailure records a document that could not be written.
+type BulkLoadFailure struct {
+	Key  string
+	Err  error
+	line int64
+}
+
+var errRawOnHash = errors.New("grsearch: raw documents can only be loaded into JSON indexes")
+
+// BulkLoadResult summarises a call to [BulkLoader.Load].
+type BulkLoadResult struct {
+	Loaded   int64
+	Failures []BulkLoadFailure
+}
+
+// BulkLoader writes large numbers of documents matching an index definition.
+type BulkLoader struct {
+	client      *Client
+	index       string
+	options     *IndexOptions
+	prefix      string
+	batchSize   int
+	concurrency int
+	attributes  map[string]SchemaAttribute
+}
+
+var simpleJSONPath = regexp.MustCompile(`^\$(\.[A-Za-z_][A-Za-z0-9_]*)+$`)
+
+// NewBulkLoader creates a loader for the given index. If options is nil the index definition
+// is read with FT.INFO when Load is first called.
+func NewBulkLoader(client *Client, index string, options *IndexOptions) *BulkLoader {
+	return &BulkLoader{
+		client:      client,
+		index:       index,
+		options:     options,
+		batchSize:   DefaultBulkBatchSize,
+		concurrency: DefaultBulkConcurrency,
+	}
+}
+
+// BatchSize sets the number of documents written in each pipeline.
+func (l *BulkLoader) BatchSize(size int) *BulkLoader {
+	if size > 0 {
+		l.batchSize = size
+	}
+	return l
+}
+
+// Concurrency sets the number of pipelines executed at the same time.
+func (l *BulkLoader) Concurrency(workers int) *BulkLoader {
+	if workers > 0 {
+		l.concurrency = workers
+	}
+	return l
+}
+
+// Prefix sets the prefix used to build keys from document ids. It defaults
+// to the first prefix of the index.
+func (l *BulkLoader) Prefix(prefix string) *BulkLoader {
+	l.prefix = prefix
+	return l
+}
+
+// LoadAll writes all the documents in the slice. See [BulkLoader.Load].
+func (l *BulkLoader) LoadAll(ctx context.Context, docs []BulkDocument) (*BulkLoadResult, error) {
+	source := make(chan BulkDocument)
+	go func() {
+		defer close(source)
+		for _, doc := range docs {
+			select {
+			case source <- doc:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return l.Load(ctx, source)
+}
+
+// Load writes every document read from the channel until it is closed or the context is
+// cancelled. Documents which could not be written are reported in the result; the error is
+// only set if the index definition could not be read or the context was cancelled.
+func (l *BulkLoader) Load(ctx context.Context, docs <-chan BulkDocument) (*BulkLoadResult, error) {
+	if err := l.init(ctx); err != nil {
+		return nil, err
+	}
+
+	result := &BulkLoadResult{}
+	lock := sync.Mutex{}
+	batches := make(chan []BulkDocument)
+	wg := sync.WaitGroup{}
+
+	for n := 0; n < l.concurrency; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				loaded, failures := l.write(ctx, batch)
+				lock.Lock()
+				result.Loaded += loaded
+				result.Failures = append(result.Failures, failures...)
+				lock.Unlock()
+			}
+		}()
+	}
+
+	batch := make([]BulkDocument, 0, l.batchSize)
+	send := func() bool {
+		select {
+		case batches <- batch:
+			batch = make([]BulkDocument, 0, l.batchSize)
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+read:
+	for {
+		select {
+		case doc, ok := <-docs:
+			if !ok {
+				if len(batch) > 0 {
+					send()
+				}
+				break read
+			}
+			batch = append(batch, doc)
+			if len(batch) == l.batchSize && !send() {
+				break read
+			}
+		case <-ctx.Done():
+			break read
+		}
+	}
+
+	close(batches)
+	wg.Wait()
+
+	return result, ctx.Err()
+}
+
+// WaitForIndex polls FT.INFO until the index has caught up with the documents loaded.
+func (l *BulkLoader) WaitForIndex(ctx context.Context, interval time.Duration) error {
+	for {
+		info, err := l.client.FTInfo(ctx, l.index).Result()
+		if err != nil {
+			return err
+		}
+		if info.Indexing == 0 && info.PercentIndexed >= 1 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// init reads the index definition if necessary and builds the attribute map.
+func (l *BulkLoader) init(ctx context.Context) error {
+	if l.attributes != nil {
+		return nil
+	}
+
+	if l.options == nil {
+		info, err := l.client.FTInfo(ctx, l.index).Result()
+		if err != nil {
+			return err
+		}
+		l.options = info.Index
+	}
+
+	if l.prefix == "" && len(l.options.Prefix) > 0 {
+		l.prefix = l.options.Prefix[0]
+	}
+
+	l.attributes = map[string]SchemaAttribute{}
+	for _, a := range l.options.Schema {
+		name, alias, _ := attributeIdentity(a)
+		l.attributes[name] = a
+		if alias != "" {
+			l.attributes[alias] = a
+		}
+	}
+
+	return nil
+}
+
+// write loads a single batch in a pipeline.
+func (l *BulkLoader) write(ctx context.Context, batch []BulkDocument) (int64, []BulkLoadFailure) {
+	var loaded int64
+	failures := []BulkLoadFailure{}
+	written := make([]BulkDocument, 0, len(batch))
+	keys := make([]string, 0, len(batch))
+	cmds := make([]redis.Cmder, 0, len(batch))
+	pipe := l.client.Pipeline()
+	onJSON := strings.EqualFold(l.options.On, "json")
+
+	for _, doc := range batch {
+		key := doc.Key
+		if key == "" {
+			key = l.prefix + doc.Id
+		}
+		switch {
+		case doc.Raw != nil && !onJSON:
+			failures = append(failures, BulkLoadFailure{Key: key, Err: errRawOnHash, line: doc.line})
+			continue
+		case doc.Raw != nil:
+			cmds = append(cmds, pipe.JSONSet(ctx, key, "$", doc.Raw))
+		case onJSON:
+			cmds = append(cmds, pipe.JSONSet(ctx, key, "$", l.jsonDocument(doc)))
+		default:
+			cmds = append(cmds, pipe.HSet(ctx, key, l.hashFields(doc)))
+		}
+		written = append(written, doc)
+		keys = append(keys, key)
+	}
+
+	if len(cmds) > 0 {
+		_, _ = pipe.Exec(ctx)
+	}
+
+	for n, cmd := range cmds {
+		if cmd.Err() != nil {
+			failures = append(failures, BulkLoadFailure{Key: keys[n], Err: cmd.Err(), line: written[n].line})
+		} else {
+			loaded++
+		}
+	}
+
+	return loaded, failures
+}
+
+// hashFields maps the document fields to hash fields, converting tag lists
+// and vectors to the form expected by the index.
+func (l *BulkLoader) hashFields(doc BulkDocument) map[string]interface{} {
+	fields := make(map[string]interface{}, len(doc.Fields))
+	for name, value := range doc.Fields {
+		switch a := l.attributes[name].(type) {
+		case *TagAttribute:
+			fields[a.Name] = tagValue(a, value)
+		case *VectorAttribute:
+			fields[a.Name] = vectorValue(a, value)
+		case nil:
+			fields[name] = value
+		default:
+			attribName, _, _ := attributeIdentity(a)
+			fields[attribName] = value
+		}
+	}
+	return fields
+}
+
+// jsonDocument builds a JSON document from the fields, placing each at the path
+// of its attribute. Fields whose attribute path isn't a simple dotted path are
+// stored at the top level under the name given.
+func (l *BulkLoader) jsonDocument(doc BulkDocument) map[string]interface{} {
+	root := map[string]interface{}{}
+	for name, value := range doc.Fields {
+		path := name
+		if a, ok := l.attributes[name]; ok {
+			path, _, _ = attributeIdentity(a)
+		}
+
+		if !simpleJSONPath.MatchString(path) {
+			root[name] = value
+			continue
+		}
+
+		node := root
+		segments := strings.Split(path[2:], ".")
+		for _, segment := range segments[:len(segments)-1] {
+			child, ok := node[segment].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				node[segment] = child
+			}
+			node = child
+		}
+		node[segments[len(segments)-1]] = value
+	}
+	return root
+}
+
+// tagValue joins a list of tags with the separator for the attribute.
+func tagValue(a *TagAttribute, value interface{}) interface{} {
+	tags, ok := value.([]string)
+	if !ok {
+		return value
+	}
+	separator := a.Separator
+	if separator == "" {
+		separator = ","
+	}
+	return strings.Join(tags, separator)
+}
+
+// vectorValue converts a slice of floats to the little endian binary form
+// stored in hashes.
+func vectorValue(a *VectorAttribute, value interface{}) interface{} {
+	var floats []float64
+	switch v := value.(type) {
+	case []float64:
+		floats = v
+	case []float32:
+		floats = make([]float64, len(v))
+		for n, f := range v {
+			floats[n] = float64(f)
+		}
+	default:
+		return value
+	}
+
+	if strings.EqualFold(a.Type, "float64") {
+		buf := make([]byte, 8*len(floats))
+		for n, f := range floats {
+			binary.LittleEndian.PutUint64(buf[n*8:], math.Float64bits(f))
+		}
+		return buf
+	}
+
+	buf := make([]byte, 4*len(floats))
+	for n, f := range floats {
+		binary.LittleEndian.PutUint32(buf[n*4:], math.Float32bits(float32(f)))
+	}
+	return buf
+}
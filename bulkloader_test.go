@@ -0,0 +1,87 @@
+package grsearch_test
+
+import (
+	"fmt"
+	"time"
+
+	grsearch "github.com/goslogan/grsearch"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bulk loading", Ordered, Label("bulk", "ft.create"), func() {
+
+	BeforeAll(func() {
+		DeferCleanup(func() {
+			client.FTDropIndex(ctx, "hbulk", true)
+			client.FTDropIndex(ctx, "jbulk", true)
+		})
+	})
+
+	It("can load hashes in batches", Label("hash"), func() {
+		options := grsearch.NewIndexBuilder().
+			Prefix("hbulk:").
+			Schema(&grsearch.TagAttribute{Name: "categories", Alias: "cats", Separator: ";"}).
+			Schema(&grsearch.NumericAttribute{Name: "n", Sortable: true}).
+			Options()
+		Expect(client.FTCreate(ctx, "hbulk", options).Err()).NotTo(HaveOccurred())
+
+		docs := make([]grsearch.BulkDocument, 1200)
+		for n := range docs {
+			docs[n] = grsearch.BulkDocument{
+				Id: fmt.Sprintf("%d", n),
+				Fields: map[string]interface{}{
+					"cats": []string{"all", fmt.Sprintf("mod%d", n%3)},
+					"n":    n,
+				},
+			}
+		}
+
+		loader := grsearch.NewBulkLoader(client, "hbulk", options).
+			BatchSize(100).
+			Concurrency(3)
+		result, err := loader.LoadAll(ctx, docs)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Failures).To(BeEmpty())
+		Expect(result.Loaded).To(Equal(int64(1200)))
+		Expect(loader.WaitForIndex(ctx, 100*time.Millisecond)).NotTo(HaveOccurred())
+
+		Expect(client.HGet(ctx, "hbulk:7", "categories").Val()).To(Equal("all;mod1"))
+		Expect(client.FTSearchHash(ctx, "hbulk", `@cats:{mod1}`, nil).TotalResults()).To(Equal(int64(400)))
+	})
+
+	It("can load JSON documents using the index definition from FT.INFO", Label("json"), func() {
+		options := grsearch.NewIndexBuilder().
+			On("json").
+			Prefix("jbulk:").
+			Schema(&grsearch.TextAttribute{Name: "$.customer.name", Alias: "name"}).
+			Schema(&grsearch.NumericAttribute{Name: "$.balance", Alias: "balance"}).
+			Options()
+		Expect(client.FTCreate(ctx, "jbulk", options).Err()).NotTo(HaveOccurred())
+
+		loader := grsearch.NewBulkLoader(client, "jbulk", nil)
+		result, err := loader.LoadAll(ctx, []grsearch.BulkDocument{
+			{Id: "1", Fields: map[string]interface{}{"name": "Ellen Ripley", "balance": 100}},
+			{Id: "2", Fields: map[string]interface{}{"name": "Sarah Connor", "balance": -20.5}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Loaded).To(Equal(int64(2)))
+		Expect(loader.WaitForIndex(ctx, 100*time.Millisecond)).NotTo(HaveOccurred())
+
+		Expect(client.JSONGet(ctx, "jbulk:1", "$.customer.name").Val()).To(Equal(`["Ellen Ripley"]`))
+		Expect(client.FTSearchJSON(ctx, "jbulk", `@balance:[-inf 0]`, nil).Keys()).To(ConsistOf("jbulk:2"))
+	})
+
+	It("reports documents which could not be written", Label("hash"), func() {
+		Expect(client.Set(ctx, "hbulk:bad", "not a hash", 0).Err()).NotTo(HaveOccurred())
+		result, err := grsearch.NewBulkLoader(client, "hbulk", nil).LoadAll(ctx, []grsearch.BulkDocument{
+			{Id: "good", Fields: map[string]interface{}{"n": 1}},
+			{Id: "bad", Fields: map[string]interface{}{"n": 2}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Loaded).To(Equal(int64(1)))
+		Expect(result.Failures).To(HaveLen(1))
+		Expect(result.Failures[0].Key).To(Equal("hbulk:bad"))
+		Expect(result.Failures[0].Err).To(HaveOccurred())
+	})
+})
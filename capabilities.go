@@ -0,0 +1,347 @@
+package grsearch
+
+// capability detection - the modules loaded by the server (and their versions) are read with
+// MODULE LIST, falling back to INFO modules where MODULE LIST is disabled, and cached by the
+// client. FTCreate, FTAlter, FTSearchHash, FTSearchJSON and FTAggregate check the features
+// their options need against the cache before sending the command. If the modules can't be
+// read the checks are skipped and the server is left to reject anything it doesn't support.
+// The failure is cached too so that commands don't each wait for another probe; only one
+// probe runs at a time and the cache lock isn't held whilst it does.
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/goslogan/grsearch/internal"
+	"github.com/redis/go-redis/v9"
+)
+
+// Module names as reported by MODULE LIST.
+const (
+	ModuleSearch = "search"
+	ModuleJSON   = "ReJSON"
+)
+
+// Feature identifies an option which needs a minimum module version.
+type Feature string
+
+const (
+	FeatureJSON          Feature = "JSON indexes"
+	FeatureVector        Feature = "VECTOR attributes"
+	FeatureVectorFloat64 Feature = "FLOAT64 vectors"
+	FeatureGeometry      Feature = "GEOMETRY attributes"
+	FeatureSuffixTrie    Feature = "WITHSUFFIXTRIE"
+	FeatureDialect3      Feature = "DIALECT 3"
+	FeatureDialect4      Feature = "DIALECT 4"
+	FeatureRESP3         Feature = "RESP3 search replies" // see [Capabilities.RESP3Replies]
+	FeatureAddScores     Feature = "ADDSCORES"
+)
+
+// requirement is the module version needed for a feature.
+type requirement struct {
+	module  string
+	version int
+}
+
+var featureRequirements = map[Feature][]requirement{
+	FeatureJSON:          {{ModuleSearch, 20200}, {ModuleJSON, 20000}},
+	FeatureVector:        {{ModuleSearch, 20400}},
+	FeatureVectorFloat64: {{ModuleSearch, 20600}},
+	FeatureGeometry:      {{ModuleSearch, 20800}},
+	FeatureSuffixTrie:    {{ModuleSearch, 20600}},
+	FeatureDialect3:      {{ModuleSearch, 20600}},
+	FeatureDialect4:      {{ModuleSearch, 20800}},
+	FeatureRESP3:         {{ModuleSearch, 20800}},
+	FeatureAddScores:     {{ModuleSearch, 21000}},
+}
+
+// UnsupportedFeatureError is returned when a command uses a feature which the modules loaded
+// by the server don't support. Version is zero if the module isn't loaded at all.
+type UnsupportedFeatureError struct {
+	Feature  Feature
+	Module   string
+	Required int
+	Version  int
+}
+
+func (e *UnsupportedFeatureError) Error() string {
+	if e.Version == 0 {
+		return fmt.Sprintf("grsearch: %s requires the %s module %s or later, which is not loaded", e.Feature, e.Module, FormatModuleVersion(e.Required))
+	}
+	return fmt.Sprintf("grsearch: %s requires the %s module %s or later, the server has %s", e.Feature, e.Module, FormatModuleVersion(e.Required), FormatModuleVersion(e.Version))
+}
+
+// Capabilities describes the modules loaded by the server.
+type Capabilities struct {
+	Modules  map[string]int // Module versions by name, e.g. 20813 for 2.8.13
+	Protocol int            // Protocol version used by the client
+}
+
+// SearchVersion returns the version of the search module, or zero if it isn't loaded.
+func (c *Capabilities) SearchVersion() int {
+	return c.Modules[ModuleSearch]
+}
+
+// JSONVersion returns the version of the JSON module, or zero if it isn't loaded.
+func (c *Capabilities) JSONVersion() int {
+	return c.Modules[ModuleJSON]
+}
+
+// RESP3Replies reports whether search commands get RESP3 replies: the client must use protocol 3
+// and the search module must support [FeatureRESP3]. Older modules reply to RESP3 clients as they
+// do to RESP2 ones. Both are parsed, so commands aren't gated on the feature, but
+// [QueryCmd.RESP3Data] and [AggregateCmd.RESP3Data] are only set for RESP3 replies.
+func (c *Capabilities) RESP3Replies() bool {
+	return c.Protocol == 3 && c.Supports(FeatureRESP3)
+}
+
+// Supports reports whether the server supports a feature.
+func (c *Capabilities) Supports(feature Feature) bool {
+	return c.Check(feature) == nil
+}
+
+// Check returns an [UnsupportedFeatureError] for the first feature the server doesn't support.
+func (c *Capabilities) Check(features ...Feature) error {
+	for _, feature := range features {
+		for _, req := range featureRequirements[feature] {
+			if version := c.Modules[req.module]; version < req.version {
+				return &UnsupportedFeatureError{Feature: feature, Module: req.module, Required: req.version, Version: version}
+			}
+		}
+	}
+	return nil
+}
+
+// FormatModuleVersion converts a module version as reported by the server (20813) to
+// the usual dotted form (2.8.13).
+func FormatModuleVersion(version int) string {
+	return fmt.Sprintf("%d.%d.%d", version/10000, version/100%100, version%100)
+}
+
+// capabilityCache holds the capabilities of the server a client is connected to, or the
+// error from reading them. filling is closed when a probe in progress completes.
+type capabilityCache struct {
+	mu      sync.Mutex
+	caps    *Capabilities
+	err     error
+	filling chan struct{}
+}
+
+// Capabilities returns the capabilities of the server, reading them the first time
+// it is called. If they can't be read the error is returned until [Client.ResetCapabilities]
+// is called, unless it came from ctx.
+func (c *Client) Capabilities(ctx context.Context) (*Capabilities, error) {
+	for {
+		c.capabilities.mu.Lock()
+		if caps, err := c.capabilities.caps, c.capabilities.err; caps != nil || err != nil {
+			c.capabilities.mu.Unlock()
+			return caps, err
+		}
+
+		filling := c.capabilities.filling
+		if filling == nil {
+			break
+		}
+		c.capabilities.mu.Unlock()
+
+		select {
+		case <-filling:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	filling := make(chan struct{})
+	c.capabilities.filling = filling
+	c.capabilities.mu.Unlock()
+
+	caps, err := c.probeCapabilities(ctx)
+
+	c.capabilities.mu.Lock()
+	defer c.capabilities.mu.Unlock()
+	if c.capabilities.filling == filling {
+		c.capabilities.filling = nil
+		if err == nil {
+			c.capabilities.caps = caps
+		} else if ctx.Err() == nil {
+			c.capabilities.err = err
+		}
+	}
+	close(filling)
+	return caps, err
+}
+
+// ResetCapabilities discards the cached capabilities, or the error from reading them, so
+// that they are read again when next needed, for example after modules have been upgraded.
+func (c *Client) ResetCapabilities() {
+	c.capabilities.mu.Lock()
+	defer c.capabilities.mu.Unlock()
+	c.capabilities.caps = nil
+	c.capabilities.err = nil
+	c.capabilities.filling = nil
+}
+
+func (c *Client) probeCapabilities(ctx context.Context) (*Capabilities, error) {
+	modules, err := c.Client.Do(ctx, "MODULE", "LIST").Slice()
+	if err != nil {
+		info, infoErr := c.Client.Info(ctx, "modules").Result()
+		if infoErr != nil {
+			return nil, err
+		}
+		return &Capabilities{Modules: parseInfoModules(info), Protocol: c.Client.Options().Protocol}, nil
+	}
+
+	caps := &Capabilities{Modules: map[string]int{}, Protocol: c.Client.Options().Protocol}
+	for _, module := range modules {
+		fields := internal.ToMap(module)
+		name, _ := fields["name"].(string)
+		version, _ := internal.Int64(fields["ver"])
+		caps.Modules[name] = int(version)
+	}
+	return caps, nil
+}
+
+// parseInfoModules reads module versions from the modules section of INFO, which has
+// lines of the form module:name=search,ver=20813,api=1,...
+func parseInfoModules(info string) map[string]int {
+	modules := map[string]int{}
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "module:") {
+			continue
+		}
+		var name string
+		var version int
+		for _, field := range strings.Split(strings.TrimPrefix(line, "module:"), ",") {
+			key, value, _ := strings.Cut(field, "=")
+			switch key {
+			case "name":
+				name = value
+			case "ver":
+				version, _ = strconv.Atoi(value)
+			}
+		}
+		if name != "" {
+			modules[name] = version
+		}
+	}
+	return modules
+}
+
+// checkFeatures returns an error if the server doesn't support one of the features. Nothing
+// is checked if the capabilities of the server can't be read; the error is available from
+// [Client.Capabilities].
+func (c *Client) checkFeatures(ctx context.Context, features []Feature) error {
+	if len(features) == 0 {
+		return nil
+	}
+	caps, err := c.Capabilities(ctx)
+	if err != nil {
+		return nil
+	}
+	return caps.Check(features...)
+}
+
+// gatedCmd wraps a command with the features it needs. [Client.Process] checks the features
+// and then processes the wrapped command.
+type gatedCmd struct {
+	redis.Cmder
+	features []Feature
+}
+
+// postProcess parses the wrapped command, so that one which reaches a pipeline without being
+// unwrapped by [Client.Process] is still parsed.
+func (g *gatedCmd) postProcess() error {
+	if c, ok := g.Cmder.(ExtCmder); ok {
+		return c.postProcess()
+	}
+	return g.Err()
+}
+
+// markParsed marks the wrapped command as parsed. Commands which don't need parsing always
+// report that they have been.
+func (g *gatedCmd) markParsed() bool {
+	if c, ok := g.Cmder.(ExtCmder); ok {
+		return c.markParsed()
+	}
+	return true
+}
+
+// gate wraps cmd if it needs any features.
+func gate(cmd redis.Cmder, features []Feature) redis.Cmder {
+	if len(features) == 0 {
+		return cmd
+	}
+	return &gatedCmd{Cmder: cmd, features: features}
+}
+
+// requiredFeatures returns the features needed to create an index with these options.
+func (i *IndexOptions) requiredFeatures() []Feature {
+	if i == nil {
+		return nil
+	}
+	features := attributeFeatures(i.Schema)
+	if strings.EqualFold(i.On, "json") {
+		features = append(features, FeatureJSON)
+	}
+	return features
+}
+
+// attributeFeatures returns the features needed by schema attributes.
+func attributeFeatures(attributes []SchemaAttribute) []Feature {
+	features := []Feature{}
+	for _, attribute := range attributes {
+		switch a := attribute.(type) {
+		case *VectorAttribute:
+			features = append(features, FeatureVector)
+			if strings.EqualFold(a.Type, "FLOAT64") {
+				features = append(features, FeatureVectorFloat64)
+			}
+		case *GeometryAttribute:
+			features = append(features, FeatureGeometry)
+		case *TextAttribute:
+			if a.WithSuffixTrie {
+				features = append(features, FeatureSuffixTrie)
+			}
+		case *TagAttribute:
+			if a.WithSuffixTrie {
+				features = append(features, FeatureSuffixTrie)
+			}
+		}
+	}
+	return features
+}
+
+// dialectFeatures returns the features needed to use a query dialect.
+func dialectFeatures(dialect uint8) []Feature {
+	switch {
+	case dialect >= 4:
+		return []Feature{FeatureDialect3, FeatureDialect4}
+	case dialect == 3:
+		return []Feature{FeatureDialect3}
+	}
+	return nil
+}
+
+// requiredFeatures returns the features needed to search with these options.
+func (q *QueryOptions) requiredFeatures() []Feature {
+	if q == nil {
+		return nil
+	}
+	return dialectFeatures(q.Dialect)
+}
+
+// requiredFeatures returns the features needed to aggregate with these options.
+func (a *AggregateOptions) requiredFeatures() []Feature {
+	if a == nil {
+		return nil
+	}
+	features := dialectFeatures(a.Dialect)
+	if a.AddScores {
+		features = append(features, FeatureAddScores)
+	}
+	return features
+}
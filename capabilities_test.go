@@ -0,0 +1,152 @@
+package grsearch_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	grsearch "github.com/goslogan/grsearch"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
+)
+
+// probeCounter fails every command with errOffline, counting the MODULE LIST probes and
+// making each one take a little time.
+type probeCounter struct {
+	offlineHook
+	probes *int32
+}
+
+func (h probeCounter) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if cmd.Name() == "module" {
+			atomic.AddInt32(h.probes, 1)
+			time.Sleep(10 * time.Millisecond)
+		}
+		cmd.SetErr(errOffline)
+		return errOffline
+	}
+}
+
+var _ = Describe("Capabilities", Label("capabilities"), func() {
+
+	It("reads the module versions from the server", func() {
+		caps, err := client.Capabilities(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(caps.SearchVersion()).To(BeNumerically(">=", 20400))
+		Expect(caps.JSONVersion()).To(BeNumerically(">", 0))
+		Expect(caps.Supports(grsearch.FeatureJSON)).To(BeTrue())
+		Expect(caps.Supports(grsearch.FeatureVector)).To(BeTrue())
+
+		client.ResetCapabilities()
+		again, err := client.Capabilities(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(again.Modules).To(Equal(caps.Modules))
+	})
+
+	It("reports the version needed for a feature", func() {
+		caps := &grsearch.Capabilities{Modules: map[string]int{grsearch.ModuleSearch: 20613}}
+		Expect(caps.Supports(grsearch.FeatureDialect3)).To(BeTrue())
+		Expect(caps.Supports(grsearch.FeatureJSON)).To(BeFalse())
+
+		err := caps.Check(grsearch.FeatureVector, grsearch.FeatureGeometry)
+		var unsupported *grsearch.UnsupportedFeatureError
+		Expect(errors.As(err, &unsupported)).To(BeTrue())
+		Expect(unsupported.Feature).To(Equal(grsearch.FeatureGeometry))
+		Expect(unsupported.Required).To(Equal(20800))
+		Expect(err.Error()).To(ContainSubstring("2.8.0"))
+		Expect(err.Error()).To(ContainSubstring("2.6.13"))
+	})
+
+	It("reports whether search replies use RESP3", func() {
+		Expect((&grsearch.Capabilities{Modules: map[string]int{grsearch.ModuleSearch: 20613}, Protocol: 3}).RESP3Replies()).To(BeFalse())
+		Expect((&grsearch.Capabilities{Modules: map[string]int{grsearch.ModuleSearch: 20800}, Protocol: 2}).RESP3Replies()).To(BeFalse())
+		Expect((&grsearch.Capabilities{Modules: map[string]int{grsearch.ModuleSearch: 20800}, Protocol: 3}).RESP3Replies()).To(BeTrue())
+
+		caps, err := client.Capabilities(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(caps.Protocol).To(Equal(client.Options().Protocol))
+	})
+
+	It("sends queries the server supports", func() {
+		caps, err := client.Capabilities(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		options := grsearch.NewQueryOptions()
+		options.Dialect = 3
+		cmd := client.FTSearchHash(ctx, "hcustomers", "*", options)
+		if caps.Supports(grsearch.FeatureDialect3) {
+			Expect(cmd.Err()).NotTo(HaveOccurred())
+		} else {
+			Expect(cmd.Err()).To(BeAssignableToTypeOf(&grsearch.UnsupportedFeatureError{}))
+		}
+	})
+
+	It("checks and parses searches run in a pipeline", func() {
+		caps, err := client.Capabilities(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		options := grsearch.NewQueryOptions()
+		options.Dialect = 3
+		result, err := client.FacetedSearch(ctx, "hcustomers", "*", &grsearch.FacetedSearchOptions{
+			Query:  options,
+			Facets: []grsearch.Facet{&grsearch.TagFacet{Attribute: "owner"}},
+		})
+		if caps.Supports(grsearch.FeatureDialect3) {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Hits.TotalResults()).To(Equal(client.FTSearchHash(ctx, "hcustomers", "*", nil).TotalResults()))
+			Expect(result.Hits.Val()).NotTo(BeEmpty())
+			Expect(result.Facets["owner"]).NotTo(BeEmpty())
+		} else {
+			Expect(err).To(BeAssignableToTypeOf(&grsearch.UnsupportedFeatureError{}))
+		}
+	})
+
+	It("probes once for concurrent callers and caches the failure", func() {
+		var probes int32
+		failing := grsearch.NewClient(&redis.Options{})
+		DeferCleanup(failing.Close)
+		failing.AddHook(probeCounter{probes: &probes})
+
+		var wg sync.WaitGroup
+		for n := 0; n < 5; n++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				_, err := failing.Capabilities(ctx)
+				Expect(err).To(MatchError(errOffline))
+			}()
+		}
+		wg.Wait()
+		Expect(atomic.LoadInt32(&probes)).To(Equal(int32(1)))
+
+		options := grsearch.NewQueryOptions()
+		options.Dialect = 3
+		Expect(failing.FTSearchHash(ctx, "hcustomers", "*", options).Err()).To(MatchError(errOffline))
+		Expect(atomic.LoadInt32(&probes)).To(Equal(int32(1)))
+
+		failing.ResetCapabilities()
+		_, err := failing.Capabilities(ctx)
+		Expect(err).To(MatchError(errOffline))
+		Expect(atomic.LoadInt32(&probes)).To(Equal(int32(2)))
+	})
+
+	It("doesn't cache a failure caused by the context", func() {
+		var probes int32
+		failing := grsearch.NewClient(&redis.Options{})
+		DeferCleanup(failing.Close)
+		failing.AddHook(probeCounter{probes: &probes})
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := failing.Capabilities(cancelled)
+		Expect(err).To(HaveOccurred())
+		_, err = failing.Capabilities(ctx)
+		Expect(err).To(MatchError(errOffline))
+		Expect(atomic.LoadInt32(&probes)).To(Equal(int32(2)))
+	})
+})
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+func aliasCommand(ctx context.Context, env *environment, args []string) error {
+	return subcommand(ctx, env, "alias", args, map[string]command{
+		"add":    aliasAdd,
+		"update": aliasUpdate,
+		"del":    aliasDel,
+	})
+}
+
+func aliasAdd(ctx context.Context, env *environment, args []string) error {
+	args, err := parseArgs(newFlags("alias add", "<alias> <index>"), args, 2, 2)
+	if err != nil {
+		return err
+	}
+	return printOK(env, env.client.FTAliasAdd(ctx, args[0], args[1]).Err())
+}
+
+func aliasUpdate(ctx context.Context, env *environment, args []string) error {
+	args, err := parseArgs(newFlags("alias update", "<alias> <index>"), args, 2, 2)
+	if err != nil {
+		return err
+	}
+	return printOK(env, env.client.FTAliasUpdate(ctx, args[0], args[1]).Err())
+}
+
+func aliasDel(ctx context.Context, env *environment, args []string) error {
+	args, err := parseArgs(newFlags("alias del", "<alias>"), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	return printOK(env, env.client.FTAliasDel(ctx, args[0]).Err())
+}
+
+func dictCommand(ctx context.Context, env *environment, args []string) error {
+	return subcommand(ctx, env, "dict", args, map[string]command{
+		"add":  dictAdd,
+		"del":  dictDel,
+		"dump": dictDump,
+	})
+}
+
+func dictAdd(ctx context.Context, env *environment, args []string) error {
+	args, err := parseArgs(newFlags("dict add", "<dictionary> <term>..."), args, 2, -1)
+	if err != nil {
+		return err
+	}
+	added, err := env.client.FTDictAdd(ctx, args[0], args[1:]...).Result()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(env.out, added)
+	return nil
+}
+
+func dictDel(ctx context.Context, env *environment, args []string) error {
+	args, err := parseArgs(newFlags("dict del", "<dictionary> <term>..."), args, 2, -1)
+	if err != nil {
+		return err
+	}
+	deleted, err := env.client.FTDictDel(ctx, args[0], args[1:]...).Result()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(env.out, deleted)
+	return nil
+}
+
+func dictDump(ctx context.Context, env *environment, args []string) error {
+	args, err := parseArgs(newFlags("dict dump", "<dictionary>"), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	terms, err := env.client.FTDictDump(ctx, args[0]).Result()
+	if err != nil {
+		return err
+	}
+	for _, term := range terms {
+		fmt.Fprintln(env.out, term)
+	}
+	return nil
+}
+
+func synCommand(ctx context.Context, env *environment, args []string) error {
+	return subcommand(ctx, env, "syn", args, map[string]command{
+		"update": synUpdate,
+		"dump":   synDump,
+	})
+}
+
+func synUpdate(ctx context.Context, env *environment, args []string) error {
+	args, err := parseArgs(newFlags("syn update", "<index> <group> <term>..."), args, 3, -1)
+	if err != nil {
+		return err
+	}
+	return printOK(env, env.client.FTSynUpdate(ctx, args[0], args[1], args[2:]...).Err())
+}
+
+func synDump(ctx context.Context, env *environment, args []string) error {
+	args, err := parseArgs(newFlags("syn dump", "<index>"), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	synonyms, err := env.client.FTSynDump(ctx, args[0]).Result()
+	if err != nil {
+		return err
+	}
+
+	terms := make([]string, 0, len(synonyms))
+	for term := range synonyms {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+
+	w := tabwriter.NewWriter(env.out, 0, 4, 2, ' ', 0)
+	for _, term := range terms {
+		fmt.Fprintf(w, "%s\t%s\n", term, strings.Join(synonyms[term], ", "))
+	}
+	return w.Flush()
+}
+
+func configCommand(ctx context.Context, env *environment, args []string) error {
+	return subcommand(ctx, env, "config", args, map[string]command{
+		"get": configGet,
+		"set": configSet,
+	})
+}
+
+func configGet(ctx context.Context, env *environment, args []string) error {
+	args, err := parseArgs(newFlags("config get", "[option]..."), args, 0, -1)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		args = []string{"*"}
+	}
+
+	config := map[string]string{}
+	for _, option := range args {
+		values, err := env.client.FTConfigGet(ctx, option).Result()
+		if err != nil {
+			return err
+		}
+		for k, v := range values {
+			config[k] = v
+		}
+	}
+
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(env.out, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\t%s\n", name, config[name])
+	}
+	return w.Flush()
+}
+
+func configSet(ctx context.Context, env *environment, args []string) error {
+	args, err := parseArgs(newFlags("config set", "<option> <value>"), args, 2, 2)
+	if err != nil {
+		return err
+	}
+	return printOK(env, env.client.FTConfigSet(ctx, args[0], args[1]).Err())
+}
+
+// printOK prints OK if err is nil and returns err.
+func printOK(env *environment, err error) error {
+	if err == nil {
+		fmt.Fprintln(env.out, "OK")
+	}
+	return err
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/goslogan/grsearch"
+)
+
+func indexCommand(ctx context.Context, env *environment, args []string) error {
+	return subcommand(ctx, env, "index", args, map[string]command{
+		"create": indexCreate,
+		"apply":  indexApply,
+		"dump":   indexDump,
+		"info":   indexInfo,
+		"list":   indexList,
+		"drop":   indexDrop,
+	})
+}
+
+// indexCreate runs FT.CREATE using a definition read from a file. The index name
+// defaults to the name in the definition.
+func indexCreate(ctx context.Context, env *environment, args []string) error {
+	flags := newFlags("index create", "[index]")
+	from := flags.String("from", "", "YAML or JSON file containing the index definition")
+	args, err := parseArgs(flags, args, 0, 1)
+	if err != nil {
+		return err
+	}
+	if *from == "" {
+		return fmt.Errorf("index create: -from is required")
+	}
+
+	def, err := grsearch.ReadIndexDefinition(*from)
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		def.Name = args[0]
+	}
+
+	if err := env.client.FTCreate(ctx, def.Name, def.Options).Err(); err != nil {
+		return err
+	}
+	fmt.Fprintln(env.out, "OK")
+	return nil
+}
+
+// indexApply applies a directory of definitions, printing the outcome for each index.
+func indexApply(ctx context.Context, env *environment, args []string) error {
+	flags := newFlags("index apply", "<directory>")
+	dryRun := flags.Bool("dry-run", false, "report what would be done without changing anything")
+	skipScan := flags.Bool("skip-initial-scan", false, "don't index existing documents for added attributes")
+	args, err := parseArgs(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	changes, err := env.client.Apply(ctx, args[0], &grsearch.ApplyOptions{DryRun: *dryRun, SkipInitialScan: *skipScan})
+	drift := false
+	for _, change := range changes {
+		fmt.Fprintf(env.out, "%s\t%s\t%s\n", change.Index, change.Action, change.File)
+		for _, a := range change.Added {
+			fmt.Fprintf(env.out, "  + %s\n", strings.Join(describeAttribute(a)[:3], " "))
+		}
+		for _, difference := range change.Differences {
+			fmt.Fprintf(env.out, "  ! %s\n", difference)
+		}
+		drift = drift || change.Action == grsearch.ApplyDrift
+	}
+	if err != nil {
+		return err
+	}
+	if drift {
+		return fmt.Errorf("index apply: one or more indexes differ from their definitions")
+	}
+	return nil
+}
+
+// indexDump writes the definition of an existing index as YAML.
+func indexDump(ctx context.Context, env *environment, args []string) error {
+	flags := newFlags("index dump", "<index>")
+	output := flags.String("o", "", "file to write, defaults to <index>.yaml")
+	args, err := parseArgs(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	info, err := env.client.FTInfo(ctx, args[0]).Result()
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		*output = args[0] + ".yaml"
+	}
+	return grsearch.WriteIndexDefinition(*output, args[0], info.Index)
+}
+
+// indexList prints the names of all indexes.
+func indexList(ctx context.Context, env *environment, args []string) error {
+	if _, err := parseArgs(newFlags("index list", ""), args, 0, 0); err != nil {
+		return err
+	}
+
+	indexes, err := env.client.FTList(ctx).Result()
+	if err != nil {
+		return err
+	}
+	for _, index := range indexes {
+		fmt.Fprintln(env.out, index)
+	}
+	return nil
+}
+
+// indexDrop runs FT.DROPINDEX.
+func indexDrop(ctx context.Context, env *environment, args []string) error {
+	flags := newFlags("index drop", "<index>")
+	dd := flags.Bool("dd", false, "delete the indexed documents as well")
+	args, err := parseArgs(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	if err := env.client.FTDropIndex(ctx, args[0], *dd).Err(); err != nil {
+		return err
+	}
+	fmt.Fprintln(env.out, "OK")
+	return nil
+}
+
+// indexInfo prints the definition and statistics of an index.
+func indexInfo(ctx context.Context, env *environment, args []string) error {
+	flags := newFlags("index info", "<index>")
+	format := flags.String("format", "table", "output format: table or json")
+	args, err := parseArgs(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	info, err := env.client.FTInfo(ctx, args[0]).Result()
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(env.out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	case "table":
+		return printInfo(env, info)
+	default:
+		return fmt.Errorf("unknown format: %s", *format)
+	}
+}
+
+// printInfo writes the info in sections, one value per line.
+func printInfo(env *environment, info *grsearch.Info) error {
+	w := tabwriter.NewWriter(env.out, 0, 4, 2, ' ', 0)
+
+	section := func(title string) {
+		fmt.Fprintf(w, "\n%s\n", title)
+	}
+	value := func(name string, v interface{}) {
+		fmt.Fprintf(w, "  %s\t%v\n", name, v)
+	}
+
+	fmt.Fprintf(w, "Index\t%s\n", info.IndexName)
+
+	section("Definition")
+	value("On", info.Index.On)
+	value("Prefixes", strings.Join(info.Index.Prefix, ", "))
+	value("Default score", info.Index.Score)
+	optional := func(name, v string) {
+		if v != "" {
+			value(name, v)
+		}
+	}
+	optional("Filter", info.Index.Filter)
+	optional("Language", info.Index.Language)
+	optional("Language field", info.Index.LanguageField)
+	optional("Score field", info.Index.ScoreField)
+	optional("Payload field", info.Index.PayloadField)
+	if info.Index.UseStopWords {
+		value("Stopwords", strings.Join(info.Index.StopWords, ", "))
+	}
+	flags := []string{}
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{
+		{info.Index.MaxTextFields, "MAXTEXTFIELDS"},
+		{info.Index.NoOffsets, "NOOFFSETS"},
+		{info.Index.NoHighlight, "NOHL"},
+		{info.Index.NoFields, "NOFIELDS"},
+		{info.Index.NoFreqs, "NOFREQS"},
+	} {
+		if f.set {
+			flags = append(flags, f.name)
+		}
+	}
+	optional("Options", strings.Join(flags, " "))
+	if info.Index.Temporary > 0 {
+		value("Temporary", fmt.Sprintf("%ds", info.Index.Temporary))
+	}
+
+	section("Schema")
+	fmt.Fprintln(w, "  Identifier\tAttribute\tType\tOptions")
+	for _, a := range info.Index.Schema {
+		fmt.Fprintf(w, "  %s\n", strings.Join(describeAttribute(a), "\t"))
+	}
+
+	section("Documents")
+	value("Documents", info.NumDocs)
+	value("Max document id", info.MaxDocId)
+	value("Terms", info.NumTerms)
+	value("Records", info.NumRecords)
+	value("Indexing", info.Indexing)
+	value("Percent indexed", fmt.Sprintf("%.2f%%", info.PercentIndexed*100))
+	value("Hash indexing failures", info.HashIndexingFailures)
+	value("Indexing failures", info.IndexingFailures)
+	value("Total indexing time", info.TotalIndexingTime)
+	value("Number of uses", info.NumberOfUses)
+
+	section("Memory (MB)")
+	value("Inverted index", info.InvertedSize)
+	value("Vector index", info.VectorIndexSize)
+	value("Document table", info.DocTableSize)
+	value("Offset vectors", info.OffsetVectorsSize)
+	value("Sortable values", info.SortableValuesSize)
+	value("Key table", info.KeyTableSize)
+	value("Geoshapes", info.GeoshapesSize)
+	value("Total index memory", info.TotalIndexMemory)
+	value("Inverted index blocks", info.TotalInvertedIndexBlocks)
+	value("Records per document", info.AverageRecordsPerDoc)
+	value("Bytes per record", info.AverageBytesPerRecord)
+	value("Offsets per term", info.AverageOffsetsPerTerm)
+	value("Offset bits per record", info.AverageOffsetBitsPerRecord)
+
+	section("Indexing errors")
+	value("Failures", info.IndexErrors.IndexingFailures)
+	if info.IndexErrors.LastError != "" {
+		value("Last error", info.IndexErrors.LastError)
+		value("Last error key", info.IndexErrors.LastErrorKey)
+	}
+	for _, f := range info.FailedFields() {
+		value(fmt.Sprintf("Attribute %s", f.Attribute), fmt.Sprintf("%d failures, last %q on %s", f.Errors.IndexingFailures, f.Errors.LastError, f.Errors.LastErrorKey))
+	}
+
+	section("Garbage collection")
+	value("Bytes collected", info.GCStats.BytesCollected)
+	value("Total run time", info.GCStats.TotalMsRun)
+	value("Total cycles", info.GCStats.TotalCycles)
+	value("Average cycle time", info.GCStats.AverageCycleTime)
+	value("Last run time", info.GCStats.LastRunTime)
+	value("Numeric trees missed", info.GCStats.GCNumericTreesMissed)
+	value("Blocks denied", info.GCStats.GCBlocksDenied)
+
+	section("Cursors")
+	value("Global idle", info.CursorStats.GlobalIdle)
+	value("Global total", info.CursorStats.GlobalTotal)
+	value("Index capacity", info.CursorStats.IndexCapacity)
+	value("Index total", info.CursorStats.IndexTotal)
+
+	section("Dialects")
+	value("Dialect 1", info.DialectStats.Dialect1)
+	value("Dialect 2", info.DialectStats.Dialect2)
+	value("Dialect 3", info.DialectStats.Dialect3)
+	value("Dialect 4", info.DialectStats.Dialect4)
+
+	return w.Flush()
+}
+
+// describeAttribute returns the identifier, alias, type and options of an attribute.
+func describeAttribute(a grsearch.SchemaAttribute) []string {
+	options := []string{}
+	flag := func(set bool, name string) {
+		if set {
+			options = append(options, name)
+		}
+	}
+
+	switch a := a.(type) {
+	case *grsearch.TagAttribute:
+		if a.Separator != "" && a.Separator != "," {
+			options = append(options, "SEPARATOR "+a.Separator)
+		}
+		flag(a.CaseSensitive, "CASESENSITIVE")
+		flag(a.WithSuffixTrie, "WITHSUFFIXTRIE")
+		flag(a.Sortable, "SORTABLE")
+		flag(a.UnNormalized, "UNF")
+		flag(a.NoIndex, "NOINDEX")
+		return []string{a.Name, a.Alias, "TAG", strings.Join(options, " ")}
+	case *grsearch.TextAttribute:
+		if a.Weight != 0 && a.Weight != 1 {
+			options = append(options, fmt.Sprintf("WEIGHT %g", a.Weight))
+		}
+		if a.Phonetic != "" {
+			options = append(options, "PHONETIC "+a.Phonetic)
+		}
+		flag(a.NoStem, "NOSTEM")
+		flag(a.WithSuffixTrie, "WITHSUFFIXTRIE")
+		flag(a.Sortable, "SORTABLE")
+		flag(a.UnNormalized, "UNF")
+		flag(a.NoIndex, "NOINDEX")
+		return []string{a.Name, a.Alias, "TEXT", strings.Join(options, " ")}
+	case *grsearch.NumericAttribute:
+		flag(a.Sortable, "SORTABLE")
+		flag(a.NoIndex, "NOINDEX")
+		return []string{a.Name, a.Alias, "NUMERIC", strings.Join(options, " ")}
+	case *grsearch.GeoAttribute:
+		flag(a.Sortable, "SORTABLE")
+		flag(a.NoIndex, "NOINDEX")
+		return []string{a.Name, a.Alias, "GEO", strings.Join(options, " ")}
+	case *grsearch.GeometryAttribute:
+		return []string{a.Name, a.Alias, "GEOMETRY", ""}
+	case *grsearch.VectorAttribute:
+		options = append(options, strings.ToUpper(a.Algorithm), strings.ToUpper(a.Type),
+			fmt.Sprintf("DIM %d", a.Dim), strings.ToUpper(a.DistanceMetric))
+		return []string{a.Name, a.Alias, "VECTOR", strings.Join(options, " ")}
+	default:
+		return []string{fmt.Sprint(a), "", "", ""}
+	}
+}
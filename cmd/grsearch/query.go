@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/goslogan/grsearch"
+)
+
+// searchCommand runs FT.SEARCH and prints every result, paging with a SearchIterator.
+func searchCommand(ctx context.Context, env *environment, args []string) error {
+	flags := newFlags("search", "<index> <query>")
+	format := flags.String("format", "table", "output format: table, json (one object per line) or csv")
+	pageSize := flags.Int64("page-size", grsearch.DefaultLimit, "number of results fetched by each search")
+	max := flags.Int64("max", 0, "maximum number of results to print, 0 for all")
+	sortBy := flags.String("sortby", "", "attribute to sort by")
+	desc := flags.Bool("desc", false, "sort in descending order")
+	dialect := flags.Uint("dialect", 2, "query dialect")
+	verbatim := flags.Bool("verbatim", false, "do not expand query terms")
+	returns := stringList{}
+	flags.Var(&returns, "return", "attribute or JSON path to return, optionally followed by AS <name> (repeatable)")
+	params := stringList{}
+	flags.Var(&params, "param", "query parameter as name=value (repeatable)")
+	args, err := parseArgs(flags, args, 2, 2)
+	if err != nil {
+		return err
+	}
+
+	info, err := env.client.FTInfo(ctx, args[0]).Result()
+	if err != nil {
+		return err
+	}
+
+	builder := grsearch.NewQueryBuilder().
+		Limit(grsearch.DefaultOffset, *pageSize).
+		Dialect(uint8(*dialect))
+	if *sortBy != "" {
+		builder.SortBy(*sortBy)
+		if *desc {
+			builder.Descending()
+		}
+	}
+	if *verbatim {
+		builder.Verbatim()
+	}
+	for _, r := range returns {
+		name, as := splitAs(r)
+		builder.Return(name, as)
+	}
+	if values, err := parseParams(params); err != nil {
+		return err
+	} else if len(values) > 0 {
+		builder.Params(values)
+	}
+	options := builder.Options()
+
+	columns, err := env.client.InferSearchColumns(ctx, args[0], options)
+	if err != nil {
+		return err
+	}
+
+	var cmd *grsearch.QueryCmd
+	if strings.EqualFold(info.Index.On, "json") {
+		cmd = env.client.FTSearchJSON(ctx, args[0], args[1], options)
+	} else {
+		cmd = env.client.FTSearchHash(ctx, args[0], args[1], options)
+	}
+	if cmd.Err() != nil {
+		return cmd.Err()
+	}
+
+	rows := grsearch.NewSearchExportRows(cmd.Iterator(ctx))
+	return printRows(ctx, env.out, *format, columns, limitRows(rows, *max))
+}
+
+// aggregateCommand runs FT.AGGREGATE with a cursor and prints every row. The pipeline is
+// given in the same form as for FT.AGGREGATE, for example
+//
+//	grsearch aggregate hcustomers '*' GROUPBY 1 @owner REDUCE SUM 1 @balance AS total SORTBY 2 @total DESC
+func aggregateCommand(ctx context.Context, env *environment, args []string) error {
+	flags := newFlags("aggregate", "<index> <query> [pipeline]...")
+	format := flags.String("format", "table", "output format: table, json (one object per line) or csv")
+	cursor := flags.Uint64("cursor", 1000, "number of rows read from the cursor at a time")
+	dialect := flags.Uint("dialect", 2, "query dialect")
+	verbatim := flags.Bool("verbatim", false, "do not expand query terms")
+	loads := stringList{}
+	flags.Var(&loads, "load", "attribute to load, optionally followed by AS <name>; * loads all (repeatable)")
+	params := stringList{}
+	flags.Var(&params, "param", "query parameter as name=value (repeatable)")
+	args, err := parseArgs(flags, args, 2, -1)
+	if err != nil {
+		return err
+	}
+
+	builder := grsearch.NewAggregateBuilder().
+		Cursor(*cursor, 0).
+		Dialect(uint8(*dialect))
+	if *verbatim {
+		builder.Verbatim()
+	}
+	for _, l := range loads {
+		if l == "*" {
+			builder.LoadAll()
+			continue
+		}
+		name, as := splitAs(l)
+		builder.Load(name, as)
+	}
+	if values, err := parseParams(params); err != nil {
+		return err
+	} else if len(values) > 0 {
+		builder.Params(values)
+	}
+	options := builder.Options()
+	if options.Steps, err = parsePipeline(args[2:]); err != nil {
+		return err
+	}
+
+	columns, err := env.client.InferAggregateColumns(ctx, args[0], options)
+	if err != nil {
+		return err
+	}
+
+	cmd := env.client.FTAggregate(ctx, args[0], args[1], options)
+	if cmd.Err() != nil {
+		return cmd.Err()
+	}
+
+	return printRows(ctx, env.out, *format, columns, grsearch.NewAggregateExportRows(cmd))
+}
+
+// printRows writes the rows in the requested format.
+func printRows(ctx context.Context, out io.Writer, format string, columns []grsearch.ExportColumn, rows grsearch.ExportRows) error {
+	var err error
+	switch format {
+	case "table":
+		err = printTable(ctx, out, columns, rows)
+	case "json":
+		_, err = grsearch.ExportNDJSON(ctx, out, columns, rows)
+	case "csv":
+		_, err = grsearch.ExportCSV(ctx, out, columns, rows)
+	default:
+		err = fmt.Errorf("unknown format: %s", format)
+	}
+	return err
+}
+
+// printTable writes the rows as aligned columns with a header, followed by the row count.
+func printTable(ctx context.Context, out io.Writer, columns []grsearch.ExportColumn, rows grsearch.ExportRows) error {
+	defer rows.Close()
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	cells := make([]string, len(columns))
+	for n, column := range columns {
+		cells[n] = column.Name
+	}
+	fmt.Fprintln(w, strings.Join(cells, "\t"))
+
+	count := 0
+	for rows.Next(ctx) {
+		row := rows.Row()
+		for n, column := range columns {
+			value, err := column.Value(row)
+			if err != nil {
+				return err
+			}
+			cells[n] = cell(value)
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+		count++
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "(%d rows)\n", count)
+	return nil
+}
+
+// cell formats a value for the table output.
+func cell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.NewReplacer("\t", " ", "\n", " ").Replace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []string:
+		return strings.Join(v, ", ")
+	default:
+		js, _ := json.Marshal(v)
+		return string(js)
+	}
+}
+
+// limitedRows stops after a maximum number of rows.
+type limitedRows struct {
+	grsearch.ExportRows
+	max   int64
+	count int64
+}
+
+// limitRows returns rows limited to max rows (or all of them if max is 0).
+func limitRows(rows grsearch.ExportRows, max int64) grsearch.ExportRows {
+	if max <= 0 {
+		return rows
+	}
+	return &limitedRows{ExportRows: rows, max: max}
+}
+
+func (r *limitedRows) Next(ctx context.Context) bool {
+	if r.count >= r.max {
+		return false
+	}
+	r.count++
+	return r.ExportRows.Next(ctx)
+}
+
+// splitAs splits "name AS alias" into its parts.
+func splitAs(value string) (string, string) {
+	fields := strings.Fields(value)
+	if len(fields) == 3 && strings.EqualFold(fields[1], "as") {
+		return fields[0], fields[2]
+	}
+	return value, ""
+}
+
+// parseParams parses name=value query parameters.
+func parseParams(params []string) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(params))
+	for _, p := range params {
+		name, value, ok := strings.Cut(p, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid parameter %q, expected name=value", p)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// parsePipeline converts FT.AGGREGATE pipeline arguments (GROUPBY, REDUCE, SORTBY, APPLY,
+// FILTER, LOAD and LIMIT) to aggregate steps. Reducers are added with a GroupByBuilder so
+// that invalid ones are reported before anything is sent.
+func parsePipeline(args []string) ([]grsearch.AggregateStep, error) {
+	steps := []grsearch.AggregateStep{}
+	var group *grsearch.GroupByBuilder
+	groupStep := 0
+
+	// counted reads a count followed by that many arguments.
+	counted := func(n int, keyword string) ([]string, int, error) {
+		if n+1 >= len(args) {
+			return nil, 0, fmt.Errorf("%s: missing argument count", keyword)
+		}
+		count, err := strconv.Atoi(args[n+1])
+		if err != nil || count < 0 || n+2+count > len(args) {
+			return nil, 0, fmt.Errorf("%s: invalid argument count %s", keyword, args[n+1])
+		}
+		return args[n+2 : n+2+count], n + 2 + count, nil
+	}
+
+	// as reads an optional AS name.
+	as := func(n int) (string, int) {
+		if n+1 < len(args) && strings.EqualFold(args[n], "as") {
+			return args[n+1], n + 2
+		}
+		return "", n
+	}
+
+	for n := 0; n < len(args); {
+		keyword := strings.ToUpper(args[n])
+		switch keyword {
+		case "GROUPBY":
+			properties, next, err := counted(n, keyword)
+			if err != nil {
+				return nil, err
+			}
+			group = grsearch.NewGroupByBuilder().Properties(properties)
+			groupBy := group.GroupBy()
+			steps = append(steps, &groupBy)
+			groupStep = len(steps) - 1
+			n = next
+		case "REDUCE":
+			if group == nil {
+				return nil, fmt.Errorf("REDUCE must follow GROUPBY")
+			}
+			if n+1 >= len(args) {
+				return nil, fmt.Errorf("REDUCE: missing function")
+			}
+			fnArgs, next, err := counted(n+1, keyword)
+			if err != nil {
+				return nil, err
+			}
+			reducer := grsearch.AggregateReducer{Name: strings.ToLower(args[n+1])}
+			for _, a := range fnArgs {
+				reducer.Args = append(reducer.Args, a)
+			}
+			reducer.As, n = as(next)
+			if err := group.Reduce(reducer).Err(); err != nil {
+				return nil, err
+			}
+			groupBy := group.GroupBy()
+			steps[groupStep] = &groupBy
+		case "SORTBY":
+			keys, next, err := counted(n, keyword)
+			if err != nil {
+				return nil, err
+			}
+			sort := &grsearch.AggregateSort{}
+			for k := 0; k < len(keys); k++ {
+				key := grsearch.AggregateSortKey{Name: keys[k]}
+				if k+1 < len(keys) && (strings.EqualFold(keys[k+1], grsearch.SortAsc) || strings.EqualFold(keys[k+1], grsearch.SortDesc)) {
+					key.Order = strings.ToUpper(keys[k+1])
+					k++
+				}
+				sort.Keys = append(sort.Keys, key)
+			}
+			n = next
+			if n+1 < len(args) && strings.EqualFold(args[n], "max") {
+				if sort.Max, err = strconv.ParseInt(args[n+1], 10, 64); err != nil {
+					return nil, fmt.Errorf("SORTBY: invalid MAX %s", args[n+1])
+				}
+				n += 2
+			}
+			steps = append(steps, sort)
+			group = nil
+		case "APPLY":
+			if n+1 >= len(args) {
+				return nil, fmt.Errorf("APPLY: missing expression")
+			}
+			apply := &grsearch.AggregateApply{Expression: args[n+1]}
+			apply.As, n = as(n + 2)
+			steps = append(steps, apply)
+			group = nil
+		case "FILTER":
+			if n+1 >= len(args) {
+				return nil, fmt.Errorf("FILTER: missing expression")
+			}
+			steps = append(steps, grsearch.AggregateFilter(args[n+1]))
+			n += 2
+			group = nil
+		case "LOAD":
+			fields, next, err := counted(n, keyword)
+			if err != nil {
+				return nil, err
+			}
+			load := &grsearch.AggregateLoadStep{}
+			for k := 0; k < len(fields); k++ {
+				field := grsearch.AggregateLoad{Name: fields[k]}
+				if k+2 < len(fields) && strings.EqualFold(fields[k+1], "as") {
+					field.As = fields[k+2]
+					k += 2
+				}
+				load.Load = append(load.Load, field)
+			}
+			steps = append(steps, load)
+			n = next
+			group = nil
+		case "LIMIT":
+			if n+2 >= len(args) {
+				return nil, fmt.Errorf("LIMIT: expected offset and count")
+			}
+			offset, err1 := strconv.ParseInt(args[n+1], 10, 64)
+			num, err2 := strconv.ParseInt(args[n+2], 10, 64)
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("LIMIT: invalid offset or count")
+			}
+			steps = append(steps, grsearch.NewLimit(offset, num))
+			n += 3
+			group = nil
+		default:
+			return nil, fmt.Errorf("unexpected pipeline argument %s", args[n])
+		}
+	}
+
+	return steps, nil
+}
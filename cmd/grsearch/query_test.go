@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/goslogan/grsearch"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// sliceRows is a fixed set of rows to print.
+type sliceRows struct {
+	rows   []map[string]interface{}
+	pos    int
+	closed bool
+}
+
+func (r *sliceRows) Next(ctx context.Context) bool {
+	if r.pos >= len(r.rows) {
+		return false
+	}
+	r.pos++
+	return true
+}
+
+func (r *sliceRows) Row() map[string]interface{} {
+	return r.rows[r.pos-1]
+}
+
+func (r *sliceRows) Err() error {
+	return nil
+}
+
+func (r *sliceRows) Close() {
+	r.closed = true
+}
+
+var _ = Describe("Parsing aggregate pipelines", func() {
+
+	DescribeTable("converts pipeline arguments to steps",
+		func(args []string, expected ...grsearch.AggregateStep) {
+			steps, err := parsePipeline(args)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(steps).To(Equal(append([]grsearch.AggregateStep{}, expected...)))
+		},
+		Entry("empty", []string{}),
+		Entry("group by with reducers",
+			[]string{"GROUPBY", "1", "@owner", "REDUCE", "SUM", "1", "@balance", "AS", "total", "reduce", "count", "0"},
+			&grsearch.AggregateGroupBy{
+				Properties: []string{"@owner"},
+				Reducers: []grsearch.AggregateReducer{
+					{Name: "sum", Args: []interface{}{"@balance"}, As: "total"},
+					{Name: "count"},
+				},
+			}),
+		Entry("group by 0",
+			[]string{"GROUPBY", "0", "REDUCE", "COUNT", "0", "AS", "n"},
+			&grsearch.AggregateGroupBy{
+				Properties: []string{},
+				Reducers:   []grsearch.AggregateReducer{{Name: "count", As: "n"}},
+			}),
+		Entry("sort by with orders and max",
+			[]string{"SORTBY", "3", "@total", "desc", "@owner", "MAX", "10"},
+			&grsearch.AggregateSort{
+				Keys: []grsearch.AggregateSortKey{{Name: "@total", Order: grsearch.SortDesc}, {Name: "@owner"}},
+				Max:  10,
+			}),
+		Entry("apply, filter, load and limit",
+			[]string{"APPLY", "upper(@owner)", "AS", "uowner", "FILTER", "@balance > 0", "LOAD", "2", "@customer", "@email", "LIMIT", "5", "10"},
+			&grsearch.AggregateApply{Expression: "upper(@owner)", As: "uowner"},
+			grsearch.AggregateFilter("@balance > 0"),
+			&grsearch.AggregateLoadStep{Load: []grsearch.AggregateLoad{{Name: "@customer"}, {Name: "@email"}}},
+			grsearch.NewLimit(5, 10)),
+		Entry("load with aliases",
+			[]string{"LOAD", "5", "$.customer", "AS", "customer", "@email", "@balance"},
+			&grsearch.AggregateLoadStep{Load: []grsearch.AggregateLoad{{Name: "$.customer", As: "customer"}, {Name: "@email"}, {Name: "@balance"}}}),
+		Entry("reducers checked and normalised",
+			[]string{"GROUPBY", "1", "@owner", "REDUCE", "QUANTILE", "2", "balance", "0.9", "AS", "p90"},
+			&grsearch.AggregateGroupBy{
+				Properties: []string{"@owner"},
+				Reducers:   []grsearch.AggregateReducer{{Name: "quantile", Args: []interface{}{"@balance", 0.9}, As: "p90"}},
+			}),
+	)
+
+	DescribeTable("rejects invalid pipelines",
+		func(args []string, message string) {
+			_, err := parsePipeline(args)
+			Expect(err).To(MatchError(message))
+		},
+		Entry("missing count", []string{"GROUPBY"}, "GROUPBY: missing argument count"),
+		Entry("bad count", []string{"GROUPBY", "x"}, "GROUPBY: invalid argument count x"),
+		Entry("count past the end", []string{"LOAD", "3", "@a"}, "LOAD: invalid argument count 3"),
+		Entry("reduce without group by", []string{"REDUCE", "COUNT", "0"}, "REDUCE must follow GROUPBY"),
+		Entry("reduce after another step", []string{"GROUPBY", "1", "@a", "FILTER", "@a > 1", "REDUCE", "COUNT", "0"}, "REDUCE must follow GROUPBY"),
+		Entry("reduce without function", []string{"GROUPBY", "1", "@a", "REDUCE"}, "REDUCE: missing function"),
+		Entry("invalid reducer", []string{"GROUPBY", "1", "@a", "REDUCE", "QUANTILE", "2", "@b", "2"}, "grsearch: invalid quantile reducer: quantile must be a number from 0 to 1, got 2"),
+		Entry("bad sort max", []string{"SORTBY", "1", "@a", "MAX", "lots"}, "SORTBY: invalid MAX lots"),
+		Entry("apply without expression", []string{"APPLY"}, "APPLY: missing expression"),
+		Entry("filter without expression", []string{"FILTER"}, "FILTER: missing expression"),
+		Entry("short limit", []string{"LIMIT", "0"}, "LIMIT: expected offset and count"),
+		Entry("bad limit", []string{"LIMIT", "0", "ten"}, "LIMIT: invalid offset or count"),
+		Entry("unknown keyword", []string{"WITHCURSOR"}, "unexpected pipeline argument WITHCURSOR"),
+	)
+})
+
+var _ = Describe("Parsing arguments", func() {
+
+	DescribeTable("splits AS from names",
+		func(value, name, as string) {
+			n, a := splitAs(value)
+			Expect(n).To(Equal(name))
+			Expect(a).To(Equal(as))
+		},
+		Entry("name only", "email", "email", ""),
+		Entry("with alias", "$.customer.name AS name", "$.customer.name", "name"),
+		Entry("lower case as", "email as e", "email", "e"),
+		Entry("not an alias", "email is e", "email is e", ""),
+	)
+
+	It("parses query parameters", func() {
+		values, err := parseParams([]string{"country=UK", "query=a=b", "empty="})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(values).To(Equal(map[string]interface{}{"country": "UK", "query": "a=b", "empty": ""}))
+
+		_, err = parseParams([]string{"country"})
+		Expect(err).To(MatchError(`invalid parameter "country", expected name=value`))
+	})
+})
+
+var _ = Describe("Printing rows", func() {
+
+	columns := []grsearch.ExportColumn{
+		{Name: "__key", Field: "__key", Type: "TEXT"},
+		{Name: "balance", Field: "balance", Type: "NUMERIC"},
+		{Name: "countries", Field: "countries", Type: "TAG", Separator: ","},
+	}
+	rows := func() *sliceRows {
+		return &sliceRows{rows: []map[string]interface{}{
+			{"__key": "haccount:1", "balance": "10.50", "countries": "UK,FR"},
+			{"__key": "haccount:2\twith\ttabs", "balance": "-3"},
+		}}
+	}
+
+	DescribeTable("writes every format",
+		func(format, expected string) {
+			var out bytes.Buffer
+			source := rows()
+			Expect(printRows(context.Background(), &out, format, columns, source)).To(Succeed())
+			Expect(out.String()).To(Equal(expected))
+			Expect(source.closed).To(BeTrue())
+		},
+		Entry("table", "table", ""+
+			"__key                 balance  countries\n"+
+			"haccount:1            10.5     UK, FR\n"+
+			"haccount:2 with tabs  -3       \n"+
+			"(2 rows)\n"),
+		Entry("json", "json", ""+
+			`{"__key":"haccount:1","balance":10.5,"countries":["UK","FR"]}`+"\n"+
+			`{"__key":"haccount:2\twith\ttabs","balance":-3,"countries":null}`+"\n"),
+		Entry("csv", "csv", ""+
+			"__key,balance,countries\n"+
+			"haccount:1,10.5,\"UK,FR\"\n"+
+			"haccount:2\twith\ttabs,-3,\n"),
+	)
+
+	It("rejects unknown formats", func() {
+		Expect(printRows(context.Background(), &bytes.Buffer{}, "xml", columns, rows())).To(MatchError("unknown format: xml"))
+	})
+
+	It("stops after the maximum number of rows", func() {
+		var out bytes.Buffer
+		source := rows()
+		Expect(printRows(context.Background(), &out, "csv", columns, limitRows(source, 1))).To(Succeed())
+		Expect(out.String()).To(Equal("__key,balance,countries\nhaccount:1,10.5,\"UK,FR\"\n"))
+		Expect(source.closed).To(BeTrue())
+	})
+})
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/goslogan/grsearch/internal"
+	"github.com/mitchellh/mapstructure"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -14,6 +15,7 @@ import (
 
 type QueryCmd struct {
 	redis.Cmd
+	parseOnce
 	totalResults int64
 	keymap       map[string]int
 	respData     *RESPData
@@ -22,6 +24,7 @@ type QueryCmd struct {
 	onHash       bool
 	process      cmdable // used to initialise iterator
 	count        int64   // contains the total number of results if the query was successful
+	rewritten    bool    // set once query rewriters have been run
 }
 
 type RESPData struct {
@@ -77,11 +80,28 @@ func (cmd *QueryCmd) Count() int64 {
 	return cmd.count
 }
 
+// Options returns the options used for the search. It is nil if the command
+// was created with NewQueryCmd.
+func (cmd *QueryCmd) Options() *QueryOptions {
+	return cmd.options
+}
+
 // Iterator returns an iterator for the search.
 func (cmd *QueryCmd) Iterator(ctx context.Context) *SearchIterator {
 	return NewSearchIterator(ctx, cmd, cmd.process)
 }
 
+// Prefetch returns an iterator for the search which fetches up to ahead pages concurrently
+// before they are needed. Close must be called if iteration stops early.
+func (cmd *QueryCmd) Prefetch(ctx context.Context, ahead int) *PrefetchIterator {
+	return NewPrefetchIterator(ctx, cmd, cmd.process, ahead)
+}
+
+// SearchAfter returns a keyset iterator for the search. The query must be sorted (SortBy must be set).
+func (cmd *QueryCmd) SearchAfter(ctx context.Context) *SearchAfterIterator {
+	return NewSearchAfterIterator(ctx, cmd, cmd.process)
+}
+
 // RESPData returns the additional data returned with a RESP3 response if set.
 func (cmd *QueryCmd) RESP3Data() *RESPData {
 	return cmd.respData
@@ -190,6 +210,10 @@ func (cmd *QueryCmd) postprocessRESP3Response(baseResponse interface{}, parser f
 		}
 		current.Key = rawResult["id"].(string)
 
+		if cmd.options.WithSortKeys {
+			current.SortKey, _ = rawResult["sortkey"].(string)
+		}
+
 		if cmd.options.WithScores {
 			if cmd.options.ExplainScore {
 				scoreInfo := rawResult["score"].([]interface{})
@@ -226,6 +250,7 @@ func (cmd *QueryCmd) postprocessRESP2Response(baseResponse interface{}, parser f
 		var current *SearchResult
 		var score float64 = 0
 		var explanation []interface{}
+		var sortKey string
 		j := 0
 		key := response[i+j].(string)
 
@@ -243,6 +268,11 @@ func (cmd *QueryCmd) postprocessRESP2Response(baseResponse interface{}, parser f
 			j++
 		}
 
+		if cmd.options.WithSortKeys {
+			sortKey, _ = response[i+j].(string)
+			j++
+		}
+
 		if cmd.options.NoContent {
 			current = &SearchResult{}
 		} else {
@@ -259,6 +289,7 @@ func (cmd *QueryCmd) postprocessRESP2Response(baseResponse interface{}, parser f
 		current.Key = key
 		current.Explanation = explanation
 		current.Score = score
+		current.SortKey = sortKey
 
 		results = append(results, current)
 		j++
@@ -275,6 +306,7 @@ func (cmd *QueryCmd) postprocessRESP2Response(baseResponse interface{}, parser f
 
 type ConfigGetCmd struct {
 	redis.Cmd
+	parseOnce
 	val map[string]string
 }
 
@@ -285,25 +317,39 @@ func NewConfigGetCmd(ctx context.Context, args ...interface{}) *ConfigGetCmd {
 }
 
 func (c *ConfigGetCmd) postProcess() error {
-	if result, err := c.Slice(); err == nil {
-		configs := make(map[string]string, len(result))
+	if c.Err() != nil {
+		return c.Err()
+	}
+
+	configs := map[string]string{}
+	switch result := c.Cmd.Val().(type) {
+	case []interface{}: // RESP2
 		for _, cfg := range result {
-			key := cfg.([]interface{})[0].(string)
-			if key[0] != '_' {
-				if cfg.([]interface{})[1] != nil {
-					val := cfg.([]interface{})[1].(string)
-					configs[key] = val
-				} else {
-					configs[key] = ""
-				}
+			pair, ok := cfg.([]interface{})
+			if !ok || len(pair) != 2 {
+				return fmt.Errorf("redis: %v is not a valid FT.CONFIG GET entry", cfg)
 			}
-
+			configs[pair[0].(string)] = configValue(pair[1])
 		}
-		c.SetVal(configs)
+	case map[interface{}]interface{}: // RESP3
+		for k, v := range result {
+			configs[k.(string)] = configValue(v)
+		}
+	default:
+		return fmt.Errorf("redis: %v is not a valid result for FT.CONFIG GET", result)
 	}
+	c.SetVal(configs)
 	return nil
 }
 
+// configValue converts a value from FT.CONFIG GET to a string. Unset values are nil.
+func configValue(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
 func (cmd *ConfigGetCmd) SetVal(val map[string]string) {
 	cmd.val = val
 }
@@ -316,6 +362,46 @@ func (cmd *ConfigGetCmd) Result() (map[string]string, error) {
 	return cmd.Val(), cmd.Err()
 }
 
+/*******************************************************************************
+ ***** SearchConfigCmd 												  ******
+ *******************************************************************************/
+
+type SearchConfigCmd struct {
+	ConfigGetCmd
+	config *SearchConfig
+}
+
+// NewSearchConfigCmd returns an initialised command to read the search config into a [SearchConfig].
+func NewSearchConfigCmd(ctx context.Context, args ...interface{}) *SearchConfigCmd {
+	return &SearchConfigCmd{
+		ConfigGetCmd: *NewConfigGetCmd(ctx, args...),
+	}
+}
+
+func (cmd *SearchConfigCmd) postProcess() error {
+	if err := cmd.ConfigGetCmd.postProcess(); err != nil {
+		return err
+	}
+	config, err := DecodeSearchConfig(cmd.ConfigGetCmd.Val())
+	if err != nil {
+		return err
+	}
+	cmd.SetVal(config)
+	return nil
+}
+
+func (cmd *SearchConfigCmd) SetVal(val *SearchConfig) {
+	cmd.config = val
+}
+
+func (cmd *SearchConfigCmd) Val() *SearchConfig {
+	return cmd.config
+}
+
+func (cmd *SearchConfigCmd) Result() (*SearchConfig, error) {
+	return cmd.Val(), cmd.Err()
+}
+
 /*******************************************************************************
 *
 * SynDumpCmd
@@ -324,6 +410,7 @@ func (cmd *ConfigGetCmd) Result() (map[string]string, error) {
 
 type SynonymDumpCmd struct {
 	redis.Cmd
+	parseOnce
 	val map[string][]string
 }
 
@@ -336,6 +423,10 @@ func NewSynonymDumpCmd(ctx context.Context, args ...interface{}) *SynonymDumpCmd
 }
 
 func (cmd *SynonymDumpCmd) postProcess() error {
+	if cmd.Err() != nil {
+		return cmd.Err()
+	}
+
 	r := cmd.Cmd.Val()
 	synonymMap := make(map[string][]string)
 
@@ -386,6 +477,7 @@ func (cmd *SynonymDumpCmd) Result() (map[string][]string, error) {
 
 type InfoCmd struct {
 	redis.Cmd
+	parseOnce
 	val *Info
 }
 
@@ -409,6 +501,10 @@ func (cmd *InfoCmd) Result() (*Info, error) {
 
 func (cmd *InfoCmd) postProcess() error {
 
+	if cmd.Err() != nil {
+		return cmd.Err()
+	}
+
 	rawResult := cmd.Cmd.Val()
 	var mapped map[interface{}]interface{}
 
@@ -437,6 +533,7 @@ func (cmd *InfoCmd) postProcess() error {
 
 type IntSlicePointerCmd struct {
 	redis.SliceCmd
+	parseOnce
 	val []*int64
 }
 
@@ -495,9 +592,15 @@ func (cmd *IntSlicePointerCmd) Result() ([]*int64, error) {
 
 type AggregateCmd struct {
 	redis.Cmd
+	parseOnce
 	respData     *RESPData
 	val          []map[string]interface{}
 	totalResults int64
+	cursorId     int64
+	options      *AggregateOptions
+	index        string  // used to read from the cursor
+	process      cmdable // used to read from the cursor
+	rewritten    bool    // set once query rewriters have been run
 }
 
 func NewAggregateCmd(ctx context.Context, args ...interface{}) *AggregateCmd {
@@ -516,6 +619,15 @@ func (cmd *AggregateCmd) postProcess() error {
 	rawResults := cmd.Cmd.Val()
 	results := make([]map[string]interface{}, 0)
 
+	// Responses from WITHCURSOR and FT.CURSOR READ are wrapped with the cursor id
+	if r, ok := rawResults.([]interface{}); ok && len(r) == 2 {
+		switch r[0].(type) {
+		case []interface{}, map[interface{}]interface{}:
+			cmd.cursorId, _ = internal.Int64(r[1])
+			rawResults = r[0]
+		}
+	}
+
 	// RESP2 v RESP3
 	switch r := rawResults.(type) {
 	case []interface{}:
@@ -532,12 +644,11 @@ func (cmd *AggregateCmd) postProcess() error {
 		// ignore the total_results field - it's meaningless
 
 		respData.Format = r["format"].(string)
-		if w, ok := r["error"]; ok {
-			respData.Warnings = w.([]interface{})
+		if w, ok := r["warning"].([]interface{}); ok {
+			respData.Warnings = w
 		}
-		if e, ok := r["error"]; ok {
-			respData.Warnings = r["warning"].([]interface{})
-			respData.Errors = e.([]interface{})
+		if e, ok := r["error"].([]interface{}); ok {
+			respData.Errors = e
 		}
 		for _, data := range r["results"].([]interface{}) {
 			values := data.(map[interface{}]interface{})["extra_attributes"].(map[interface{}]interface{})
@@ -567,6 +678,39 @@ func (cmd *AggregateCmd) Result() ([]map[string]interface{}, error) {
 	return cmd.Val(), cmd.Err()
 }
 
+// Scan decodes the rows into dest, which must be a pointer to a slice of structs (or of pointers to
+// structs). Fields are matched to properties using their redis tags, or their names ignoring case
+// if they have none. Values are converted in the same way whether they were read with RESP2 or RESP3:
+// numeric fields accept numbers or strings holding numbers, and slice fields accept the lists returned
+// by reducers such as [ReduceToList] and [ReduceRandomSample] as well as single values.
+func (cmd *AggregateCmd) Scan(dest interface{}) error {
+	if cmd.Err() != nil {
+		return cmd.Err()
+	}
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       internal.NumberHookFunc(),
+		WeaklyTypedInput: true,
+		TagName:          "redis",
+		Result:           dest,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(cmd.Val())
+}
+
+// CursorId returns the id of the cursor used to read further results. It is zero if no cursor was
+// requested or there are no more results to read.
+func (cmd *AggregateCmd) CursorId() int64 {
+	return cmd.cursorId
+}
+
+// SetCursorId stores the id of the cursor used to read further results.
+func (cmd *AggregateCmd) SetCursorId(id int64) {
+	cmd.cursorId = id
+}
+
 func (cmd *AggregateCmd) SetTotalResults(n int64) {
 	cmd.totalResults = n
 }
@@ -575,6 +719,12 @@ func (cmd *AggregateCmd) TotalResults() int64 {
 	return cmd.totalResults
 }
 
+// Options returns the options used for the aggregate. It is nil for cursor reads and
+// commands created with NewAggregateCmd.
+func (cmd *AggregateCmd) Options() *AggregateOptions {
+	return cmd.options
+}
+
 // RESPData returns the additional data returned with a RESP3 response if set.
 func (cmd *AggregateCmd) RESP3Data() *RESPData {
 	return cmd.respData
@@ -588,4 +738,18 @@ func (cmd *AggregateCmd) SetRESP3Data(data *RESPData) {
 type ExtCmder interface {
 	redis.Cmder
 	postProcess() error
+	markParsed() bool
+}
+
+// parseOnce records whether the reply to a command has been parsed, so that hooks and
+// [Client.Process] don't parse it twice.
+type parseOnce struct {
+	parsed bool
+}
+
+// markParsed marks the command as parsed and returns true if it already was.
+func (p *parseOnce) markParsed() bool {
+	parsed := p.parsed
+	p.parsed = true
+	return parsed
 }
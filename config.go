@@ -0,0 +1,316 @@
+package grsearch
+
+// typed search configuration - FT.CONFIG GET reports every value as a string. SearchConfig
+// decodes the documented settings into typed fields and keeps anything it doesn't recognise
+// (including the underscore-prefixed internal settings) in Extra so that nothing is lost. The
+// server reports "unlimited" for MAXSEARCHRESULTS and MAXAGGREGATERESULTS when no limit is set;
+// this is decoded as -1, which is also the value the server accepts to remove the limit.
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Values accepted for ON_TIMEOUT.
+const (
+	OnTimeoutReturn = "return" // return the results found so far
+	OnTimeoutFail   = "fail"   // return an error
+)
+
+// Values accepted for GC_POLICY.
+const (
+	GCPolicyFork   = "fork"
+	GCPolicyLegacy = "legacy"
+)
+
+// Unlimited is used in MaxSearchResults and MaxAggregateResults to remove the limit.
+const Unlimited int64 = -1
+
+// SearchConfig represents the settings returned by FT.CONFIG GET *. Settings the server
+// doesn't report are left at their zero value.
+type SearchConfig struct {
+	MaxSearchResults            int64         // MAXSEARCHRESULTS, Unlimited if there is no limit
+	MaxAggregateResults         int64         // MAXAGGREGATERESULTS, Unlimited if there is no limit
+	Timeout                     time.Duration // TIMEOUT, zero if queries never time out
+	OnTimeout                   string        // ON_TIMEOUT, OnTimeoutReturn or OnTimeoutFail
+	MinPrefix                   int64         // MINPREFIX
+	MaxExpansions               int64         // MAXEXPANSIONS (also reported as MAXPREFIXEXPANSIONS)
+	MaxDocTableSize             int64         // MAXDOCTABLESIZE
+	MinPhoneticTermLen          int64         // MIN_PHONETIC_TERM_LEN
+	DefaultDialect              int64         // DEFAULT_DIALECT
+	MultiTextSlop               int64         // MULTI_TEXT_SLOP
+	UnionIteratorHeap           int64         // UNION_ITERATOR_HEAP
+	CursorMaxIdle               time.Duration // CURSOR_MAX_IDLE
+	CursorReadSize              int64         // CURSOR_READ_SIZE
+	NoGC                        bool          // NOGC
+	GCPolicy                    string        // GC_POLICY, GCPolicyFork or GCPolicyLegacy
+	GCScanSize                  int64         // GCSCANSIZE
+	ForkGCRunInterval           time.Duration // FORK_GC_RUN_INTERVAL
+	ForkGCRetryInterval         time.Duration // FORK_GC_RETRY_INTERVAL
+	ForkGCCleanThreshold        int64         // FORK_GC_CLEAN_THRESHOLD
+	ForkGCSleepBeforeExit       time.Duration // FORK_GC_SLEEP_BEFORE_EXIT
+	ForkGCCleanNumericEmptyNode bool          // FORK_GC_CLEAN_NUMERIC_EMPTY_NODES
+	WorkerThreads               int64         // WORKER_THREADS
+	ExtLoad                     string        // EXTLOAD
+	FrisoIni                    string        // FRISOINI
+	Extra                       map[string]string
+}
+
+// configKind describes how a setting is converted to and from a string.
+type configKind int
+
+const (
+	configInt configKind = iota
+	configLimit
+	configMillis
+	configSeconds
+	configBool
+	configString
+)
+
+// configSetting maps a setting name to a field in SearchConfig.
+type configSetting struct {
+	name    string
+	kind    configKind
+	allowed []string // allowed values for enumerated string settings
+	field   func(*SearchConfig) interface{}
+}
+
+var configSettings = []configSetting{
+	{name: "MAXSEARCHRESULTS", kind: configLimit, field: func(c *SearchConfig) interface{} { return &c.MaxSearchResults }},
+	{name: "MAXAGGREGATERESULTS", kind: configLimit, field: func(c *SearchConfig) interface{} { return &c.MaxAggregateResults }},
+	{name: "TIMEOUT", kind: configMillis, field: func(c *SearchConfig) interface{} { return &c.Timeout }},
+	{name: "ON_TIMEOUT", kind: configString, allowed: []string{OnTimeoutReturn, OnTimeoutFail}, field: func(c *SearchConfig) interface{} { return &c.OnTimeout }},
+	{name: "MINPREFIX", kind: configInt, field: func(c *SearchConfig) interface{} { return &c.MinPrefix }},
+	{name: "MAXEXPANSIONS", kind: configInt, field: func(c *SearchConfig) interface{} { return &c.MaxExpansions }},
+	{name: "MAXDOCTABLESIZE", kind: configInt, field: func(c *SearchConfig) interface{} { return &c.MaxDocTableSize }},
+	{name: "MIN_PHONETIC_TERM_LEN", kind: configInt, field: func(c *SearchConfig) interface{} { return &c.MinPhoneticTermLen }},
+	{name: "DEFAULT_DIALECT", kind: configInt, field: func(c *SearchConfig) interface{} { return &c.DefaultDialect }},
+	{name: "MULTI_TEXT_SLOP", kind: configInt, field: func(c *SearchConfig) interface{} { return &c.MultiTextSlop }},
+	{name: "UNION_ITERATOR_HEAP", kind: configInt, field: func(c *SearchConfig) interface{} { return &c.UnionIteratorHeap }},
+	{name: "CURSOR_MAX_IDLE", kind: configMillis, field: func(c *SearchConfig) interface{} { return &c.CursorMaxIdle }},
+	{name: "CURSOR_READ_SIZE", kind: configInt, field: func(c *SearchConfig) interface{} { return &c.CursorReadSize }},
+	{name: "NOGC", kind: configBool, field: func(c *SearchConfig) interface{} { return &c.NoGC }},
+	{name: "GC_POLICY", kind: configString, allowed: []string{GCPolicyFork, GCPolicyLegacy}, field: func(c *SearchConfig) interface{} { return &c.GCPolicy }},
+	{name: "GCSCANSIZE", kind: configInt, field: func(c *SearchConfig) interface{} { return &c.GCScanSize }},
+	{name: "FORK_GC_RUN_INTERVAL", kind: configSeconds, field: func(c *SearchConfig) interface{} { return &c.ForkGCRunInterval }},
+	{name: "FORK_GC_RETRY_INTERVAL", kind: configSeconds, field: func(c *SearchConfig) interface{} { return &c.ForkGCRetryInterval }},
+	{name: "FORK_GC_CLEAN_THRESHOLD", kind: configInt, field: func(c *SearchConfig) interface{} { return &c.ForkGCCleanThreshold }},
+	{name: "FORK_GC_SLEEP_BEFORE_EXIT", kind: configSeconds, field: func(c *SearchConfig) interface{} { return &c.ForkGCSleepBeforeExit }},
+	{name: "FORK_GC_CLEAN_NUMERIC_EMPTY_NODES", kind: configBool, field: func(c *SearchConfig) interface{} { return &c.ForkGCCleanNumericEmptyNode }},
+	{name: "WORKER_THREADS", kind: configInt, field: func(c *SearchConfig) interface{} { return &c.WorkerThreads }},
+	{name: "EXTLOAD", kind: configString, field: func(c *SearchConfig) interface{} { return &c.ExtLoad }},
+	{name: "FRISOINI", kind: configString, field: func(c *SearchConfig) interface{} { return &c.FrisoIni }},
+}
+
+// configAliases are alternative names reported for the same setting.
+var configAliases = map[string]string{
+	"MAXPREFIXEXPANSIONS": "MAXEXPANSIONS",
+}
+
+func lookupConfigSetting(name string) (configSetting, bool) {
+	name = strings.ToUpper(name)
+	if alias, ok := configAliases[name]; ok {
+		name = alias
+	}
+	for _, s := range configSettings {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return configSetting{}, false
+}
+
+// decode parses value and stores it in the field for the setting.
+func (s configSetting) decode(config *SearchConfig, value string) error {
+	var err error
+	switch field := s.field(config).(type) {
+	case *int64:
+		if s.kind == configLimit && strings.EqualFold(value, "unlimited") {
+			*field = Unlimited
+		} else {
+			*field, err = strconv.ParseInt(value, 10, 64)
+		}
+	case *time.Duration:
+		var n int64
+		if n, err = strconv.ParseInt(value, 10, 64); err == nil {
+			if s.kind == configSeconds {
+				*field = time.Duration(n) * time.Second
+			} else {
+				*field = time.Duration(n) * time.Millisecond
+			}
+		}
+	case *bool:
+		*field, err = parseConfigBool(value)
+	case *string:
+		if s.allowed != nil {
+			value = strings.ToLower(value)
+		}
+		*field = value
+	}
+	if err != nil {
+		return fmt.Errorf("grsearch: invalid value %q for %s: %w", value, s.name, err)
+	}
+	return nil
+}
+
+// encode returns the value of the setting in the form accepted by FT.CONFIG SET.
+func (s configSetting) encode(config *SearchConfig) string {
+	switch field := s.field(config).(type) {
+	case *int64:
+		return strconv.FormatInt(*field, 10)
+	case *time.Duration:
+		if s.kind == configSeconds {
+			return strconv.FormatInt(int64(*field/time.Second), 10)
+		}
+		return strconv.FormatInt(field.Milliseconds(), 10)
+	case *bool:
+		if *field {
+			return "true"
+		}
+		return "false"
+	case *string:
+		return *field
+	}
+	return ""
+}
+
+// validate checks that the value of the setting is one the server will accept.
+func (s configSetting) validate(config *SearchConfig) error {
+	value := s.encode(config)
+	if s.allowed != nil && value != "" {
+		for _, allowed := range s.allowed {
+			if strings.EqualFold(value, allowed) {
+				return nil
+			}
+		}
+		return fmt.Errorf("grsearch: invalid value %q for %s, expected one of %s", value, s.name, strings.Join(s.allowed, ", "))
+	}
+
+	switch field := s.field(config).(type) {
+	case *int64:
+		if *field < 0 && !(s.kind == configLimit && *field == Unlimited) {
+			return fmt.Errorf("grsearch: %s cannot be negative", s.name)
+		}
+	case *time.Duration:
+		if *field < 0 {
+			return fmt.Errorf("grsearch: %s cannot be negative", s.name)
+		}
+	}
+	return nil
+}
+
+func parseConfigBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "on", "yes", "1":
+		return true, nil
+	case "false", "off", "no", "0", "":
+		return false, nil
+	}
+	return false, fmt.Errorf("not a boolean")
+}
+
+// DecodeSearchConfig builds a SearchConfig from the values returned by [Client.FTConfigGet].
+// Settings that aren't fields of SearchConfig are copied to Extra.
+func DecodeSearchConfig(values map[string]string) (*SearchConfig, error) {
+	config := &SearchConfig{Extra: map[string]string{}}
+	for name, value := range values {
+		if setting, ok := lookupConfigSetting(name); ok {
+			if err := setting.decode(config, value); err != nil {
+				return nil, err
+			}
+		} else {
+			config.Extra[name] = value
+		}
+	}
+	return config, nil
+}
+
+// Encode returns the settings in the form accepted by [Client.FTConfigSet], including
+// those held in Extra.
+func (c *SearchConfig) Encode() map[string]string {
+	values := make(map[string]string, len(configSettings)+len(c.Extra))
+	for name, value := range c.Extra {
+		values[name] = value
+	}
+	for _, setting := range configSettings {
+		values[setting.name] = setting.encode(c)
+	}
+	return values
+}
+
+// Validate checks that enumerated settings have one of their allowed values and that
+// numeric settings are not negative.
+func (c *SearchConfig) Validate() error {
+	for _, setting := range configSettings {
+		if err := setting.validate(c); err != nil {
+			return err
+		}
+	}
+	if c.DefaultDialect != 0 && (c.DefaultDialect < 1 || c.DefaultDialect > 4) {
+		return fmt.Errorf("grsearch: invalid value %d for DEFAULT_DIALECT, expected 1 to 4", c.DefaultDialect)
+	}
+	return nil
+}
+
+// FTConfigApply reads the config from the server and passes a copy of it to update. Only the
+// settings which update changes are sent to the server, so settings it leaves alone keep their
+// current values. The names of the settings changed are returned, along with those changed
+// before any error.
+func (c *Client) FTConfigApply(ctx context.Context, update func(config *SearchConfig) error) ([]string, error) {
+	cmd := c.FTConfigGetAll(ctx)
+	if err := cmd.Err(); err != nil {
+		return nil, err
+	}
+
+	current := cmd.Val()
+	config := current.clone()
+	if err := update(config); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	before, wanted := current.Encode(), config.Encode()
+	names := make([]string, 0, len(wanted))
+	for name, value := range wanted {
+		if existing, ok := before[name]; !ok || existing != value {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	reported := cmd.ConfigGetCmd.Val()
+	changed := []string{}
+	for _, name := range names {
+		serverName := name
+		if _, ok := reported[name]; !ok {
+			for alias, canonical := range configAliases {
+				if _, ok := reported[alias]; ok && canonical == name {
+					serverName = alias
+				}
+			}
+		}
+		if err := c.FTConfigSet(ctx, serverName, wanted[name]).Err(); err != nil {
+			return changed, fmt.Errorf("grsearch: unable to set %s: %w", serverName, err)
+		}
+		changed = append(changed, serverName)
+	}
+
+	return changed, nil
+}
+
+// clone copies the config so that it can be changed without affecting the original.
+func (c *SearchConfig) clone() *SearchConfig {
+	config := *c
+	config.Extra = make(map[string]string, len(c.Extra))
+	for name, value := range c.Extra {
+		config.Extra[name] = value
+	}
+	return &config
+}
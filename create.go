@@ -15,6 +15,7 @@ type IndexOptions struct {
 	LanguageField   string
 	Score           float64
 	ScoreField      string
+	PayloadField    string
 	MaxTextFields   bool
 	NoOffsets       bool
 	Temporary       uint64 // If this is a temporary index, number of seconds until expiry
@@ -27,61 +28,68 @@ type IndexOptions struct {
 	Schema          []SchemaAttribute
 }
 
+// TagAttribute defines a TAG attribute. The struct tags give the keys used
+// in index definition files (see [IndexOptions.MarshalJSON]).
 type TagAttribute struct {
-	Name           string
-	Alias          string
-	Sortable       bool
-	UnNormalized   bool
-	Separator      string
-	CaseSensitive  bool
-	WithSuffixTrie bool
-	NoIndex        bool
+	Name           string `json:"name" yaml:"name"`
+	Alias          string `json:"alias,omitempty" yaml:"alias,omitempty"`
+	Sortable       bool   `json:"sortable,omitempty" yaml:"sortable,omitempty"`
+	UnNormalized   bool   `json:"unf,omitempty" yaml:"unf,omitempty"`
+	Separator      string `json:"separator,omitempty" yaml:"separator,omitempty"`
+	CaseSensitive  bool   `json:"case_sensitive,omitempty" yaml:"case_sensitive,omitempty"`
+	WithSuffixTrie bool   `json:"with_suffix_trie,omitempty" yaml:"with_suffix_trie,omitempty"`
+	NoIndex        bool   `json:"no_index,omitempty" yaml:"no_index,omitempty"`
 }
 
+// TextAttribute defines a TEXT attribute.
 type TextAttribute struct {
-	Name           string
-	Alias          string
-	Sortable       bool
-	UnNormalized   bool
-	Phonetic       string
-	Weight         float64
-	NoStem         bool
-	WithSuffixTrie bool
-	NoIndex        bool
+	Name           string  `json:"name" yaml:"name"`
+	Alias          string  `json:"alias,omitempty" yaml:"alias,omitempty"`
+	Sortable       bool    `json:"sortable,omitempty" yaml:"sortable,omitempty"`
+	UnNormalized   bool    `json:"unf,omitempty" yaml:"unf,omitempty"`
+	Phonetic       string  `json:"phonetic,omitempty" yaml:"phonetic,omitempty"`
+	Weight         float64 `json:"weight,omitempty" yaml:"weight,omitempty"`
+	NoStem         bool    `json:"no_stem,omitempty" yaml:"no_stem,omitempty"`
+	WithSuffixTrie bool    `json:"with_suffix_trie,omitempty" yaml:"with_suffix_trie,omitempty"`
+	NoIndex        bool    `json:"no_index,omitempty" yaml:"no_index,omitempty"`
 }
 
+// NumericAttribute defines a NUMERIC attribute.
 type NumericAttribute struct {
-	Name     string
-	Alias    string
-	Sortable bool
-	NoIndex  bool
+	Name     string `json:"name" yaml:"name"`
+	Alias    string `json:"alias,omitempty" yaml:"alias,omitempty"`
+	Sortable bool   `json:"sortable,omitempty" yaml:"sortable,omitempty"`
+	NoIndex  bool   `json:"no_index,omitempty" yaml:"no_index,omitempty"`
 }
 
+// GeoAttribute defines a GEO attribute.
 type GeoAttribute struct {
-	Name     string
-	Alias    string
-	Sortable bool
-	NoIndex  bool
+	Name     string `json:"name" yaml:"name"`
+	Alias    string `json:"alias,omitempty" yaml:"alias,omitempty"`
+	Sortable bool   `json:"sortable,omitempty" yaml:"sortable,omitempty"`
+	NoIndex  bool   `json:"no_index,omitempty" yaml:"no_index,omitempty"`
 }
 
+// VectorAttribute defines a VECTOR attribute. Type is the element type (FLOAT32 or FLOAT64).
 type VectorAttribute struct {
-	Name           string
-	Alias          string
-	Algorithm      string
-	Type           string
-	Dim            uint64
-	DistanceMetric string
-	InitialCap     uint64
-	BlockSize      uint64
-	M              uint64
-	EFConstruction uint64
-	EFRuntime      uint64
-	Epsilon        float64
+	Name           string  `json:"name" yaml:"name"`
+	Alias          string  `json:"alias,omitempty" yaml:"alias,omitempty"`
+	Algorithm      string  `json:"algorithm" yaml:"algorithm"`
+	Type           string  `json:"vector_type" yaml:"vector_type"`
+	Dim            uint64  `json:"dim" yaml:"dim"`
+	DistanceMetric string  `json:"distance_metric" yaml:"distance_metric"`
+	InitialCap     uint64  `json:"initial_cap,omitempty" yaml:"initial_cap,omitempty"`
+	BlockSize      uint64  `json:"block_size,omitempty" yaml:"block_size,omitempty"`
+	M              uint64  `json:"m,omitempty" yaml:"m,omitempty"`
+	EFConstruction uint64  `json:"ef_construction,omitempty" yaml:"ef_construction,omitempty"`
+	EFRuntime      uint64  `json:"ef_runtime,omitempty" yaml:"ef_runtime,omitempty"`
+	Epsilon        float64 `json:"epsilon,omitempty" yaml:"epsilon,omitempty"`
 }
 
+// GeometryAttribute defines a GEOMETRY attribute.
 type GeometryAttribute struct {
-	Name  string
-	Alias string
+	Name  string `json:"name" yaml:"name"`
+	Alias string `json:"alias,omitempty" yaml:"alias,omitempty"`
 }
 
 type SchemaAttribute interface {
@@ -122,6 +130,10 @@ func (i *IndexOptions) serialize() []interface{} {
 		args = append(args, "SCORE_FIELD", i.ScoreField)
 	}
 
+	if i.PayloadField != "" {
+		args = append(args, "PAYLOAD_FIELD", i.PayloadField)
+	}
+
 	if i.MaxTextFields {
 		args = append(args, "MAXTEXTFIELDS")
 	}
@@ -204,6 +216,9 @@ func (a *TagAttribute) serialize() []interface{} {
 	if a.CaseSensitive {
 		attribs = append(attribs, "CASESENSITIVE")
 	}
+	if a.WithSuffixTrie {
+		attribs = append(attribs, "WITHSUFFIXTRIE")
+	}
 	if a.NoIndex {
 		attribs = append(attribs, "NOINDEX")
 	}
@@ -236,6 +251,9 @@ func (a *TextAttribute) serialize() []interface{} {
 	if a.NoStem {
 		attribs = append(attribs, "NOSTEM")
 	}
+	if a.WithSuffixTrie {
+		attribs = append(attribs, "WITHSUFFIXTRIE")
+	}
 
 	if a.NoIndex {
 		attribs = append(attribs, "NOINDEX")
@@ -285,10 +303,10 @@ func (a *VectorAttribute) serialize() []interface{} {
 	if a.InitialCap != 0 {
 		params = append(params, "INITIAL_CAP", a.InitialCap)
 	}
-	if strings.ToLower(a.Algorithm) == "FLAT" && a.BlockSize != 0 {
+	if strings.EqualFold(a.Algorithm, "FLAT") && a.BlockSize != 0 {
 		params = append(params, "BLOCK_SIZE", a.BlockSize)
 	}
-	if strings.ToLower(a.Algorithm) == "HNSW" {
+	if strings.EqualFold(a.Algorithm, "HNSW") {
 		if a.M != 0 {
 			params = append(params, "M", a.M)
 		}
@@ -307,3 +325,23 @@ func (a *VectorAttribute) serialize() []interface{} {
 
 	return attribs
 }
+
+// attributeIdentity returns the name, alias and type (as used in FT.CREATE) of a schema attribute.
+func attributeIdentity(a SchemaAttribute) (name, alias, attribType string) {
+	switch v := a.(type) {
+	case *TagAttribute:
+		return v.Name, v.Alias, "TAG"
+	case *TextAttribute:
+		return v.Name, v.Alias, "TEXT"
+	case *NumericAttribute:
+		return v.Name, v.Alias, "NUMERIC"
+	case *GeoAttribute:
+		return v.Name, v.Alias, "GEO"
+	case *GeometryAttribute:
+		return v.Name, v.Alias, "GEOMETRY"
+	case *VectorAttribute:
+		return v.Name, v.Alias, "VECTOR"
+	default:
+		return "", "", ""
+	}
+}
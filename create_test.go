@@ -37,3 +37,79 @@ var _ = Describe("Create", Label("json", "query", "ft.create"), func() {
 	})
 
 })
+
+var _ = Describe("Index definitions from FT.INFO", Label("hash", "ft.create", "ft.info"), func() {
+
+	It("recovers the index options and attributes", func() {
+		options := grsearch.NewIndexOptions()
+		options.Prefix = []string{"hfull:"}
+		options.Filter = "@balance > 0"
+		options.Language = "german"
+		options.LanguageField = "lang"
+		options.ScoreField = "doc_score"
+		options.PayloadField = "doc_payload"
+		options.NoFreqs = true
+		options.UseStopWords = true
+		options.StopWords = []string{"foo", "bar"}
+		options.Schema = []grsearch.SchemaAttribute{
+			&grsearch.TagAttribute{Name: "tags", Separator: ";", CaseSensitive: true, WithSuffixTrie: true},
+			&grsearch.TextAttribute{Name: "description", Weight: 2, NoStem: true, WithSuffixTrie: true, Phonetic: "dm:en"},
+			&grsearch.NumericAttribute{Name: "balance", Sortable: true},
+			&grsearch.VectorAttribute{Name: "embedding", Algorithm: "FLAT", Type: "FLOAT32", Dim: 4, DistanceMetric: "COSINE"},
+		}
+		Expect(client.FTCreate(ctx, "hfull", options).Err()).NotTo(HaveOccurred())
+		DeferCleanup(func() {
+			client.FTDropIndex(ctx, "hfull", false)
+		})
+
+		info, err := client.FTInfo(ctx, "hfull").Result()
+		Expect(err).NotTo(HaveOccurred())
+		index := info.Index
+		Expect(index.On).To(Equal("HASH"))
+		Expect(index.Prefix).To(Equal([]string{"hfull:"}))
+		Expect(index.Filter).To(Equal("@balance > 0"))
+		Expect(index.Language).To(Equal("german"))
+		Expect(index.LanguageField).To(Equal("lang"))
+		Expect(index.ScoreField).To(Equal("doc_score"))
+		Expect(index.PayloadField).To(Equal("doc_payload"))
+		Expect(index.NoFreqs).To(BeTrue())
+		Expect(index.NoOffsets).To(BeFalse())
+		Expect(index.UseStopWords).To(BeTrue())
+		Expect(index.StopWords).To(ConsistOf("foo", "bar"))
+
+		Expect(index.Schema).To(HaveLen(4))
+		Expect(index.Schema[0]).To(Equal(&grsearch.TagAttribute{Name: "tags", Alias: "tags", Separator: ";", CaseSensitive: true, WithSuffixTrie: true}))
+		text := index.Schema[1].(*grsearch.TextAttribute)
+		Expect(text.Weight).To(Equal(2.0))
+		Expect(text.NoStem).To(BeTrue())
+		Expect(text.WithSuffixTrie).To(BeTrue())
+		Expect(text.Phonetic).To(Equal("dm:en"))
+		vector := index.Schema[3].(*grsearch.VectorAttribute)
+		Expect(vector.Algorithm).To(Equal("FLAT"))
+		Expect(vector.Type).To(Equal("FLOAT32"))
+		Expect(vector.Dim).To(Equal(uint64(4)))
+		Expect(vector.DistanceMetric).To(Equal("COSINE"))
+
+		Expect(client.FTCreate(ctx, "hfullcopy", index).Err()).NotTo(HaveOccurred())
+		DeferCleanup(func() {
+			client.FTDropIndex(ctx, "hfullcopy", false)
+		})
+		copied, err := client.FTInfo(ctx, "hfullcopy").Result()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(copied.Index).To(Equal(index))
+	})
+
+	It("serializes suffix tries", func() {
+		options := grsearch.NewIndexOptions()
+		options.Schema = []grsearch.SchemaAttribute{
+			&grsearch.TextAttribute{Name: "a", WithSuffixTrie: true},
+			&grsearch.TagAttribute{Name: "b", WithSuffixTrie: true},
+		}
+		cmd := client.FTCreate(ctx, "hsuffix", options)
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		DeferCleanup(func() {
+			client.FTDropIndex(ctx, "hsuffix", false)
+		})
+		Expect(cmd.String()).To(Equal("FT.CREATE hsuffix ON HASH SCORE 1 SCHEMA a TEXT WITHSUFFIXTRIE b TAG WITHSUFFIXTRIE: true"))
+	})
+})
@@ -0,0 +1,595 @@
+package grsearch
+
+// exporting - search results and aggregate rows are read through the ExportRows interface and each
+// value is converted to a Go type based on the column type: NUMERIC values become float64, TAG values
+// []string, GEO values GeoPoint and VECTOR values []float64 (decoded from the binary form used in
+// hashes). Columns can be inferred from the RETURN fields of a query, the steps of an aggregate or
+// the index schema (read with FT.INFO). Writers for CSV and NDJSON are provided here, Arrow IPC
+// streams are written by the arrowexport package so that the dependency is optional.
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/goslogan/grsearch/internal"
+)
+
+const (
+	ExportKeyColumn = "__key" // name of the column holding the key of search results
+	ExportListType  = "LIST"  // column type for lists produced by TOLIST and RANDOM_SAMPLE
+)
+
+// ExportColumn describes a single column in an export.
+type ExportColumn struct {
+	Name       string // Column name in the output
+	Field      string // The name of the value in the search result or aggregate row
+	Path       string // JSON path used to find the value in a JSON document if Field is not returned
+	Type       string // Attribute type (TEXT, TAG, NUMERIC, GEO, GEOMETRY, VECTOR) or LIST
+	Separator  string // TAG separator
+	VectorType string // VECTOR element type (FLOAT32 or FLOAT64)
+}
+
+// GeoPoint is the exported form of a GEO value.
+type GeoPoint struct {
+	Lon float64 `json:"lon"`
+	Lat float64 `json:"lat"`
+}
+
+// ExportRows is implemented by the sources of an export. Close releases anything held by
+// the source (an aggregate cursor or prefetched pages) if the export stops early.
+type ExportRows interface {
+	Next(ctx context.Context) bool
+	Row() map[string]interface{}
+	Err() error
+	Close()
+}
+
+// ResultIterator is implemented by [SearchIterator], [SearchAfterIterator] and [PrefetchIterator].
+type ResultIterator interface {
+	Next(ctx context.Context) bool
+	Val() *SearchResult
+	Err() error
+}
+
+type searchRows struct {
+	it ResultIterator
+}
+
+type aggregateRows struct {
+	rows *cursorRows
+}
+
+/******************************************************************************
+* Column inference
+******************************************************************************/
+
+// InferSearchColumns returns the export columns for a search on the index. If the query options
+// contain RETURN fields these are used, otherwise every attribute in the schema is exported. The
+// first column always holds the key.
+func (c *Client) InferSearchColumns(ctx context.Context, index string, options *QueryOptions) ([]ExportColumn, error) {
+	info, err := c.FTInfo(ctx, index).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	onJSON := strings.EqualFold(info.Index.On, "json")
+	attributes := schemaLookup(info.Index)
+	columns := []ExportColumn{{Name: ExportKeyColumn, Field: ExportKeyColumn, Type: "TEXT"}}
+
+	if options != nil && len(options.Return) > 0 {
+		for _, r := range options.Return {
+			name := r.As
+			if name == "" {
+				name = r.Name
+			}
+			column := exportColumn(attributes[r.Name], name)
+			column.Field = name
+			columns = append(columns, column)
+		}
+		return columns, nil
+	}
+
+	for _, a := range info.Index.Schema {
+		name, alias, _ := attributeIdentity(a)
+		if alias == "" {
+			alias = name
+		}
+		column := exportColumn(a, alias)
+		column.Field = alias
+		if onJSON {
+			column.Path = name
+		}
+		columns = append(columns, column)
+	}
+
+	return columns, nil
+}
+
+// InferAggregateColumns returns the export columns for an aggregate on the index by following
+// its LOAD, GROUPBY and APPLY steps. The types of loaded and grouped properties are taken from
+// the schema, reducers are numeric except for lists and FIRST_VALUE, and APPLY expressions are
+// assumed to be TEXT.
+func (c *Client) InferAggregateColumns(ctx context.Context, index string, options *AggregateOptions) ([]ExportColumn, error) {
+	info, err := c.FTInfo(ctx, index).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := schemaLookup(info.Index)
+	columns := []ExportColumn{}
+	known := map[string]ExportColumn{}
+
+	add := func(column ExportColumn) {
+		known[column.Name] = column
+		for n, existing := range columns {
+			if existing.Name == column.Name {
+				columns[n] = column
+				return
+			}
+		}
+		columns = append(columns, column)
+	}
+
+	property := func(name string) ExportColumn {
+		name = strings.TrimPrefix(name, "@")
+		if column, ok := known[name]; ok {
+			return column
+		}
+		column := exportColumn(attributes[name], name)
+		column.Field = name
+		return column
+	}
+
+	if options == nil {
+		options = NewAggregateOptions()
+	}
+
+	load := func(loads []AggregateLoad) {
+		for _, l := range loads {
+			if l.Name == "*" {
+				for _, a := range info.Index.Schema {
+					name, alias, _ := attributeIdentity(a)
+					if alias == "" {
+						alias = name
+					}
+					add(property(alias))
+				}
+				continue
+			}
+			column := property(l.Name)
+			if l.As != "" {
+				column.Name, column.Field = l.As, l.As
+			}
+			add(column)
+		}
+	}
+
+	load(options.Load)
+	for _, step := range options.Steps {
+		switch s := step.(type) {
+		case *AggregateLoadStep:
+			load(s.Load)
+		case *AggregateGroupBy:
+			grouped := []ExportColumn{}
+			for _, p := range s.Properties {
+				grouped = append(grouped, property(p))
+			}
+			columns = columns[:0]
+			for _, column := range grouped {
+				add(column)
+			}
+			for _, r := range s.Reducers {
+				add(reducerColumn(r, property))
+			}
+		case *AggregateApply:
+			add(ExportColumn{Name: s.As, Field: s.As, Type: "TEXT"})
+		case *AggregateSort:
+			for n, k := range s.Keys {
+				if k.Expression != "" {
+					add(ExportColumn{Name: k.property(n), Field: k.property(n), Type: "TEXT"})
+				}
+			}
+		}
+	}
+
+	return columns, nil
+}
+
+// schemaLookup maps attribute names and aliases to attributes.
+func schemaLookup(index *IndexOptions) map[string]SchemaAttribute {
+	attributes := map[string]SchemaAttribute{}
+	for _, a := range index.Schema {
+		name, alias, _ := attributeIdentity(a)
+		attributes[name] = a
+		if alias != "" {
+			attributes[alias] = a
+		}
+	}
+	return attributes
+}
+
+// exportColumn creates a column for an attribute (which may be nil).
+func exportColumn(a SchemaAttribute, name string) ExportColumn {
+	column := ExportColumn{Name: name, Field: name, Type: "TEXT"}
+	if a == nil {
+		return column
+	}
+	_, _, column.Type = attributeIdentity(a)
+	switch v := a.(type) {
+	case *TagAttribute:
+		column.Separator = v.Separator
+	case *VectorAttribute:
+		column.VectorType = strings.ToUpper(v.Type)
+	}
+	return column
+}
+
+// reducerColumn works out the type of the value produced by a reducer.
+func reducerColumn(r AggregateReducer, property func(string) ExportColumn) ExportColumn {
+	name := r.As
+	if name == "" {
+		name = "__generated_alias" + strings.ToLower(r.Name)
+		for _, arg := range r.Args {
+			name += strings.ToLower(strings.TrimPrefix(fmt.Sprint(arg), "@"))
+		}
+	}
+
+	column := ExportColumn{Name: name, Field: name, Type: "NUMERIC"}
+	switch strings.ToLower(r.Name) {
+	case "tolist", "random_sample":
+		column.Type = ExportListType
+	case "first_value":
+		column.Type = "TEXT"
+		if len(r.Args) > 0 {
+			if p, ok := r.Args[0].(string); ok {
+				column.Type = property(p).Type
+			}
+		}
+	}
+	return column
+}
+
+/******************************************************************************
+* Sources
+******************************************************************************/
+
+// NewSearchExportRows returns export rows reading from a search iterator. The key of each
+// result is stored as [ExportKeyColumn] and the document returned for JSON searches without
+// RETURN is decoded so that values can be found by path.
+func NewSearchExportRows(it ResultIterator) ExportRows {
+	return &searchRows{it: it}
+}
+
+func (r *searchRows) Next(ctx context.Context) bool {
+	return r.it.Next(ctx)
+}
+
+func (r *searchRows) Err() error {
+	return r.it.Err()
+}
+
+func (r *searchRows) Close() {
+	if closer, ok := r.it.(interface{ Close() }); ok {
+		closer.Close()
+	}
+}
+
+func (r *searchRows) Row() map[string]interface{} {
+	result := r.it.Val()
+	row := make(map[string]interface{}, len(result.Values)+1)
+	for k, v := range result.Values {
+		row[k] = v
+	}
+	if doc, ok := result.Values["$"]; ok {
+		var decoded interface{}
+		if json.Unmarshal([]byte(doc), &decoded) == nil {
+			row["$"] = decoded
+		}
+	}
+	row[ExportKeyColumn] = result.Key
+	return row
+}
+
+// NewAggregateExportRows returns export rows reading from an aggregate, reading
+// further rows from its cursor if it has one.
+func NewAggregateExportRows(cmd *AggregateCmd) ExportRows {
+	return &aggregateRows{rows: newCursorRows(cmd)}
+}
+
+func (r *aggregateRows) Next(ctx context.Context) bool {
+	return r.rows.next(ctx)
+}
+
+func (r *aggregateRows) Err() error {
+	return r.rows.err
+}
+
+func (r *aggregateRows) Close() {
+	r.rows.close()
+}
+
+func (r *aggregateRows) Row() map[string]interface{} {
+	return r.rows.row()
+}
+
+/******************************************************************************
+* Value conversion
+******************************************************************************/
+
+// Value finds the value for the column in a row and converts it to the column type.
+// Missing values are returned as nil.
+func (col *ExportColumn) Value(row map[string]interface{}) (interface{}, error) {
+	raw, ok := row[col.Field]
+	if !ok && col.Path != "" {
+		raw, ok = documentValue(row["$"], col.Path)
+	}
+	if !ok || raw == nil {
+		return nil, nil
+	}
+
+	switch strings.ToUpper(col.Type) {
+	case "NUMERIC":
+		raw = unwrapSingle(raw)
+		if s, ok := raw.(string); ok && s == "" {
+			return nil, nil
+		}
+		f, err := internal.Float64(raw)
+		if err != nil {
+			return nil, fmt.Errorf("grsearch: %s: %w", col.Name, err)
+		}
+		return f, nil
+	case "TAG", ExportListType:
+		return col.tagValue(raw), nil
+	case "GEO":
+		return col.geoValue(unwrapSingle(raw))
+	case "VECTOR":
+		return col.vectorValue(raw)
+	default:
+		raw = unwrapSingle(raw)
+		if s, ok := raw.(string); ok {
+			return s, nil
+		}
+		return fmt.Sprint(raw), nil
+	}
+}
+
+func (col *ExportColumn) tagValue(raw interface{}) []string {
+	switch v := raw.(type) {
+	case []interface{}:
+		tags := make([]string, 0, len(v))
+		for _, t := range v {
+			tags = append(tags, col.tagValue(t)...)
+		}
+		return tags
+	case string:
+		if strings.HasPrefix(v, "[") {
+			var list []interface{}
+			if json.Unmarshal([]byte(v), &list) == nil {
+				return col.tagValue(list)
+			}
+		}
+		if col.Type == ExportListType {
+			return []string{v}
+		}
+		separator := col.Separator
+		if separator == "" {
+			separator = ","
+		}
+		tags := []string{}
+		for _, t := range strings.Split(v, separator) {
+			if t = strings.TrimSpace(t); t != "" {
+				tags = append(tags, t)
+			}
+		}
+		return tags
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+func (col *ExportColumn) geoValue(raw interface{}) (interface{}, error) {
+	s, ok := raw.(string)
+	if !ok {
+		return nil, fmt.Errorf("grsearch: %s: %v is not a geo value", col.Name, raw)
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("grsearch: %s: %s is not a geo value", col.Name, s)
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return nil, fmt.Errorf("grsearch: %s: %w", col.Name, err)
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return nil, fmt.Errorf("grsearch: %s: %w", col.Name, err)
+	}
+	return GeoPoint{Lon: lon, Lat: lat}, nil
+}
+
+func (col *ExportColumn) vectorValue(raw interface{}) (interface{}, error) {
+	switch v := raw.(type) {
+	case []interface{}:
+		floats := make([]float64, len(v))
+		for n, f := range v {
+			var err error
+			if floats[n], err = internal.Float64(f); err != nil {
+				return nil, fmt.Errorf("grsearch: %s: %w", col.Name, err)
+			}
+		}
+		return floats, nil
+	case string:
+		var list []interface{}
+		if strings.HasPrefix(v, "[") && json.Unmarshal([]byte(v), &list) == nil {
+			return col.vectorValue(unwrapSingle(list))
+		}
+		size := 4
+		if col.VectorType == "FLOAT64" {
+			size = 8
+		}
+		if len(v)%size != 0 {
+			return nil, fmt.Errorf("grsearch: %s: invalid vector length %d", col.Name, len(v))
+		}
+		floats := make([]float64, len(v)/size)
+		for n := range floats {
+			if size == 8 {
+				floats[n] = math.Float64frombits(binary.LittleEndian.Uint64([]byte(v[n*8 : n*8+8])))
+			} else {
+				floats[n] = float64(math.Float32frombits(binary.LittleEndian.Uint32([]byte(v[n*4 : n*4+4]))))
+			}
+		}
+		return floats, nil
+	default:
+		return nil, fmt.Errorf("grsearch: %s: %v is not a vector", col.Name, raw)
+	}
+}
+
+// unwrapSingle returns the only value of a single element list (as returned by DIALECT 3)
+// or the value itself.
+func unwrapSingle(raw interface{}) interface{} {
+	if s, ok := raw.(string); ok && strings.HasPrefix(s, "[") {
+		var list []interface{}
+		if json.Unmarshal([]byte(s), &list) == nil && len(list) == 1 {
+			return unwrapSingle(list[0])
+		}
+	}
+	if list, ok := raw.([]interface{}); ok && len(list) == 1 {
+		return list[0]
+	}
+	return raw
+}
+
+// documentValue finds the value at a simple dotted path in a decoded JSON document.
+func documentValue(doc interface{}, path string) (interface{}, bool) {
+	if m, ok := doc.(map[string]interface{}); ok {
+		return lookupJSONPath(m, path)
+	}
+	if list, ok := doc.([]interface{}); ok && len(list) == 1 {
+		return documentValue(list[0], path)
+	}
+	return nil, false
+}
+
+/******************************************************************************
+* Writers
+******************************************************************************/
+
+// ExportCSV writes the rows to w as CSV with a header row. Lists are joined with the
+// column separator (or a comma), GEO values are written as lon,lat and vectors as JSON
+// arrays. The number of rows written is returned and rows is closed. Rows written before an
+// error are flushed to w.
+func ExportCSV(ctx context.Context, w io.Writer, columns []ExportColumn, rows ExportRows) (count int64, err error) {
+	defer rows.Close()
+	writer := csv.NewWriter(w)
+	defer func() {
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+	}()
+
+	record := make([]string, len(columns))
+	for n, column := range columns {
+		record[n] = column.Name
+	}
+	if err := writer.Write(record); err != nil {
+		return 0, err
+	}
+
+	for rows.Next(ctx) {
+		row := rows.Row()
+		for n, column := range columns {
+			value, err := column.Value(row)
+			if err != nil {
+				return count, err
+			}
+			record[n] = csvValue(column, value)
+		}
+		if err := writer.Write(record); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, rows.Err()
+}
+
+func csvValue(column ExportColumn, value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []string:
+		separator := column.Separator
+		if separator == "" {
+			separator = ","
+		}
+		return strings.Join(v, separator)
+	case GeoPoint:
+		return strconv.FormatFloat(v.Lon, 'f', -1, 64) + "," + strconv.FormatFloat(v.Lat, 'f', -1, 64)
+	default:
+		js, _ := json.Marshal(v)
+		return string(js)
+	}
+}
+
+// ExportNDJSON writes the rows to w as newline delimited JSON objects with the keys in
+// column order. Missing values are written as null. The number of rows written is returned
+// and rows is closed. Rows written before an error are flushed to w.
+func ExportNDJSON(ctx context.Context, w io.Writer, columns []ExportColumn, rows ExportRows) (count int64, err error) {
+	defer rows.Close()
+	writer := bufio.NewWriter(w)
+	defer func() {
+		if flushErr := writer.Flush(); err == nil {
+			err = flushErr
+		}
+	}()
+
+	names := make([][]byte, len(columns))
+	for n, column := range columns {
+		names[n], _ = json.Marshal(column.Name)
+	}
+
+	// each row is built up first so that a row which fails isn't written in part
+	var line bytes.Buffer
+	for rows.Next(ctx) {
+		row := rows.Row()
+		line.Reset()
+		line.WriteByte('{')
+		for n, column := range columns {
+			value, err := column.Value(row)
+			if err != nil {
+				return count, err
+			}
+			js, err := json.Marshal(value)
+			if err != nil {
+				return count, err
+			}
+			if n > 0 {
+				line.WriteByte(',')
+			}
+			line.Write(names[n])
+			line.WriteByte(':')
+			line.Write(js)
+		}
+		line.WriteString("}\n")
+		if _, err := writer.Write(line.Bytes()); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, rows.Err()
+}
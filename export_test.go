@@ -0,0 +1,185 @@
+package grsearch_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/ipc"
+	grsearch "github.com/goslogan/grsearch"
+	"github.com/goslogan/grsearch/arrowexport"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fixedRows is a fixed set of rows to export.
+type fixedRows struct {
+	rows []map[string]interface{}
+	pos  int
+}
+
+func (r *fixedRows) Next(ctx context.Context) bool {
+	if r.pos >= len(r.rows) {
+		return false
+	}
+	r.pos++
+	return true
+}
+
+func (r *fixedRows) Row() map[string]interface{} {
+	return r.rows[r.pos-1]
+}
+
+func (r *fixedRows) Err() error {
+	return nil
+}
+
+func (r *fixedRows) Close() {}
+
+var _ = Describe("Exporting", Label("export"), func() {
+
+	DescribeTable("writes the rows before one which fails",
+		func(export func(context.Context, io.Writer, []grsearch.ExportColumn, grsearch.ExportRows) (int64, error), expected string) {
+			columns := []grsearch.ExportColumn{{Name: "balance", Field: "balance", Type: "NUMERIC"}}
+			rows := &fixedRows{rows: []map[string]interface{}{
+				{"balance": "1.5"},
+				{"balance": "2"},
+				{"balance": "lots"},
+				{"balance": "3"},
+			}}
+			var out bytes.Buffer
+			count, err := export(ctx, &out, columns, rows)
+			Expect(err).To(MatchError(ContainSubstring("balance")))
+			Expect(count).To(Equal(int64(2)))
+			Expect(out.String()).To(Equal(expected))
+		},
+		Entry("CSV", grsearch.ExportCSV, "balance\n1.5\n2\n"),
+		Entry("NDJSON", grsearch.ExportNDJSON, `{"balance":1.5}`+"\n"+`{"balance":2}`+"\n"),
+	)
+
+	It("infers columns from the index schema", Label("hash"), func() {
+		columns, err := client.InferSearchColumns(ctx, "hcustomers", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(columns).To(HaveLen(7))
+		Expect(columns[0].Name).To(Equal(grsearch.ExportKeyColumn))
+		Expect(columns[1]).To(Equal(grsearch.ExportColumn{Name: "id", Field: "id", Type: "TAG"}))
+		Expect(columns[5]).To(Equal(grsearch.ExportColumn{Name: "balance", Field: "balance", Type: "NUMERIC"}))
+	})
+
+	It("infers columns from RETURN fields", Label("json"), func() {
+		options := grsearch.NewQueryBuilder().
+			Return("$.balance", "balance").
+			Return("$.country", "country").
+			Options()
+		columns, err := client.InferSearchColumns(ctx, "jcustomers", options)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(columns).To(Equal([]grsearch.ExportColumn{
+			{Name: grsearch.ExportKeyColumn, Field: grsearch.ExportKeyColumn, Type: "TEXT"},
+			{Name: "balance", Field: "balance", Type: "NUMERIC"},
+			{Name: "country", Field: "country", Type: "TAG"},
+		}))
+	})
+
+	It("can export search results as CSV", Label("hash", "csv"), func() {
+		options := grsearch.NewQueryBuilder().SortBy("balance").Limit(0, 4).Options()
+		columns, err := client.InferSearchColumns(ctx, "hcustomers", options)
+		Expect(err).NotTo(HaveOccurred())
+		cmd := client.FTSearchHash(ctx, "hcustomers", `@owner:{lara\.croft}`, options)
+		var out bytes.Buffer
+		count, err := grsearch.ExportCSV(ctx, &out, columns, grsearch.NewSearchExportRows(cmd.Iterator(ctx)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(Equal(int64(10)))
+		records, err := csv.NewReader(&out).ReadAll()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(HaveLen(11))
+		Expect(records[0][0]).To(Equal(grsearch.ExportKeyColumn))
+		Expect(records[10][5]).To(Equal("927"))
+	})
+
+	It("can export JSON search results as NDJSON", Label("json", "ndjson"), func() {
+		columns, err := client.InferSearchColumns(ctx, "jcustomers", nil)
+		Expect(err).NotTo(HaveOccurred())
+		cmd := client.FTSearchJSON(ctx, "jcustomers", `@id:{1121175}`, nil)
+		var out bytes.Buffer
+		count, err := grsearch.ExportNDJSON(ctx, &out, columns, grsearch.NewSearchExportRows(cmd.Iterator(ctx)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(Equal(int64(1)))
+		row := map[string]interface{}{}
+		Expect(json.Unmarshal(out.Bytes(), &row)).NotTo(HaveOccurred())
+		Expect(row["balance"]).To(Equal(927.0))
+		Expect(row["owner"]).To(Equal([]interface{}{"lara.croft"}))
+		Expect(row[grsearch.ExportKeyColumn]).To(Equal("jaccount:1121175"))
+	})
+
+	It("can export aggregate rows as NDJSON", Label("ft.aggregate", "ndjson"), func() {
+		options := grsearch.NewAggregateBuilder().
+			GroupBy(grsearch.NewGroupByBuilder().
+				Property("@owner").
+				Reduce(grsearch.ReduceSum("@balance", "total")).
+				Reduce(grsearch.ReduceToList("@country", "countries")).
+				GroupBy()).
+			Cursor(1, 0).
+			Options()
+		columns, err := client.InferAggregateColumns(ctx, "hcustomers", options)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(columns).To(Equal([]grsearch.ExportColumn{
+			{Name: "owner", Field: "owner", Type: "TAG"},
+			{Name: "total", Field: "total", Type: "NUMERIC"},
+			{Name: "countries", Field: "countries", Type: grsearch.ExportListType},
+		}))
+		cmd := client.FTAggregate(ctx, "hcustomers", "*", options)
+		var out bytes.Buffer
+		count, err := grsearch.ExportNDJSON(ctx, &out, columns, grsearch.NewAggregateExportRows(cmd))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(Equal(int64(3)))
+		Expect(strings.Count(out.String(), "\n")).To(Equal(3))
+	})
+
+	It("deletes the aggregate cursor if the export stops early", func() {
+		before := client.FTInfo(ctx, "hcustomers")
+		Expect(before.Err()).NotTo(HaveOccurred())
+
+		options := grsearch.NewAggregateBuilder().
+			Load("customer", "").
+			Cursor(2, 0).
+			Options()
+		cmd := client.FTAggregate(ctx, "hcustomers", "*", options)
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		rows := grsearch.NewAggregateExportRows(cmd)
+		Expect(rows.Next(ctx)).To(BeTrue())
+		Expect(rows.Row()).To(HaveKey("customer"))
+		rows.Close()
+		Expect(rows.Err()).NotTo(HaveOccurred())
+
+		after := client.FTInfo(ctx, "hcustomers")
+		Expect(after.Err()).NotTo(HaveOccurred())
+		Expect(after.Val().CursorStats.IndexTotal).To(Equal(before.Val().CursorStats.IndexTotal))
+	})
+
+	It("can export search results as an Arrow stream", Label("hash", "arrow"), func() {
+		options := grsearch.NewQueryBuilder().Limit(0, 5).Options()
+		columns, err := client.InferSearchColumns(ctx, "hcustomers", nil)
+		Expect(err).NotTo(HaveOccurred())
+		cmd := client.FTSearchHash(ctx, "hcustomers", `*`, options)
+		var out bytes.Buffer
+		count, err := arrowexport.Write(ctx, &out, columns, grsearch.NewSearchExportRows(cmd.Iterator(ctx)), 10)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(Equal(int64(25)))
+
+		reader, err := ipc.NewReader(&out)
+		Expect(err).NotTo(HaveOccurred())
+		defer reader.Release()
+		Expect(reader.Schema().Field(1).Type.String()).To(Equal("list<item: utf8, nullable>"))
+		rows := int64(0)
+		for reader.Next() {
+			record := reader.Record()
+			Expect(record.Column(5)).To(BeAssignableToTypeOf(&array.Float64{}))
+			rows += record.NumRows()
+		}
+		Expect(rows).To(Equal(int64(25)))
+	})
+})
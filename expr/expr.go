@@ -0,0 +1,329 @@
+// Package expr builds expressions for the APPLY and FILTER steps of aggregates from properties,
+// literals, operators and the functions of the aggregation library.
+//
+// Operands of operators are parenthesised unless they are properties, literals or function calls,
+// so expressions are grouped as they were built whatever the server's operator precedence.
+// Functions are checked against the number of arguments they take; the first problem found is
+// kept in the expression and reported by Err. Expressions are values and may be reused.
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Expr is an aggregate expression.
+type Expr struct {
+	text string
+	atom bool // property, literal or function call, so never needs parentheses
+	err  error
+}
+
+// String returns the expression as it is sent to the server.
+func (e Expr) String() string {
+	return e.text
+}
+
+// Err returns the first problem found building the expression, if any.
+func (e Expr) Err() error {
+	if e.err == nil && e.text == "" {
+		return fmt.Errorf("expr: empty expression")
+	}
+	return e.err
+}
+
+// operand renders an expression used as the operand of an operator.
+func (e Expr) operand() string {
+	if e.atom {
+		return e.text
+	}
+	return "(" + e.text + ")"
+}
+
+// firstErr returns the first error in a set of expressions.
+func firstErr(exprs ...Expr) error {
+	for _, e := range exprs {
+		if err := e.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* ---- ATOMS */
+
+// Property refers to a property of the results (@name). The leading @ is optional.
+func Property(name string) Expr {
+	name = strings.TrimPrefix(name, "@")
+	if name == "" {
+		return Expr{err: fmt.Errorf("expr: empty property name")}
+	}
+	return Expr{text: "@" + name, atom: true}
+}
+
+// Literal is a string, quoted with backslashes and double quotes escaped.
+func Literal(s string) Expr {
+	return Expr{text: `"` + literalEscaper.Replace(s) + `"`, atom: true}
+}
+
+var literalEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// Number is a numeric literal. NaN and infinite values can't be written as literals and are
+// reported by Err.
+func Number(f float64) Expr {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Expr{err: fmt.Errorf("expr: %v is not a finite number", f)}
+	}
+	text := strconv.FormatFloat(f, 'f', -1, 64)
+	if f < 0 {
+		return Expr{text: text}
+	}
+	return Expr{text: text, atom: true}
+}
+
+// Int is an integer literal.
+func Int(i int64) Expr {
+	return Number(float64(i))
+}
+
+// Raw is an expression written by hand. It is parenthesised when used as an operand.
+func Raw(text string) Expr {
+	return Expr{text: text}
+}
+
+/* ---- OPERATORS */
+
+func (e Expr) binary(op string, other Expr) Expr {
+	if err := firstErr(e, other); err != nil {
+		return Expr{err: err}
+	}
+	return Expr{text: e.operand() + " " + op + " " + other.operand()}
+}
+
+// Add returns e + other.
+func (e Expr) Add(other Expr) Expr { return e.binary("+", other) }
+
+// Sub returns e - other.
+func (e Expr) Sub(other Expr) Expr { return e.binary("-", other) }
+
+// Mul returns e * other.
+func (e Expr) Mul(other Expr) Expr { return e.binary("*", other) }
+
+// Div returns e / other.
+func (e Expr) Div(other Expr) Expr { return e.binary("/", other) }
+
+// Mod returns e % other.
+func (e Expr) Mod(other Expr) Expr { return e.binary("%", other) }
+
+// Pow returns e ^ other.
+func (e Expr) Pow(other Expr) Expr { return e.binary("^", other) }
+
+// Eq returns e == other.
+func (e Expr) Eq(other Expr) Expr { return e.binary("==", other) }
+
+// Ne returns e != other.
+func (e Expr) Ne(other Expr) Expr { return e.binary("!=", other) }
+
+// Lt returns e < other.
+func (e Expr) Lt(other Expr) Expr { return e.binary("<", other) }
+
+// Le returns e <= other.
+func (e Expr) Le(other Expr) Expr { return e.binary("<=", other) }
+
+// Gt returns e > other.
+func (e Expr) Gt(other Expr) Expr { return e.binary(">", other) }
+
+// Ge returns e >= other.
+func (e Expr) Ge(other Expr) Expr { return e.binary(">=", other) }
+
+// And returns e && other.
+func (e Expr) And(other Expr) Expr { return e.binary("&&", other) }
+
+// Or returns e || other.
+func (e Expr) Or(other Expr) Expr { return e.binary("||", other) }
+
+// Not returns !e.
+func Not(e Expr) Expr {
+	if err := e.Err(); err != nil {
+		return Expr{err: err}
+	}
+	return Expr{text: "!" + e.operand()}
+}
+
+/* ---- FUNCTIONS */
+
+// arity is the number of arguments a function takes; max is -1 for any number.
+type arity struct {
+	min, max int
+}
+
+// functions lists the aggregation function library.
+var functions = map[string]arity{
+	// strings
+	"upper":         {1, 1},
+	"lower":         {1, 1},
+	"startswith":    {2, 2},
+	"contains":      {2, 2},
+	"strlen":        {1, 1},
+	"substr":        {3, 3},
+	"format":        {1, -1},
+	"split":         {1, 3},
+	"matched_terms": {0, 1},
+	"exists":        {1, 1},
+	// maths
+	"log":   {1, 1},
+	"abs":   {1, 1},
+	"ceil":  {1, 1},
+	"floor": {1, 1},
+	"log2":  {1, 1},
+	"exp":   {1, 1},
+	"sqrt":  {1, 1},
+	// dates
+	"timefmt":     {1, 2},
+	"parsetime":   {2, 2},
+	"day":         {1, 1},
+	"hour":        {1, 1},
+	"minute":      {1, 1},
+	"month":       {1, 1},
+	"dayofweek":   {1, 1},
+	"dayofmonth":  {1, 1},
+	"dayofyear":   {1, 1},
+	"year":        {1, 1},
+	"monthofyear": {1, 1},
+	// geo
+	"geodistance": {2, 3},
+}
+
+// Call calls a function of the aggregation library, checking the number of arguments.
+func Call(name string, args ...Expr) Expr {
+	name = strings.ToLower(name)
+	a, ok := functions[name]
+	if !ok {
+		return Expr{err: fmt.Errorf("expr: unknown function %s", name)}
+	}
+	if len(args) < a.min || a.max >= 0 && len(args) > a.max {
+		return Expr{err: fmt.Errorf("expr: %s takes %s, got %d", name, a, len(args))}
+	}
+	if err := firstErr(args...); err != nil {
+		return Expr{err: err}
+	}
+
+	rendered := make([]string, len(args))
+	for n, arg := range args {
+		rendered[n] = arg.text
+	}
+	return Expr{text: name + "(" + strings.Join(rendered, ", ") + ")", atom: true}
+}
+
+func (a arity) String() string {
+	switch {
+	case a.max < 0:
+		return fmt.Sprintf("at least %d arguments", a.min)
+	case a.min == a.max && a.min == 1:
+		return "1 argument"
+	case a.min == a.max:
+		return fmt.Sprintf("%d arguments", a.min)
+	}
+	return fmt.Sprintf("%d to %d arguments", a.min, a.max)
+}
+
+// Upper converts a string to upper case.
+func Upper(s Expr) Expr { return Call("upper", s) }
+
+// Lower converts a string to lower case.
+func Lower(s Expr) Expr { return Call("lower", s) }
+
+// StartsWith is 1 if s starts with prefix and 0 otherwise.
+func StartsWith(s, prefix Expr) Expr { return Call("startswith", s, prefix) }
+
+// Contains counts the occurrences of substr in s.
+func Contains(s, substr Expr) Expr { return Call("contains", s, substr) }
+
+// Strlen returns the length of a string.
+func Strlen(s Expr) Expr { return Call("strlen", s) }
+
+// Substr returns count characters of s starting at offset. A negative count is relative to
+// the end of the string.
+func Substr(s, offset, count Expr) Expr { return Call("substr", s, offset, count) }
+
+// Format formats its arguments with a format string in which %s is replaced by each argument
+// in turn and %% is a percent sign. The number of %s must match the number of arguments.
+func Format(format string, args ...Expr) Expr {
+	verbs := strings.Count(strings.ReplaceAll(format, "%%", ""), "%s")
+	if verbs != len(args) {
+		return Expr{err: fmt.Errorf("expr: format %q has %d %%s, got %d arguments", format, verbs, len(args))}
+	}
+	return Call("format", append([]Expr{Literal(format)}, args...)...)
+}
+
+// Split splits a string into an array, by default at commas and with spaces stripped. The
+// separator characters and the characters to strip may be given as further arguments.
+func Split(s Expr, args ...Expr) Expr { return Call("split", append([]Expr{s}, args...)...) }
+
+// Exists is 1 if the property exists and 0 otherwise.
+func Exists(property Expr) Expr { return Call("exists", property) }
+
+// Log returns the natural logarithm of x.
+func Log(x Expr) Expr { return Call("log", x) }
+
+// Log2 returns the base 2 logarithm of x.
+func Log2(x Expr) Expr { return Call("log2", x) }
+
+// Abs returns the absolute value of x.
+func Abs(x Expr) Expr { return Call("abs", x) }
+
+// Ceil rounds x up.
+func Ceil(x Expr) Expr { return Call("ceil", x) }
+
+// Floor rounds x down.
+func Floor(x Expr) Expr { return Call("floor", x) }
+
+// Exp returns e to the power of x.
+func Exp(x Expr) Expr { return Call("exp", x) }
+
+// Sqrt returns the square root of x.
+func Sqrt(x Expr) Expr { return Call("sqrt", x) }
+
+// TimeFmt formats a unix timestamp, by default as an ISO 8601 date and time. The format
+// (strftime style) may be given as a further argument.
+func TimeFmt(timestamp Expr, format ...Expr) Expr {
+	return Call("timefmt", append([]Expr{timestamp}, format...)...)
+}
+
+// ParseTime parses a string into a unix timestamp using a strptime style format.
+func ParseTime(s, format Expr) Expr { return Call("parsetime", s, format) }
+
+// Day rounds a timestamp down to midnight.
+func Day(timestamp Expr) Expr { return Call("day", timestamp) }
+
+// Hour rounds a timestamp down to the hour.
+func Hour(timestamp Expr) Expr { return Call("hour", timestamp) }
+
+// Minute rounds a timestamp down to the minute.
+func Minute(timestamp Expr) Expr { return Call("minute", timestamp) }
+
+// Month rounds a timestamp down to the start of the month.
+func Month(timestamp Expr) Expr { return Call("month", timestamp) }
+
+// DayOfWeek returns the day of the week of a timestamp, Sunday being 0.
+func DayOfWeek(timestamp Expr) Expr { return Call("dayofweek", timestamp) }
+
+// DayOfMonth returns the day of the month of a timestamp, from 1 to 31.
+func DayOfMonth(timestamp Expr) Expr { return Call("dayofmonth", timestamp) }
+
+// DayOfYear returns the day of the year of a timestamp, January 1st being 0.
+func DayOfYear(timestamp Expr) Expr { return Call("dayofyear", timestamp) }
+
+// Year returns the year of a timestamp.
+func Year(timestamp Expr) Expr { return Call("year", timestamp) }
+
+// MonthOfYear returns the month of a timestamp, January being 0.
+func MonthOfYear(timestamp Expr) Expr { return Call("monthofyear", timestamp) }
+
+// GeoDistance returns the distance in metres between two points. The points may be geo
+// properties or "lon,lat" strings, and the second may be given as separate lon and lat.
+func GeoDistance(from Expr, to ...Expr) Expr {
+	return Call("geodistance", append([]Expr{from}, to...)...)
+}
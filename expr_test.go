@@ -0,0 +1,84 @@
+package grsearch_test
+
+import (
+	"math"
+
+	grsearch "github.com/goslogan/grsearch"
+	"github.com/goslogan/grsearch/expr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Expressions", Label("expr"), func() {
+
+	It("renders operators with their operands grouped", func() {
+		day := expr.Property("timestamp").Sub(expr.Property("timestamp").Mod(expr.Int(86400)))
+		Expect(day.Err()).NotTo(HaveOccurred())
+		Expect(day.String()).To(Equal("@timestamp - (@timestamp % 86400)"))
+
+		filter := expr.Property("balance").Gt(expr.Number(-0.5)).And(expr.Not(expr.Property("country").Eq(expr.Literal("GB"))))
+		Expect(filter.String()).To(Equal(`(@balance > (-0.5)) && (!(@country == "GB"))`))
+	})
+
+	It("quotes string literals", func() {
+		Expect(expr.Literal(`say "hi" \ bye`).String()).To(Equal(`"say \"hi\" \\ bye"`))
+	})
+
+	It("renders functions", func() {
+		Expect(expr.Upper(expr.Property("@owner")).String()).To(Equal("upper(@owner)"))
+		Expect(expr.Substr(expr.Property("email"), expr.Int(0), expr.Int(-1)).String()).To(Equal("substr(@email, 0, -1)"))
+		Expect(expr.Format("%s (%s)", expr.Property("customer"), expr.Property("country")).String()).To(Equal(`format("%s (%s)", @customer, @country)`))
+		Expect(expr.TimeFmt(expr.Property("ts"), expr.Literal("%Y")).String()).To(Equal(`timefmt(@ts, "%Y")`))
+		Expect(expr.DayOfWeek(expr.Property("ts")).Add(expr.Int(1)).String()).To(Equal("dayofweek(@ts) + 1"))
+		Expect(expr.GeoDistance(expr.Property("location"), expr.Number(-0.1), expr.Number(51.5)).String()).To(Equal("geodistance(@location, -0.1, 51.5)"))
+		Expect(expr.Floor(expr.Log(expr.Abs(expr.Property("balance")))).String()).To(Equal("floor(log(abs(@balance)))"))
+	})
+
+	It("checks the number of arguments", func() {
+		Expect(expr.Call("upper").Err()).To(MatchError("expr: upper takes 1 argument, got 0"))
+		Expect(expr.Call("geodistance", expr.Property("a")).Err()).To(MatchError("expr: geodistance takes 2 to 3 arguments, got 1"))
+		Expect(expr.TimeFmt(expr.Property("ts"), expr.Literal("%Y"), expr.Literal("%m")).Err()).To(HaveOccurred())
+		Expect(expr.Format("%s and %s", expr.Property("a")).Err()).To(MatchError(ContainSubstring("has 2 %s, got 1 arguments")))
+		Expect(expr.Format("100%% %s", expr.Property("a")).Err()).NotTo(HaveOccurred())
+		Expect(expr.Call("nosuchfunction", expr.Property("a")).Err()).To(MatchError("expr: unknown function nosuchfunction"))
+		Expect(expr.Number(math.NaN()).Err()).To(MatchError("expr: NaN is not a finite number"))
+		Expect(expr.Property("a").Lt(expr.Number(math.Inf(1))).Err()).To(MatchError("expr: +Inf is not a finite number"))
+		Expect(expr.Number(math.Inf(-1)).Err()).To(MatchError("expr: -Inf is not a finite number"))
+	})
+
+	It("keeps the first error", func() {
+		e := expr.Lower(expr.Call("upper")).Add(expr.Property(""))
+		Expect(e.Err()).To(MatchError("expr: upper takes 1 argument, got 0"))
+		Expect(expr.Expr{}.Err()).To(HaveOccurred())
+	})
+
+	It("builds aggregates with expressions", func() {
+		opts := grsearch.NewAggregateBuilder().
+			Load("owner", "").
+			Load("balance", "").
+			ApplyExpr(expr.Upper(expr.Property("owner")), "uowner").
+			FilterExpr(expr.Property("balance").Ge(expr.Int(0))).
+			Options()
+		Expect(opts).To(Equal(grsearch.NewAggregateBuilder().
+			Load("owner", "").
+			Load("balance", "").
+			Apply("upper(@owner)", "uowner").
+			Filter("@balance >= 0").
+			Options()))
+
+		cmd := client.FTAggregate(ctx, "hcustomers", "*", opts)
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		Expect(cmd.Val()).NotTo(BeEmpty())
+		for _, row := range cmd.Val() {
+			Expect(row["uowner"]).To(MatchRegexp("^[^a-z]+$"))
+		}
+	})
+
+	It("fails aggregates with invalid expressions without sending them", func() {
+		opts := grsearch.NewAggregateBuilder().
+			ApplyExpr(expr.Call("upper"), "uowner").
+			Options()
+		cmd := client.FTAggregate(ctx, "hcustomers", "*", opts)
+		Expect(cmd.Err()).To(MatchError("expr: upper takes 1 argument, got 0"))
+	})
+})
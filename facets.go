@@ -0,0 +1,398 @@
+package grsearch
+
+// faceted search - the search for hits and a query per facet are sent in a single pipeline.
+// Tag facets are counted with FT.AGGREGATE (GROUPBY the attribute, REDUCE COUNT); range facets
+// are counted with one FT.SEARCH per bucket returning no documents, as aggregate expressions
+// can't assign values to buckets. Each facet's selections become a filter on the hits. For
+// multi-select facets the facet's own filter is left out when counting it, so that the other
+// values stay available to be added to the selection. The counts must cover the same documents
+// as the hits, so the search options which restrict the hits are copied to the range searches.
+// FT.AGGREGATE has no FILTER or GEOFILTER arguments so for tag facets these become clauses in
+// the query; the options it has no equivalent for are rejected.
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/goslogan/grsearch/internal"
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultFacetLimit is the number of values returned for a tag facet if Limit is not set.
+const DefaultFacetLimit = 10
+
+// Facet is a facet to be counted by [Client.FacetedSearch]; either a [TagFacet] or a [RangeFacet].
+type Facet interface {
+	facetName() string
+	facetFilter() string
+	queue(ctx context.Context, c cmdable, index, query string, options *QueryOptions, onJSON bool) func() ([]FacetCount, error)
+	multiSelect() bool
+}
+
+// TagFacet counts the values of a TAG attribute.
+type TagFacet struct {
+	Name        string   // Name of the facet in the results, defaults to Attribute
+	Attribute   string   // The attribute (or alias) to count
+	Limit       int64    // The number of values to return, most frequent first. DefaultFacetLimit if zero.
+	Separator   string   // Split values with this separator before counting (for hashes with several tags in one field)
+	Selected    []string // Values selected by the user; hits must match one of them
+	MultiSelect bool     // Count the facet without its own selections
+}
+
+// FacetBucket is a bucket for a [RangeFacet], holding values from Min up to but not including
+// Max. Use math.Inf for open ended buckets.
+type FacetBucket struct {
+	Name     string
+	Min, Max float64
+}
+
+// RangeFacet counts the values of a NUMERIC attribute in buckets.
+type RangeFacet struct {
+	Name        string // Name of the facet in the results, defaults to Attribute
+	Attribute   string // The attribute (or alias) to count
+	Buckets     []FacetBucket
+	Selected    []string // Names of buckets selected by the user; hits must be in one of them
+	MultiSelect bool     // Count the facet without its own selections
+}
+
+// FacetCount is the number of hits with a facet value.
+type FacetCount struct {
+	Value    string
+	Count    int64
+	Selected bool
+}
+
+// FacetedSearchOptions configures [Client.FacetedSearch].
+type FacetedSearchOptions struct {
+	Query  *QueryOptions // Options for the search for hits. Params and Dialect are used for the facets too.
+	Facets []Facet
+	JSON   bool // Search JSON documents rather than hashes
+}
+
+// FacetedResult holds the results of [Client.FacetedSearch].
+type FacetedResult struct {
+	Hits   *QueryCmd
+	Facets map[string][]FacetCount // Counts for each facet by name; most frequent first for tags, in bucket order for ranges
+}
+
+// FacetedSearch searches an index and counts the values of each facet among the hits. The hits
+// are restricted by the selections in all the facets. The counts for a facet are restricted
+// by the selections in the others, and by its own unless it is a MultiSelect facet.
+func (c *Client) FacetedSearch(ctx context.Context, index, query string, options *FacetedSearchOptions) (*FacetedResult, error) {
+	if options == nil {
+		options = &FacetedSearchOptions{}
+	}
+	qryOptions := options.Query
+	if qryOptions == nil {
+		qryOptions = NewQueryOptions()
+	}
+
+	filters := make([]string, 0, len(options.Facets))
+	for _, facet := range options.Facets {
+		if _, ok := facet.(*TagFacet); ok {
+			if err := aggregateRestrictions(qryOptions); err != nil {
+				return nil, err
+			}
+		}
+		if filter := facet.facetFilter(); filter != "" {
+			filters = append(filters, filter)
+		}
+	}
+
+	pipe := c.Client.Pipeline()
+	queue := cmdable(func(ctx context.Context, cmd redis.Cmder) error {
+		cmd, err := c.prepare(ctx, cmd)
+		if err != nil {
+			return err
+		}
+		return pipe.Process(ctx, cmd)
+	})
+
+	hitsQuery, err := andQuery(query, qryOptions.Dialect, filters...)
+	if err != nil {
+		return nil, err
+	}
+	var hits *QueryCmd
+	if options.JSON {
+		hits = queue.FTSearchJSON(ctx, index, hitsQuery, qryOptions)
+	} else {
+		hits = queue.FTSearchHash(ctx, index, hitsQuery, qryOptions)
+	}
+	if hits.Err() != nil {
+		return nil, hits.Err()
+	}
+
+	counts := make([]func() ([]FacetCount, error), len(options.Facets))
+	for n, facet := range options.Facets {
+		facetFilters := filters
+		if facet.multiSelect() {
+			facetFilters = make([]string, 0, len(filters))
+			for _, other := range options.Facets {
+				if filter := other.facetFilter(); other != facet && filter != "" {
+					facetFilters = append(facetFilters, filter)
+				}
+			}
+		}
+		facetQuery, err := andQuery(query, qryOptions.Dialect, facetFilters...)
+		if err != nil {
+			return nil, err
+		}
+		counts[n] = facet.queue(ctx, queue, index, facetQuery, qryOptions, options.JSON)
+	}
+
+	cmds, _ := pipe.Exec(ctx)
+	for _, cmd := range cmds {
+		if extCmd, ok := cmd.(ExtCmder); ok {
+			parse(extCmd)
+		}
+	}
+
+	if hits.Err() != nil {
+		return nil, hits.Err()
+	}
+	// later pages are read by the client, not the pipeline
+	hits.process = c.cmdable
+	result := &FacetedResult{Hits: hits, Facets: make(map[string][]FacetCount, len(options.Facets))}
+	for n, facet := range options.Facets {
+		if result.Facets[facet.facetName()], err = counts[n](); err != nil {
+			return nil, fmt.Errorf("grsearch: unable to count facet %s: %w", facet.facetName(), err)
+		}
+	}
+	return result, nil
+}
+
+/* ---- TAG FACETS */
+
+func (f *TagFacet) facetName() string {
+	if f.Name == "" {
+		return f.Attribute
+	}
+	return f.Name
+}
+
+func (f *TagFacet) multiSelect() bool {
+	return f.MultiSelect
+}
+
+func (f *TagFacet) facetFilter() string {
+	if len(f.Selected) == 0 {
+		return ""
+	}
+	values := make([]string, len(f.Selected))
+	for n, value := range f.Selected {
+		values[n] = Escape(value)
+	}
+	return fmt.Sprintf("@%s:{%s}", f.Attribute, strings.Join(values, " | "))
+}
+
+func (f *TagFacet) queue(ctx context.Context, c cmdable, index, query string, options *QueryOptions, _ bool) func() ([]FacetCount, error) {
+	limit := f.Limit
+	if limit == 0 {
+		limit = DefaultFacetLimit
+	}
+
+	query, err := andQuery(query, options.Dialect, filterClauses(options)...)
+	if err != nil {
+		return func() ([]FacetCount, error) { return nil, err }
+	}
+
+	builder := NewAggregateBuilder().
+		Dialect(options.Dialect).
+		Params(options.Params)
+	if options.Verbatim {
+		builder = builder.Verbatim()
+	}
+	property := "@" + f.Attribute
+	if f.Separator != "" {
+		builder = builder.
+			Load(f.Attribute, "").
+			Apply(fmt.Sprintf("split(@%s, %q)", f.Attribute, f.Separator), "facet_value")
+		property = "@facet_value"
+	}
+	aggregate := builder.
+		GroupBy(NewGroupByBuilder().Property(property).Reduce(ReduceCount("facet_count")).GroupBy()).
+		SortByMax([]AggregateSortKey{{Name: "facet_count", Order: SortDesc}}, limit).
+		Options()
+
+	cmd := c.FTAggregate(ctx, index, query, aggregate)
+	return func() ([]FacetCount, error) {
+		if cmd.Err() != nil {
+			return nil, cmd.Err()
+		}
+
+		selected := map[string]bool{}
+		for _, value := range f.Selected {
+			selected[value] = true
+		}
+		counts := make([]FacetCount, 0, len(cmd.Val()))
+		for _, row := range cmd.Val() {
+			value, ok := row[strings.TrimPrefix(property, "@")].(string)
+			if !ok {
+				continue // documents without the attribute
+			}
+			count, err := internal.Int64(row["facet_count"])
+			if err != nil {
+				return nil, err
+			}
+			counts = append(counts, FacetCount{Value: value, Count: count, Selected: selected[value]})
+			delete(selected, value)
+		}
+		// selections with no hits are still reported so they can be deselected
+		for _, value := range f.Selected {
+			if selected[value] {
+				counts = append(counts, FacetCount{Value: value, Selected: true})
+			}
+		}
+		return counts, nil
+	}
+}
+
+/* ---- RANGE FACETS */
+
+func (f *RangeFacet) facetName() string {
+	if f.Name == "" {
+		return f.Attribute
+	}
+	return f.Name
+}
+
+func (f *RangeFacet) multiSelect() bool {
+	return f.MultiSelect
+}
+
+func (f *RangeFacet) facetFilter() string {
+	clauses := []string{}
+	for _, bucket := range f.Buckets {
+		for _, name := range f.Selected {
+			if name == bucket.Name {
+				clauses = append(clauses, f.bucketQuery(bucket))
+			}
+		}
+	}
+	if len(clauses) == 0 {
+		return ""
+	}
+	return strings.Join(clauses, " | ")
+}
+
+// bucketQuery returns a numeric range query for a bucket.
+func (f *RangeFacet) bucketQuery(bucket FacetBucket) string {
+	max := "(" + formatBound(bucket.Max)
+	if math.IsInf(bucket.Max, 1) {
+		max = "+inf"
+	}
+	return fmt.Sprintf("@%s:[%s %s]", f.Attribute, formatBound(bucket.Min), max)
+}
+
+func formatBound(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+inf"
+	case math.IsInf(value, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func (f *RangeFacet) queue(ctx context.Context, c cmdable, index, query string, options *QueryOptions, onJSON bool) func() ([]FacetCount, error) {
+	cmds := make([]*QueryCmd, len(f.Buckets))
+	for n, bucket := range f.Buckets {
+		bucketQuery, err := andQuery(query, options.Dialect, f.bucketQuery(bucket))
+		if err != nil {
+			return func() ([]FacetCount, error) { return nil, err }
+		}
+		countOptions := restrictedOptions(options)
+		countOptions.NoContent = true
+		countOptions.Limit = NewLimit(0, 0)
+		if onJSON {
+			cmds[n] = c.FTSearchJSON(ctx, index, bucketQuery, countOptions)
+		} else {
+			cmds[n] = c.FTSearchHash(ctx, index, bucketQuery, countOptions)
+		}
+	}
+
+	return func() ([]FacetCount, error) {
+		counts := make([]FacetCount, len(f.Buckets))
+		for n, bucket := range f.Buckets {
+			if cmds[n].Err() != nil {
+				return nil, cmds[n].Err()
+			}
+			counts[n] = FacetCount{Value: bucket.Name, Count: cmds[n].TotalResults()}
+			for _, name := range f.Selected {
+				if name == bucket.Name {
+					counts[n].Selected = true
+				}
+			}
+		}
+		return counts, nil
+	}
+}
+
+/* ---- RESTRICTIONS FROM THE SEARCH OPTIONS */
+
+// restrictedOptions returns new search options with the options which restrict the documents
+// matched by a search copied from options.
+func restrictedOptions(options *QueryOptions) *QueryOptions {
+	restricted := NewQueryOptions()
+	restricted.Verbatim = options.Verbatim
+	restricted.NoStopWords = options.NoStopWords
+	restricted.InOrder = options.InOrder
+	restricted.Filters = options.Filters
+	restricted.GeoFilters = options.GeoFilters
+	restricted.InKeys = options.InKeys
+	restricted.InFields = options.InFields
+	restricted.Language = options.Language
+	restricted.Slop = options.Slop
+	restricted.Expander = options.Expander
+	restricted.Dialect = options.Dialect
+	restricted.Params = options.Params
+	return restricted
+}
+
+// filterClauses converts the numeric and geo filters in options to query clauses.
+func filterClauses(options *QueryOptions) []string {
+	clauses := make([]string, 0, len(options.Filters)+len(options.GeoFilters))
+	for _, filter := range options.Filters {
+		clauses = append(clauses, fmt.Sprintf("@%s:[%v %v]", filter.Attribute, filter.Min, filter.Max))
+	}
+	for _, filter := range options.GeoFilters {
+		clauses = append(clauses, fmt.Sprintf("@%s:[%s %s %s %s]", filter.Attribute,
+			strconv.FormatFloat(filter.Long, 'f', -1, 64), strconv.FormatFloat(filter.Lat, 'f', -1, 64),
+			strconv.FormatFloat(filter.Radius, 'f', -1, 64), filter.Units))
+	}
+	return clauses
+}
+
+// aggregateRestrictions returns an error if options restrict the documents matched in a way
+// which can't be expressed in an aggregate.
+func aggregateRestrictions(options *QueryOptions) error {
+	unsupported := []string{}
+	if len(options.InKeys) > 0 {
+		unsupported = append(unsupported, "INKEYS")
+	}
+	if len(options.InFields) > 0 {
+		unsupported = append(unsupported, "INFIELDS")
+	}
+	if options.NoStopWords {
+		unsupported = append(unsupported, "NOSTOPWORDS")
+	}
+	if options.InOrder {
+		unsupported = append(unsupported, "INORDER")
+	}
+	if options.Language != "" {
+		unsupported = append(unsupported, "LANGUAGE")
+	}
+	if options.Slop != noSlop {
+		unsupported = append(unsupported, "SLOP")
+	}
+	if options.Expander != "" {
+		unsupported = append(unsupported, "EXPANDER")
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("grsearch: tag facets can't be counted for searches using %s", strings.Join(unsupported, ", "))
+	}
+	return nil
+}
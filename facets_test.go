@@ -0,0 +1,158 @@
+package grsearch_test
+
+import (
+	"math"
+
+	grsearch "github.com/goslogan/grsearch"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Faceted search", Label("facets"), func() {
+
+	balances := []grsearch.FacetBucket{
+		{Name: "overdrawn", Min: math.Inf(-1), Max: 0},
+		{Name: "in credit", Min: 0, Max: math.Inf(1)},
+	}
+
+	It("counts tag and range facets", func() {
+		result, err := client.FacetedSearch(ctx, "hcustomers", "*", &grsearch.FacetedSearchOptions{
+			Facets: []grsearch.Facet{
+				&grsearch.TagFacet{Attribute: "owner", Limit: 100},
+				&grsearch.RangeFacet{Attribute: "balance", Buckets: balances},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		total := result.Hits.TotalResults()
+
+		owners := int64(0)
+		for n, count := range result.Facets["owner"] {
+			if n > 0 {
+				Expect(count.Count).To(BeNumerically("<=", result.Facets["owner"][n-1].Count))
+			}
+			Expect(count.Count).To(Equal(client.FTSearchHash(ctx, "hcustomers", "@owner:{"+grsearch.Escape(count.Value)+"}", nil).TotalResults()))
+			owners += count.Count
+		}
+		Expect(owners).To(Equal(total))
+
+		Expect(result.Facets["balance"]).To(HaveLen(2))
+		Expect(result.Facets["balance"][0].Value).To(Equal("overdrawn"))
+		Expect(result.Facets["balance"][0].Count + result.Facets["balance"][1].Count).To(Equal(total))
+	})
+
+	It("filters hits by the selections", func() {
+		result, err := client.FacetedSearch(ctx, "hcustomers", "*", &grsearch.FacetedSearchOptions{
+			Facets: []grsearch.Facet{
+				&grsearch.TagFacet{Attribute: "owner", Selected: []string{"ellen.ripley"}},
+				&grsearch.RangeFacet{Attribute: "balance", Buckets: balances, Selected: []string{"in credit"}},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Hits.Args()[2]).To(Equal(`@owner:{ellen\.ripley} @balance:[0 +inf]`))
+		Expect(result.Hits.TotalResults()).To(Equal(client.FTSearchHash(ctx, "hcustomers", `@owner:{ellen\.ripley} @balance:[0 +inf]`, nil).TotalResults()))
+		for _, hit := range result.Hits.Val() {
+			Expect(hit.Values["account_owner"]).To(Equal("ellen.ripley"))
+		}
+
+		Expect(result.Facets["owner"]).To(HaveLen(1))
+		Expect(result.Facets["owner"][0]).To(Equal(grsearch.FacetCount{Value: "ellen.ripley", Count: result.Hits.TotalResults(), Selected: true}))
+		Expect(result.Facets["balance"][0].Count).To(BeZero())
+		Expect(result.Facets["balance"][1].Selected).To(BeTrue())
+	})
+
+	It("leaves out a multi-select facet's own filter when counting it", func() {
+		result, err := client.FacetedSearch(ctx, "hcustomers", "*", &grsearch.FacetedSearchOptions{
+			Query: grsearch.NewQueryBuilder().Limit(0, 0).Options(),
+			Facets: []grsearch.Facet{
+				&grsearch.TagFacet{Name: "owners", Attribute: "owner", Limit: 100, Selected: []string{"ellen.ripley"}, MultiSelect: true},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(len(result.Facets["owners"])).To(BeNumerically(">", 1))
+
+		selected := 0
+		counted := int64(0)
+		for _, count := range result.Facets["owners"] {
+			if count.Selected {
+				selected++
+				Expect(count.Count).To(Equal(result.Hits.TotalResults()))
+			}
+			counted += count.Count
+		}
+		Expect(selected).To(Equal(1))
+		Expect(counted).To(Equal(client.FTSearchHash(ctx, "hcustomers", "*", nil).TotalResults()))
+	})
+
+	It("reports selections without hits", func() {
+		result, err := client.FacetedSearch(ctx, "hcustomers", "*", &grsearch.FacetedSearchOptions{
+			Facets: []grsearch.Facet{
+				&grsearch.TagFacet{Attribute: "country", Selected: []string{"Atlantis"}},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Hits.TotalResults()).To(BeZero())
+		Expect(result.Facets["country"]).To(Equal([]grsearch.FacetCount{{Value: "Atlantis", Selected: true}}))
+	})
+
+	It("reads later pages of hits with the client", func() {
+		result, err := client.FacetedSearch(ctx, "hcustomers", "*", &grsearch.FacetedSearchOptions{
+			Query:  grsearch.NewQueryBuilder().Limit(0, 2).Options(),
+			Facets: []grsearch.Facet{&grsearch.RangeFacet{Attribute: "balance", Buckets: balances}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Hits.Val()).To(HaveLen(2))
+
+		seen := int64(0)
+		iterator := result.Hits.Iterator(ctx)
+		for iterator.Next(ctx) {
+			seen++
+		}
+		Expect(iterator.Err()).NotTo(HaveOccurred())
+		Expect(seen).To(Equal(result.Hits.TotalResults()))
+	})
+
+	It("counts facets on JSON documents", Label("json"), func() {
+		result, err := client.FacetedSearch(ctx, "jcustomers", "*", &grsearch.FacetedSearchOptions{
+			JSON: true,
+			Facets: []grsearch.Facet{
+				&grsearch.TagFacet{Attribute: "owner", Limit: 100},
+				&grsearch.RangeFacet{Attribute: "balance", Buckets: balances},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		total := result.Hits.TotalResults()
+		Expect(total).To(Equal(client.FTSearchJSON(ctx, "jcustomers", "*", nil).TotalResults()))
+		Expect(result.Hits.Val()[0].Values).To(HaveKey("$"))
+		Expect(result.Facets["balance"][0].Count + result.Facets["balance"][1].Count).To(Equal(total))
+		Expect(result.Facets["balance"][0].Count).To(Equal(client.FTSearchJSON(ctx, "jcustomers", "@balance:[-inf (0]", nil).TotalResults()))
+	})
+
+	It("counts only the documents matching the search filters", func() {
+		result, err := client.FacetedSearch(ctx, "hcustomers", "*", &grsearch.FacetedSearchOptions{
+			Query: grsearch.NewQueryBuilder().Filter("balance", 0, "+inf").Options(),
+			Facets: []grsearch.Facet{
+				&grsearch.TagFacet{Attribute: "owner", Limit: 100},
+				&grsearch.RangeFacet{Attribute: "balance", Buckets: balances},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		total := result.Hits.TotalResults()
+		Expect(total).To(Equal(client.FTSearchHash(ctx, "hcustomers", "@balance:[0 +inf]", nil).TotalResults()))
+
+		owners := int64(0)
+		for _, count := range result.Facets["owner"] {
+			owners += count.Count
+		}
+		Expect(owners).To(Equal(total))
+		Expect(result.Facets["balance"][0].Count).To(BeZero())
+		Expect(result.Facets["balance"][1].Count).To(Equal(total))
+	})
+
+	It("rejects search options which tag facets can't be counted with", func() {
+		_, err := client.FacetedSearch(ctx, "hcustomers", "*", &grsearch.FacetedSearchOptions{
+			Query:  grsearch.NewQueryBuilder().InKeys([]string{"haccount:1121175"}).Options(),
+			Facets: []grsearch.Facet{&grsearch.TagFacet{Attribute: "owner"}},
+		})
+		Expect(err).To(MatchError("grsearch: tag facets can't be counted for searches using INKEYS"))
+	})
+})
@@ -16,6 +16,8 @@ import (
 type Client struct {
 	redis.Client
 	cmdable
+	capabilities *capabilityCache
+	rewriters    *rewriters
 }
 
 type cmdable func(ctx context.Context, cmd redis.Cmder) error
@@ -23,26 +25,95 @@ type cmdable func(ctx context.Context, cmd redis.Cmder) error
 // NewClient returns a new search client using the same options as the standard
 // go-redis client.
 func NewClient(options *redis.Options) *Client {
-	client := &Client{Client: *redis.NewClient(options)}
+	client := &Client{Client: *redis.NewClient(options), capabilities: &capabilityCache{}, rewriters: &rewriters{}}
 	client.cmdable = client.Process
 	return client
 }
 
 // FromRedisClient builds a client from an existing redis client
 func FromRedisClient(redisClient *redis.Client) *Client {
-	client := &Client{Client: *redisClient}
+	client := &Client{Client: *redisClient, capabilities: &capabilityCache{}, rewriters: &rewriters{}}
 	client.cmdable = client.Process
 	return client
 }
 
+// Process runs a command. Search commands are parsed before it returns, and those whose options
+// need features the server doesn't support fail with an [UnsupportedFeatureError] without being sent.
+// Searches and aggregates are passed through any query rewriters (see [Client.AddQueryRewriter]).
 func (c *Client) Process(ctx context.Context, cmd redis.Cmder) error {
-	err := c.Client.Process(ctx, cmd)
+	cmd, err := c.prepare(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	err = c.Client.Process(ctx, cmd)
 	if c, ok := cmd.(ExtCmder); ok {
-		err = c.postProcess()
-		if err != nil {
+		err = parse(c)
+	}
+
+	return err
+}
+
+// parse post-processes a command unless that has already been done (by a hook, for example),
+// setting any error on the command.
+func parse(cmd ExtCmder) error {
+	if !cmd.markParsed() {
+		if err := cmd.postProcess(); err != nil {
 			cmd.SetErr(err)
 		}
 	}
+	return cmd.Err()
+}
 
-	return err
+// prepare checks the features a command needs and runs the query rewriters, returning the
+// command to send. The error is also set on the command.
+func (c *Client) prepare(ctx context.Context, cmd redis.Cmder) (redis.Cmder, error) {
+	if gated, ok := cmd.(*gatedCmd); ok {
+		cmd = gated.Cmder
+		if err := c.checkFeatures(ctx, gated.features); err != nil {
+			cmd.SetErr(err)
+			return cmd, err
+		}
+	}
+
+	if err := c.rewrite(ctx, cmd); err != nil {
+		cmd.SetErr(err)
+		return cmd, err
+	}
+
+	return cmd, nil
+}
+
+// AddHook adds a hook to the client in the same way as [redis.Client.AddHook]. Search and JSON
+// commands have been parsed when next returns so hooks can use the typed results, for example
+// [QueryCmd.TotalResults]. This applies to commands run in pipelines too.
+func (c *Client) AddHook(hook redis.Hook) {
+	c.Client.AddHook(parsingHook{hook})
+}
+
+// parsingHook wraps a hook so that the commands it sees are post-processed.
+type parsingHook struct {
+	redis.Hook
+}
+
+func (h parsingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return h.Hook.ProcessHook(func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		if c, ok := cmd.(ExtCmder); ok {
+			err = parse(c)
+		}
+		return err
+	})
+}
+
+func (h parsingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return h.Hook.ProcessPipelineHook(func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		for _, cmd := range cmds {
+			if c, ok := cmd.(ExtCmder); ok {
+				parse(c)
+			}
+		}
+		return err
+	})
 }
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"regexp"
 	"strings"
 	"testing"
@@ -29,6 +31,42 @@ var customerJSON string
 var client *grsearch.Client
 var ctx = context.Background()
 
+// offline fails every command with errOffline without sending it, so that the arguments
+// built for a command can be checked with cmd.Args().
+var offline = newOfflineClient()
+
+var errOffline = errors.New("offline")
+
+type offlineHook struct{}
+
+func newOfflineClient() *grsearch.Client {
+	c := grsearch.NewClient(&redis.Options{})
+	c.AddHook(offlineHook{})
+	return c
+}
+
+func (offlineHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errOffline
+	}
+}
+
+func (offlineHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		cmd.SetErr(errOffline)
+		return errOffline
+	}
+}
+
+func (offlineHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			cmd.SetErr(errOffline)
+		}
+		return errOffline
+	}
+}
+
 // convert strings that need to stay "as one for tokenising"
 func escapeForHash(s string) string {
 	re := regexp.MustCompile(`([,.<>{}\[\]"':;!@#$%^&*()\-+=~ ])`)
@@ -0,0 +1,389 @@
+package grsearch
+
+// importing - CSV and NDJSON sources are converted to BulkDocuments and written with a BulkLoader.
+// Each row is mapped to the index schema: the Mapping option gives the column (CSV) or JSON path
+// (NDJSON) for each attribute and defaults to the attribute name or alias. Values for NUMERIC
+// attributes are checked (and stored as numbers in JSON documents), other values are stored as
+// they are read and a template can be used to build JSON documents instead. Rows which can't be
+// converted or written are reported with their line number.
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// ImportOptions configures [Client.ImportCSV] and [Client.ImportNDJSON].
+type ImportOptions struct {
+	Index        string             // The index the documents belong to
+	IndexOptions *IndexOptions      // The index definition. If nil it is read with FT.INFO
+	CreateIndex  bool               // Create the index from IndexOptions before importing
+	Id           string             // The column or JSON path holding the document id
+	Columns      []string           // CSV column names. If nil the first row is read as a header
+	Mapping      map[string]string  // Attribute name or alias to column or JSON path
+	Template     *template.Template // Builds JSON documents from the CSV row ([]string) or NDJSON object
+	BatchSize    int                // Documents per pipeline, defaults to [DefaultBulkBatchSize]
+	Concurrency  int                // Concurrent pipelines, defaults to [DefaultBulkConcurrency]
+}
+
+// ImportError records a row which could not be imported.
+type ImportError struct {
+	Line int64
+	Key  string
+	Err  error
+}
+
+// ImportResult summarises an import.
+type ImportResult struct {
+	Rows   int64
+	Loaded int64
+	Errors []ImportError
+}
+
+type importRow struct {
+	line   int64
+	data   interface{}                      // passed to the template
+	record map[string]interface{}           // NDJSON only, written as is if there is no mapping
+	lookup func(string) (interface{}, bool) // finds a column or path in the row
+}
+
+type importer struct {
+	options    *ImportOptions
+	index      *IndexOptions
+	attributes map[string]SchemaAttribute
+	mapping    map[string]string
+	onJSON     bool
+}
+
+var (
+	errTemplateOnHash  = errors.New("grsearch: templates can only be used to import into JSON indexes")
+	errNoImportOptions = errors.New("grsearch: ImportOptions must be set")
+)
+
+// Error implements the error interface.
+func (e ImportError) Error() string {
+	return fmt.Sprintf("grsearch: line %d: %v", e.Line, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e ImportError) Unwrap() error {
+	return e.Err
+}
+
+// ImportCSV imports documents from CSV data.
+func (c *Client) ImportCSV(ctx context.Context, source io.Reader, options *ImportOptions) (*ImportResult, error) {
+	if options == nil {
+		return nil, errNoImportOptions
+	}
+
+	reader := csv.NewReader(source)
+	reader.FieldsPerRecord = -1
+	columns := options.Columns
+
+	if columns == nil {
+		header, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("grsearch: unable to read CSV header: %w", err)
+		}
+		columns = header
+	}
+
+	positions := make(map[string]int, len(columns))
+	for n, column := range columns {
+		positions[column] = n
+	}
+
+	return c.runImport(ctx, options, func() (*importRow, error) {
+		record, err := reader.Read()
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return &importRow{line: int64(parseErr.StartLine)}, err
+			}
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		return &importRow{
+			line: int64(line),
+			data: record,
+			lookup: func(column string) (interface{}, bool) {
+				n, ok := positions[column]
+				if !ok || n >= len(record) {
+					return nil, false
+				}
+				return record[n], true
+			},
+		}, nil
+	})
+}
+
+// ImportNDJSON imports documents from newline delimited JSON data. If the index is on
+// JSON and neither Mapping nor Template is set each object is written as is.
+func (c *Client) ImportNDJSON(ctx context.Context, source io.Reader, options *ImportOptions) (*ImportResult, error) {
+	if options == nil {
+		return nil, errNoImportOptions
+	}
+
+	reader := bufio.NewReader(source)
+	var line int64
+
+	return c.runImport(ctx, options, func() (*importRow, error) {
+		for {
+			data, err := reader.ReadBytes('\n')
+			if err != nil && (err != io.EOF || len(data) == 0) {
+				return nil, err
+			}
+			line++
+			if len(bytes.TrimSpace(data)) == 0 {
+				continue
+			}
+
+			record := map[string]interface{}{}
+			decoder := json.NewDecoder(bytes.NewReader(data))
+			decoder.UseNumber()
+			if err := decoder.Decode(&record); err != nil {
+				return &importRow{line: line}, err
+			}
+			return &importRow{
+				line:   line,
+				data:   record,
+				record: record,
+				lookup: func(path string) (interface{}, bool) {
+					return lookupJSONPath(record, path)
+				},
+			}, nil
+		}
+	})
+}
+
+// runImport reads rows with next until it returns io.EOF, converting them to documents and
+// loading them. A row error is returned with the row it applies to, any other error stops the
+// import.
+func (c *Client) runImport(ctx context.Context, options *ImportOptions, next func() (*importRow, error)) (*ImportResult, error) {
+	im, err := c.newImporter(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	loader := NewBulkLoader(c, options.Index, im.index).
+		BatchSize(options.BatchSize).
+		Concurrency(options.Concurrency)
+
+	result := &ImportResult{}
+	docs := make(chan BulkDocument)
+	loaded := make(chan *BulkLoadResult, 1)
+	loadErr := make(chan error, 1)
+
+	go func() {
+		r, err := loader.Load(ctx, docs)
+		loaded <- r
+		loadErr <- err
+	}()
+
+	var readErr error
+	for ctx.Err() == nil {
+		row, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && row == nil {
+			readErr = err
+			break
+		}
+		result.Rows++
+		if err == nil {
+			var doc BulkDocument
+			if doc, err = im.document(row); err == nil {
+				select {
+				case docs <- doc:
+				case <-ctx.Done():
+				}
+				continue
+			}
+		}
+		result.Errors = append(result.Errors, ImportError{Line: row.line, Err: err})
+	}
+	close(docs)
+
+	r, err := <-loaded, <-loadErr
+	if r != nil {
+		result.Loaded = r.Loaded
+		for _, f := range r.Failures {
+			result.Errors = append(result.Errors, ImportError{Line: f.line, Key: f.Key, Err: f.Err})
+		}
+	}
+
+	if readErr != nil {
+		return result, readErr
+	}
+	return result, err
+}
+
+// newImporter resolves the index definition (creating the index if asked) and the mapping.
+func (c *Client) newImporter(ctx context.Context, options *ImportOptions) (*importer, error) {
+	index := options.IndexOptions
+	if options.CreateIndex {
+		if index == nil {
+			return nil, errors.New("grsearch: IndexOptions must be set to create the index")
+		}
+		if err := c.FTCreate(ctx, options.Index, index).Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	if index == nil {
+		info, err := c.FTInfo(ctx, options.Index).Result()
+		if err != nil {
+			return nil, err
+		}
+		index = info.Index
+	}
+
+	im := &importer{
+		options:    options,
+		index:      index,
+		attributes: map[string]SchemaAttribute{},
+		mapping:    options.Mapping,
+		onJSON:     strings.EqualFold(index.On, "json"),
+	}
+
+	if options.Template != nil && !im.onJSON {
+		return nil, errTemplateOnHash
+	}
+
+	defaultMapping := map[string]string{}
+	for _, a := range index.Schema {
+		name, alias, _ := attributeIdentity(a)
+		im.attributes[name] = a
+		if alias != "" {
+			im.attributes[alias] = a
+			defaultMapping[alias] = alias
+		} else {
+			defaultMapping[name] = name
+		}
+	}
+
+	if len(im.mapping) == 0 {
+		im.mapping = defaultMapping
+	}
+
+	return im, nil
+}
+
+// document converts a row to a document.
+func (im *importer) document(row *importRow) (BulkDocument, error) {
+	doc := BulkDocument{line: row.line}
+
+	id, ok := row.lookup(im.options.Id)
+	if !ok || id == nil || fmt.Sprint(id) == "" {
+		return doc, fmt.Errorf("no value for the document id (%s)", im.options.Id)
+	}
+	doc.Id = fmt.Sprint(id)
+
+	if im.options.Template != nil {
+		var js bytes.Buffer
+		if err := im.options.Template.Execute(&js, row.data); err != nil {
+			return doc, err
+		}
+		if !json.Valid(js.Bytes()) {
+			return doc, errors.New("template did not produce valid JSON")
+		}
+		doc.Raw = js.String()
+		return doc, nil
+	}
+
+	if row.record != nil && im.onJSON && len(im.options.Mapping) == 0 {
+		doc.Raw = row.record
+		return doc, nil
+	}
+
+	doc.Fields = make(map[string]interface{}, len(im.mapping))
+	for attribute, source := range im.mapping {
+		value, ok := row.lookup(source)
+		if !ok || value == nil {
+			continue
+		}
+		typed, err := im.typed(attribute, value)
+		if err != nil {
+			return doc, fmt.Errorf("%s: %w", attribute, err)
+		}
+		if typed != nil {
+			doc.Fields[attribute] = typed
+		}
+	}
+
+	return doc, nil
+}
+
+// typed converts a value to the type needed by its attribute.
+func (im *importer) typed(attribute string, value interface{}) (interface{}, error) {
+	switch a := im.attributes[attribute].(type) {
+	case *NumericAttribute:
+		var f float64
+		var err error
+		switch v := value.(type) {
+		case string:
+			if strings.TrimSpace(v) == "" {
+				return nil, nil
+			}
+			f, err = strconv.ParseFloat(strings.TrimSpace(v), 64)
+		case json.Number:
+			f, err = v.Float64()
+		case float64:
+			f = v
+		default:
+			err = fmt.Errorf("%v is not a number", value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if im.onJSON {
+			return f, nil
+		}
+		if s, ok := value.(string); ok {
+			return strings.TrimSpace(s), nil
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case *VectorAttribute:
+		if list, ok := value.([]interface{}); ok && !im.onJSON {
+			floats := make([]float64, len(list))
+			for n, v := range list {
+				f, err := strconv.ParseFloat(fmt.Sprint(v), 64)
+				if err != nil {
+					return nil, err
+				}
+				floats[n] = f
+			}
+			return vectorValue(a, floats), nil
+		}
+	}
+
+	if n, ok := value.(json.Number); ok && !im.onJSON {
+		return n.String(), nil
+	}
+	return value, nil
+}
+
+// lookupJSONPath finds the value at a simple dotted path ($.a.b or a.b) in an object.
+func lookupJSONPath(record map[string]interface{}, path string) (interface{}, bool) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	var node interface{} = record
+	for _, segment := range strings.Split(path, ".") {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if node, ok = m[segment]; !ok {
+			return nil, false
+		}
+	}
+	return node, true
+}
@@ -0,0 +1,101 @@
+package grsearch_test
+
+import (
+	"strings"
+	"text/template"
+	"time"
+
+	grsearch "github.com/goslogan/grsearch"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Importing", Ordered, Label("import", "ft.create"), func() {
+
+	columns := []string{"first", "last", "email", "ip", "account_id", "owner", "balance", "country"}
+
+	BeforeAll(func() {
+		DeferCleanup(func() {
+			client.FTDropIndex(ctx, "himport", true)
+			client.FTDropIndex(ctx, "jimport", true)
+		})
+	})
+
+	It("can import CSV data into hashes, creating the index", Label("hash", "csv"), func() {
+		result, err := client.ImportCSV(ctx, strings.NewReader(customerData), &grsearch.ImportOptions{
+			Index: "himport",
+			IndexOptions: grsearch.NewIndexBuilder().
+				Prefix("himport:").
+				Schema(&grsearch.TagAttribute{Name: "account_id", Alias: "id"}).
+				Schema(&grsearch.TagAttribute{Name: "email"}).
+				Schema(&grsearch.NumericAttribute{Name: "balance", Sortable: true}).
+				Options(),
+			CreateIndex: true,
+			Id:          "account_id",
+			Columns:     columns,
+			Mapping:     map[string]string{"id": "account_id", "email": "email", "balance": "balance"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Errors).To(BeEmpty())
+		Expect(result.Rows).To(Equal(int64(25)))
+		Expect(result.Loaded).To(Equal(int64(25)))
+		Expect(grsearch.NewBulkLoader(client, "himport", nil).WaitForIndex(ctx, 100*time.Millisecond)).NotTo(HaveOccurred())
+
+		cmd := client.FTSearchHash(ctx, "himport", "@email:{"+grsearch.Escape("ejowers0@unblog.fr")+"}", nil)
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		Expect(cmd.Keys()).To(ConsistOf("himport:467734"))
+		Expect(cmd.Val()[0].Values["balance"]).To(Equal("-999.99"))
+		Expect(cmd.Val()[0].Values["email"]).To(Equal("ejowers0@unblog.fr"))
+	})
+
+	It("fails without options", func() {
+		_, err := client.ImportCSV(ctx, strings.NewReader(customerData), nil)
+		Expect(err).To(MatchError("grsearch: ImportOptions must be set"))
+		_, err = client.ImportNDJSON(ctx, strings.NewReader(`{"id": "1"}`), nil)
+		Expect(err).To(MatchError("grsearch: ImportOptions must be set"))
+	})
+
+	It("can import CSV data into JSON documents with a template", Label("json", "csv"), func() {
+		t := template.Must(template.New("customer").Parse(customerJSON))
+		Expect(client.FTCreate(ctx, "jimport", grsearch.NewIndexBuilder().
+			On("json").
+			Prefix("jimport:").
+			Schema(&grsearch.NumericAttribute{Name: "$.balance", Alias: "balance"}).
+			Options()).Err()).NotTo(HaveOccurred())
+
+		result, err := client.ImportCSV(ctx, strings.NewReader(customerData), &grsearch.ImportOptions{
+			Index:    "jimport",
+			Id:       "account_id",
+			Columns:  columns,
+			Template: t,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Errors).To(BeEmpty())
+		Expect(result.Loaded).To(Equal(int64(25)))
+		Expect(client.JSONGet(ctx, "jimport:536299", "$.balance").Val()).To(Equal(`[113.0]`))
+	})
+
+	It("reports row errors with line numbers", Label("json", "ndjson"), func() {
+		data := `{"id": "1", "balance": 10}
+{"id": "2", "balance": "lots"}
+
+{"id": "3", "balance": 
+{"balance": 4}
+{"id": "5", "balance": "5.5"}
+`
+		result, err := client.ImportNDJSON(ctx, strings.NewReader(data), &grsearch.ImportOptions{
+			Index:   "jimport",
+			Id:      "$.id",
+			Mapping: map[string]string{"balance": "$.balance"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Rows).To(Equal(int64(5)))
+		Expect(result.Loaded).To(Equal(int64(2)))
+		lines := []int64{}
+		for _, e := range result.Errors {
+			lines = append(lines, e.Line)
+		}
+		Expect(lines).To(ConsistOf(int64(2), int64(4), int64(5)))
+		Expect(client.JSONGet(ctx, "jimport:5", "$.balance").Val()).To(Equal(`[5.5]`))
+	})
+})
@@ -0,0 +1,348 @@
+package grsearch
+
+// index definitions - IndexOptions can be written to and read from JSON and YAML so that index
+// definitions can be kept in files. Each schema attribute is encoded as an object with a "type"
+// key (tag, text, numeric, geo, geometry or vector) followed by the fields of the attribute
+// struct. A definition file may also contain a "name" key giving the index name; if it is
+// missing the file name (without extension) is used.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IndexDefinition is an index name with its options, as read from a definition file.
+type IndexDefinition struct {
+	Name    string
+	File    string
+	Options *IndexOptions
+}
+
+// indexDocument is the encoded form of IndexOptions.
+type indexDocument struct {
+	On              string             `json:"on" yaml:"on"`
+	Prefix          []string           `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	Filter          string             `json:"filter,omitempty" yaml:"filter,omitempty"`
+	Language        string             `json:"language,omitempty" yaml:"language,omitempty"`
+	LanguageField   string             `json:"language_field,omitempty" yaml:"language_field,omitempty"`
+	Score           *float64           `json:"score,omitempty" yaml:"score,omitempty"`
+	ScoreField      string             `json:"score_field,omitempty" yaml:"score_field,omitempty"`
+	PayloadField    string             `json:"payload_field,omitempty" yaml:"payload_field,omitempty"`
+	MaxTextFields   bool               `json:"max_text_fields,omitempty" yaml:"max_text_fields,omitempty"`
+	NoOffsets       bool               `json:"no_offsets,omitempty" yaml:"no_offsets,omitempty"`
+	Temporary       uint64             `json:"temporary,omitempty" yaml:"temporary,omitempty"`
+	NoHighlight     bool               `json:"no_highlight,omitempty" yaml:"no_highlight,omitempty"`
+	NoFields        bool               `json:"no_fields,omitempty" yaml:"no_fields,omitempty"`
+	NoFreqs         bool               `json:"no_freqs,omitempty" yaml:"no_freqs,omitempty"`
+	StopWords       *[]string          `json:"stopwords,omitempty" yaml:"stopwords,omitempty"` // present means STOPWORDS is used
+	SkipInitialScan bool               `json:"skip_initial_scan,omitempty" yaml:"skip_initial_scan,omitempty"`
+	Schema          []encodedAttribute `json:"schema" yaml:"schema"`
+}
+
+// definitionDocument is the content of a definition file.
+type definitionDocument struct {
+	Name          string `yaml:"name"`
+	indexDocument `yaml:",inline"`
+}
+
+// encodedAttribute wraps a SchemaAttribute to add the type discriminator.
+type encodedAttribute struct {
+	SchemaAttribute
+}
+
+type attributeType struct {
+	Type string `json:"type" yaml:"type"`
+}
+
+// newAttribute returns an empty attribute of the given type.
+func newAttribute(attribType string) (SchemaAttribute, error) {
+	switch strings.ToLower(attribType) {
+	case "tag":
+		return &TagAttribute{}, nil
+	case "text":
+		return &TextAttribute{}, nil
+	case "numeric":
+		return &NumericAttribute{}, nil
+	case "geo":
+		return &GeoAttribute{}, nil
+	case "geometry":
+		return &GeometryAttribute{}, nil
+	case "vector":
+		return &VectorAttribute{}, nil
+	default:
+		return nil, fmt.Errorf("grsearch: unknown attribute type %q", attribType)
+	}
+}
+
+/******************************************************************************
+* IndexOptions encoding
+******************************************************************************/
+
+// MarshalJSON encodes the options using the keys of an index definition file.
+func (i *IndexOptions) MarshalJSON() ([]byte, error) {
+	return json.Marshal(i.document())
+}
+
+// UnmarshalJSON decodes options written by MarshalJSON. Values which aren't
+// given keep the defaults set by [NewIndexOptions].
+func (i *IndexOptions) UnmarshalJSON(data []byte) error {
+	doc := indexDocument{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	return i.fromDocument(&doc)
+}
+
+// MarshalYAML encodes the options using the keys of an index definition file.
+func (i *IndexOptions) MarshalYAML() (interface{}, error) {
+	return i.document(), nil
+}
+
+// UnmarshalYAML decodes options written by MarshalYAML. Values which aren't
+// given keep the defaults set by [NewIndexOptions].
+func (i *IndexOptions) UnmarshalYAML(node *yaml.Node) error {
+	doc := indexDocument{}
+	if err := node.Decode(&doc); err != nil {
+		return err
+	}
+	return i.fromDocument(&doc)
+}
+
+// document converts the options to their encoded form.
+func (i *IndexOptions) document() *indexDocument {
+	score := i.Score
+	doc := &indexDocument{
+		On:              strings.ToLower(i.On),
+		Prefix:          i.Prefix,
+		Filter:          i.Filter,
+		Language:        i.Language,
+		LanguageField:   i.LanguageField,
+		Score:           &score,
+		ScoreField:      i.ScoreField,
+		PayloadField:    i.PayloadField,
+		MaxTextFields:   i.MaxTextFields,
+		NoOffsets:       i.NoOffsets,
+		Temporary:       i.Temporary,
+		NoHighlight:     i.NoHighlight,
+		NoFields:        i.NoFields,
+		NoFreqs:         i.NoFreqs,
+		SkipInitialScan: i.SkipInitialscan,
+		Schema:          make([]encodedAttribute, len(i.Schema)),
+	}
+
+	if i.UseStopWords {
+		stopWords := i.StopWords
+		if stopWords == nil {
+			stopWords = []string{}
+		}
+		doc.StopWords = &stopWords
+	}
+
+	for n, a := range i.Schema {
+		doc.Schema[n] = encodedAttribute{a}
+	}
+
+	return doc
+}
+
+// fromDocument sets the options from their encoded form.
+func (i *IndexOptions) fromDocument(doc *indexDocument) error {
+	*i = *NewIndexOptions()
+
+	if doc.On != "" {
+		i.On = doc.On
+	}
+	if doc.Score != nil {
+		i.Score = *doc.Score
+	}
+	if doc.StopWords != nil {
+		i.UseStopWords = true
+		i.StopWords = *doc.StopWords
+	}
+	i.Prefix = doc.Prefix
+	i.Filter = doc.Filter
+	i.Language = doc.Language
+	i.LanguageField = doc.LanguageField
+	i.ScoreField = doc.ScoreField
+	i.PayloadField = doc.PayloadField
+	i.MaxTextFields = doc.MaxTextFields
+	i.NoOffsets = doc.NoOffsets
+	i.Temporary = doc.Temporary
+	i.NoHighlight = doc.NoHighlight
+	i.NoFields = doc.NoFields
+	i.NoFreqs = doc.NoFreqs
+	i.SkipInitialscan = doc.SkipInitialScan
+
+	i.Schema = make([]SchemaAttribute, len(doc.Schema))
+	for n, a := range doc.Schema {
+		if a.SchemaAttribute == nil {
+			return fmt.Errorf("grsearch: schema attribute %d is empty", n+1)
+		}
+		i.Schema[n] = a.SchemaAttribute
+	}
+
+	return nil
+}
+
+/******************************************************************************
+* Attribute encoding
+******************************************************************************/
+
+// MarshalJSON writes the attribute type followed by the attribute fields.
+func (e encodedAttribute) MarshalJSON() ([]byte, error) {
+	_, _, attribType := attributeIdentity(e.SchemaAttribute)
+	if attribType == "" {
+		return nil, fmt.Errorf("grsearch: unable to encode attribute of type %T", e.SchemaAttribute)
+	}
+
+	fields, err := json.Marshal(e.SchemaAttribute)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `{"type":"%s"`, strings.ToLower(attribType))
+	if len(fields) > 2 {
+		buf.WriteByte(',')
+	}
+	buf.Write(fields[1:])
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON reads the type and then decodes the fields into an attribute of that type.
+func (e *encodedAttribute) UnmarshalJSON(data []byte) error {
+	t := attributeType{}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return err
+	}
+	a, err := newAttribute(t.Type)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, a); err != nil {
+		return err
+	}
+	e.SchemaAttribute = a
+	return nil
+}
+
+// MarshalYAML writes the attribute type followed by the attribute fields.
+func (e encodedAttribute) MarshalYAML() (interface{}, error) {
+	_, _, attribType := attributeIdentity(e.SchemaAttribute)
+	if attribType == "" {
+		return nil, fmt.Errorf("grsearch: unable to encode attribute of type %T", e.SchemaAttribute)
+	}
+
+	node := &yaml.Node{}
+	if err := node.Encode(e.SchemaAttribute); err != nil {
+		return nil, err
+	}
+	node.Content = append([]*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: "type"},
+		{Kind: yaml.ScalarNode, Value: strings.ToLower(attribType)},
+	}, node.Content...)
+	return node, nil
+}
+
+// UnmarshalYAML reads the type and then decodes the fields into an attribute of that type.
+func (e *encodedAttribute) UnmarshalYAML(node *yaml.Node) error {
+	t := attributeType{}
+	if err := node.Decode(&t); err != nil {
+		return err
+	}
+	a, err := newAttribute(t.Type)
+	if err != nil {
+		return err
+	}
+	if err := node.Decode(a); err != nil {
+		return err
+	}
+	e.SchemaAttribute = a
+	return nil
+}
+
+/******************************************************************************
+* Definition files
+******************************************************************************/
+
+// ReadIndexDefinition reads an index definition from a YAML or JSON file (JSON
+// being a subset of YAML).
+func ReadIndexDefinition(path string) (*IndexDefinition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	named := struct {
+		Name string `yaml:"name"`
+	}{}
+	options := NewIndexOptions()
+	if err := yaml.Unmarshal(data, &named); err != nil {
+		return nil, fmt.Errorf("grsearch: %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, options); err != nil {
+		return nil, fmt.Errorf("grsearch: %s: %w", path, err)
+	}
+
+	if named.Name == "" {
+		named.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	return &IndexDefinition{Name: named.Name, File: path, Options: options}, nil
+}
+
+// ReadIndexDefinitions reads every .yaml, .yml and .json file in a directory, in name order.
+func ReadIndexDefinitions(dir string) ([]*IndexDefinition, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".json":
+			if !entry.IsDir() {
+				names = append(names, entry.Name())
+			}
+		}
+	}
+	sort.Strings(names)
+
+	definitions := make([]*IndexDefinition, 0, len(names))
+	seen := map[string]string{}
+	for _, name := range names {
+		def, err := ReadIndexDefinition(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := seen[def.Name]; ok {
+			return nil, fmt.Errorf("grsearch: index %s is defined in both %s and %s", def.Name, other, def.File)
+		}
+		seen[def.Name] = def.File
+		definitions = append(definitions, def)
+	}
+
+	return definitions, nil
+}
+
+// WriteIndexDefinition writes an index definition as YAML, including the index name.
+func WriteIndexDefinition(path, name string, options *IndexOptions) error {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(definitionDocument{Name: name, indexDocument: *options.document()}); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
@@ -0,0 +1,122 @@
+package grsearch_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	grsearch "github.com/goslogan/grsearch"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+var _ = Describe("Index definitions", Label("ft.create", "definitions"), func() {
+
+	definition := func() *grsearch.IndexOptions {
+		return grsearch.NewIndexBuilder().
+			On("hash").
+			Prefix("happly:").
+			Schema(&grsearch.TagAttribute{Name: "account_id", Alias: "id"}).
+			Schema(&grsearch.TextAttribute{Name: "customer", Weight: 2}).
+			Schema(&grsearch.NumericAttribute{Name: "balance", Sortable: true}).
+			Options()
+	}
+
+	withVector := func() *grsearch.IndexOptions {
+		options := definition()
+		options.Schema = append(options.Schema, &grsearch.VectorAttribute{Name: "embedding", Algorithm: "FLAT", Type: "FLOAT32", Dim: 4, DistanceMetric: "COSINE"})
+		return options
+	}
+
+	It("round trips index options through JSON", func() {
+		options := withVector()
+		options.UseStopWords = true
+		js, err := json.Marshal(options)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(js)).To(ContainSubstring(`{"type":"tag","name":"account_id","alias":"id"}`))
+		Expect(string(js)).To(ContainSubstring(`"stopwords":[]`))
+
+		decoded := &grsearch.IndexOptions{}
+		Expect(json.Unmarshal(js, decoded)).NotTo(HaveOccurred())
+		Expect(decoded.StopWords).To(BeEmpty())
+		decoded.StopWords = nil
+		Expect(decoded).To(Equal(options))
+	})
+
+	It("round trips index options through YAML", func() {
+		options := withVector()
+		y, err := yaml.Marshal(options)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(y)).To(ContainSubstring("- type: numeric\n      name: balance\n"))
+
+		decoded := &grsearch.IndexOptions{}
+		Expect(yaml.Unmarshal(y, decoded)).NotTo(HaveOccurred())
+		Expect(decoded).To(Equal(options))
+	})
+
+	It("rejects unknown attribute types", func() {
+		decoded := &grsearch.IndexOptions{}
+		err := yaml.Unmarshal([]byte("schema:\n  - {type: bogus, name: x}\n"), decoded)
+		Expect(err).To(MatchError(ContainSubstring(`unknown attribute type "bogus"`)))
+	})
+
+	It("reads definitions from a directory", func() {
+		dir := GinkgoT().TempDir()
+		Expect(grsearch.WriteIndexDefinition(filepath.Join(dir, "a.yaml"), "happly", definition())).NotTo(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(dir, "b.json"), []byte(`{"on":"json","schema":[{"type":"text","name":"$.name","alias":"name"}]}`), 0o644)).NotTo(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0o644)).NotTo(HaveOccurred())
+
+		definitions, err := grsearch.ReadIndexDefinitions(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(definitions).To(HaveLen(2))
+		Expect(definitions[0].Name).To(Equal("happly"))
+		Expect(definitions[0].Options).To(Equal(definition()))
+		Expect(definitions[1].Name).To(Equal("b"))
+		Expect(definitions[1].Options.On).To(Equal("json"))
+		Expect(definitions[1].Options.Score).To(Equal(1.0))
+	})
+
+	It("creates, alters and reports drift", func() {
+		dir := GinkgoT().TempDir()
+		file := filepath.Join(dir, "happly.yaml")
+		DeferCleanup(func() {
+			client.FTDropIndex(ctx, "happly", false)
+		})
+
+		Expect(grsearch.WriteIndexDefinition(file, "happly", definition())).NotTo(HaveOccurred())
+		changes, err := client.Apply(ctx, dir, &grsearch.ApplyOptions{DryRun: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes).To(HaveLen(1))
+		Expect(changes[0].Action).To(Equal(grsearch.ApplyCreated))
+		Expect(client.FTList(ctx).Val()).NotTo(ContainElement("happly"))
+
+		changes, err = client.Apply(ctx, dir, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes[0].Action).To(Equal(grsearch.ApplyCreated))
+
+		changes, err = client.Apply(ctx, dir, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes[0].Action).To(Equal(grsearch.ApplyUnchanged))
+		Expect(changes[0].Differences).To(BeEmpty())
+
+		altered := definition()
+		altered.Schema = append(altered.Schema, &grsearch.TagAttribute{Name: "country"})
+		Expect(grsearch.WriteIndexDefinition(file, "happly", altered)).NotTo(HaveOccurred())
+		changes, err = client.Apply(ctx, dir, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes[0].Action).To(Equal(grsearch.ApplyAltered))
+		Expect(changes[0].Added).To(Equal([]grsearch.SchemaAttribute{&grsearch.TagAttribute{Name: "country"}}))
+
+		drifted := definition()
+		drifted.Prefix = []string{"other:"}
+		Expect(grsearch.WriteIndexDefinition(file, "happly", drifted)).NotTo(HaveOccurred())
+		changes, err = client.Apply(ctx, dir, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes[0].Action).To(Equal(grsearch.ApplyDrift))
+		Expect(changes[0].Differences).To(ConsistOf(
+			`prefix: ["other:"], index has ["happly:"]`,
+			"attribute country: not in the definition",
+		))
+	})
+})
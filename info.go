@@ -2,7 +2,6 @@ package grsearch
 
 import (
 	"fmt"
-	"strconv"
 	"strings"
 	"time"
 
@@ -56,7 +55,27 @@ type Info struct {
 		Dialect1 int64
 		Dialect2 int64
 		Dialect3 int64
+		Dialect4 int64
 	}
+	IndexingFailures int64
+	TotalIndexMemory float64
+	GeoshapesSize    float64
+	IndexErrors      IndexErrors  // Indexing errors for the whole index
+	FieldStats       []FieldStats // Per attribute statistics, if the server reports them
+}
+
+// IndexErrors holds the indexing error statistics reported for an index or an attribute.
+type IndexErrors struct {
+	IndexingFailures int64
+	LastError        string // The last indexing error message, empty if there hasn't been one
+	LastErrorKey     string // The key of the document that caused the last error
+}
+
+// FieldStats holds the statistics reported for a single attribute.
+type FieldStats struct {
+	Identifier string
+	Attribute  string
+	Errors     IndexErrors
 }
 
 // parse takes the results of an FT.INFO command and creates and Info
@@ -83,6 +102,16 @@ func (info *Info) parse(result map[interface{}]interface{}) error {
 	info.AverageOffsetsPerTerm, _ = internal.Float64(result["offsets_per_term_avg"])
 	info.AverageOffsetBitsPerRecord, _ = internal.Float64(result["offset_bits_per_record_avg"])
 	info.NumberOfUses, _ = internal.Int64(result["number_of_uses"])
+	info.TotalIndexMemory, _ = internal.Float64(result["total_index_memory_sz_mb"])
+	info.GeoshapesSize, _ = internal.Float64(result["geoshapes_sz_mb"])
+	if _, ok := result["bytes_per_record_avg"]; !ok {
+		info.AverageBytesPerRecord, _ = internal.Float64(result["bytes_per_record"])
+	}
+	if v, ok := result["indexing_failures"]; ok {
+		info.IndexingFailures, _ = internal.Int64(v)
+	} else {
+		info.IndexingFailures = info.HashIndexingFailures
+	}
 
 	// Given no other evidence, we assume this is seconds
 	t, _ := internal.Float64(result["total_indexing_time"])
@@ -121,6 +150,42 @@ func (info *Info) parseIndexStats(result map[interface{}]interface{}) {
 	info.DialectStats.Dialect1, _ = internal.Int64(d["dialect_1"])
 	info.DialectStats.Dialect2, _ = internal.Int64(d["dialect_2"])
 	info.DialectStats.Dialect3, _ = internal.Int64(d["dialect_3"])
+	info.DialectStats.Dialect4, _ = internal.Int64(d["dialect_4"])
+
+	if section, ok := result["Index Errors"]; ok {
+		info.IndexErrors.parse(section)
+	}
+
+	if fields, ok := result["field statistics"].([]interface{}); ok {
+		info.FieldStats = make([]FieldStats, 0, len(fields))
+		for _, f := range fields {
+			field := internal.ToMap(f)
+			stats := FieldStats{}
+			stats.Identifier, _ = field["identifier"].(string)
+			stats.Attribute, _ = field["attribute"].(string)
+			stats.Errors.parse(field["Index Errors"])
+			info.FieldStats = append(info.FieldStats, stats)
+		}
+	}
+}
+
+// FailedFields returns the statistics for the attributes which have had indexing failures.
+func (info *Info) FailedFields() []FieldStats {
+	failed := []FieldStats{}
+	for _, f := range info.FieldStats {
+		if f.Errors.IndexingFailures > 0 {
+			failed = append(failed, f)
+		}
+	}
+	return failed
+}
+
+// parse reads an "Index Errors" section. The server reports N/A if there hasn't been an error.
+func (e *IndexErrors) parse(source interface{}) {
+	section := internal.ToMap(source)
+	e.IndexingFailures, _ = internal.Int64(section["indexing failures"])
+	e.LastError = nonDefault(section["last indexing error"], "N/A")
+	e.LastErrorKey = nonDefault(section["last indexing error key"], "N/A")
 }
 
 // Create IndexOptions from ft.info output
@@ -136,9 +201,53 @@ func (info *Info) parseIndexOptionsFromInfo(input map[interface{}]interface{}) e
 			i.On = "HASH"
 		}
 		i.Score, _ = internal.Float64(mapped["default_score"])
-		i.Prefix = make([]string, len(mapped["prefixes"].([]interface{})))
-		for n, p := range mapped["prefixes"].([]interface{}) {
-			i.Prefix[n] = p.(string)
+		if prefixes, ok := mapped["prefixes"].([]interface{}); ok {
+			i.Prefix = make([]string, len(prefixes))
+			for n, p := range prefixes {
+				i.Prefix[n] = p.(string)
+			}
+		}
+		i.Filter, _ = mapped["filter"].(string)
+		// FT.INFO reports the default values for these so we drop them to get the
+		// options as they would have been given to FT.CREATE.
+		i.Language = nonDefault(mapped["default_language"], "english")
+		i.LanguageField = nonDefault(mapped["language_field"], "__language")
+		i.ScoreField = nonDefault(mapped["score_field"], "__score")
+		i.PayloadField = nonDefault(mapped["payload_field"], "__payload")
+	}
+
+	if data, ok := input["index_options"].([]interface{}); ok {
+		for n, o := range data {
+			option, _ := o.(string)
+			switch strings.ToUpper(option) {
+			case "NOOFFSETS":
+				i.NoOffsets = true
+			case "NOHL":
+				i.NoHighlight = true
+			case "NOFIELDS":
+				i.NoFields = true
+			case "NOFREQS":
+				i.NoFreqs = true
+			case "MAXTEXTFIELDS":
+				i.MaxTextFields = true
+			case "SKIPINITIALSCAN":
+				i.SkipInitialscan = true
+			case "TEMPORARY":
+				// the expiry may follow the option; if it isn't reported it can't be recovered
+				if n+1 < len(data) {
+					if seconds, err := internal.Int64(data[n+1]); err == nil && seconds > 0 {
+						i.Temporary = uint64(seconds)
+					}
+				}
+			}
+		}
+	}
+
+	if data, ok := input["stopwords_list"].([]interface{}); ok {
+		i.UseStopWords = true
+		i.StopWords = make([]string, len(data))
+		for n, w := range data {
+			i.StopWords[n], _ = w.(string)
 		}
 	}
 
@@ -167,7 +276,7 @@ func (info *Info) parseIndexOptionsFromInfo(input map[interface{}]interface{}) e
 			case "vector":
 				attribute = &VectorAttribute{}
 			default:
-				return fmt.Errorf("grsearch: unhandled attribute type: %s", attribInfo[5].(string))
+				return fmt.Errorf("grsearch: unhandled attribute type: %s", attribType)
 			}
 			if attribInfo != nil {
 				attribute.parseFromInfo(attribInfo)
@@ -180,38 +289,55 @@ func (info *Info) parseIndexOptionsFromInfo(input map[interface{}]interface{}) e
 	return nil
 }
 
+// nonDefault returns the value as a string unless it is the default for the option.
+func nonDefault(value interface{}, defaultValue string) string {
+	s, _ := value.(string)
+	if strings.EqualFold(s, defaultValue) {
+		return ""
+	}
+	return s
+}
+
+// attributeFlags are the attribute options FT.INFO reports without a value.
+var attributeFlags = map[string]bool{
+	"sortable":       true,
+	"unf":            true,
+	"nostem":         true,
+	"noindex":        true,
+	"casesensitive":  true,
+	"withsuffixtrie": true,
+}
+
 // attribInfoMap converts the attribute definition from FT.INFO into a
-// a map, handling the use of SORTABLE and UNF in RESP2 and RESP3 properly
+// a map, handling the use of flags (SORTABLE, UNF, NOSTEM and so on) in
+// RESP2 and RESP3 properly
 func (info *Info) attribInfoMap(result interface{}) map[interface{}]interface{} {
 
 	var attrib map[interface{}]interface{}
+	var flags []interface{}
 
 	switch val := result.(type) {
 	case []interface{}: // RESP2
-		attrib = internal.ToMap(result)
-		for _, item := range val {
-			if s, ok := item.(string); ok {
-				if strings.ToLower(s) == "sortable" {
-					attrib["sortable"] = true
-				}
-				if strings.ToLower(s) == "unf" {
-					attrib["unf"] = true
-				}
+		// flags have no value so pairing up the array only works for the values before them
+		attrib = map[interface{}]interface{}{}
+		for n := 0; n < len(val); n++ {
+			if s, ok := val[n].(string); ok && attributeFlags[strings.ToLower(s)] {
+				flags = append(flags, s)
+				continue
+			}
+			if n+1 < len(val) {
+				attrib[val[n]] = val[n+1]
+				n++
 			}
 		}
 	case map[interface{}]interface{}:
 		attrib = val
-		if flags, ok := val["flags"]; ok {
-			for _, a := range flags.([]interface{}) {
-				if s, ok := a.(string); ok {
-					if strings.ToLower(s) == "sortable" {
-						attrib["sortable"] = true
-					}
-					if strings.ToLower(s) == "unf" {
-						attrib["unf"] = true
-					}
-				}
-			}
+		flags, _ = val["flags"].([]interface{})
+	}
+
+	for _, f := range flags {
+		if s, ok := f.(string); ok {
+			attrib[strings.ToLower(s)] = true
 		}
 	}
 
@@ -314,36 +440,40 @@ func (a *GeoAttribute) parseFromInfo(source map[interface{}]interface{}) {
 }
 
 func (a *VectorAttribute) parseFromInfo(source map[interface{}]interface{}) {
+	toUint := func(val interface{}) uint64 {
+		i, _ := internal.Int64(val)
+		return uint64(i)
+	}
+
 	for key, val := range source {
 
 		switch strings.ToLower(key.(string)) {
+		case "identifier":
+			a.Name = val.(string)
+		case "attribute":
+			a.Alias = val.(string)
+		case "algorithm":
+			a.Algorithm, _ = val.(string)
 		case "flat", "hnsw":
 			a.Algorithm = key.(string)
-		case "type":
-			a.Type = strings.ToLower(val.(string))
+		case "data_type":
+			a.Type, _ = val.(string)
 		case "dim":
-			i, _ := strconv.ParseUint(val.(string), 10, 64)
-			a.Dim = i
+			a.Dim = toUint(val)
 		case "distance_metric":
-			a.DistanceMetric = strings.ToLower(val.(string))
+			a.DistanceMetric, _ = val.(string)
 		case "initial_cap":
-			cap, _ := strconv.ParseUint(val.(string), 10, 64)
-			a.InitialCap = cap
+			a.InitialCap = toUint(val)
 		case "block_size":
-			size, _ := strconv.ParseUint(val.(string), 10, 64)
-			a.BlockSize = size
+			a.BlockSize = toUint(val)
 		case "m":
-			m, _ := strconv.ParseUint(val.(string), 10, 64)
-			a.M = m
+			a.M = toUint(val)
 		case "ef_construction":
-			ef, _ := strconv.ParseUint(val.(string), 10, 64)
-			a.EFConstruction = ef
+			a.EFConstruction = toUint(val)
 		case "ef_runtime":
-			ef, _ := strconv.ParseUint(val.(string), 10, 64)
-			a.EFRuntime = ef
+			a.EFRuntime = toUint(val)
 		case "epsilon":
-			ef, _ := strconv.ParseFloat(val.(string), 64)
-			a.Epsilon = ef
+			a.Epsilon, _ = internal.Float64(val)
 		}
 	}
 }
@@ -66,6 +66,40 @@ func StringToDurationHookFunc() mapstructure.DecodeHookFunc {
 	}
 }
 
+// NumberHookFunc returns a function that decodes numbers consistently whether they were read
+// as strings (RESP2) or numbers (RESP3). Integers may be written as floats (1e+06, 3.0) as long as
+// they have no fractional part, and floats may be inf, -inf or nan.
+func NumberHookFunc() mapstructure.DecodeHookFunc {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+
+		switch t.Kind() {
+		case reflect.Int, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int8,
+			reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint8:
+			switch v := data.(type) {
+			case string:
+				if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+					return i, nil
+				}
+				n, err := strconv.ParseFloat(v, 64)
+				if err != nil || n != float64(int64(n)) {
+					return nil, fmt.Errorf("redis: unable to convert %q to an integer", v)
+				}
+				return int64(n), nil
+			case float64:
+				if v != float64(int64(v)) {
+					return nil, fmt.Errorf("redis: unable to convert %v to an integer", v)
+				}
+				return int64(v), nil
+			}
+		case reflect.Float32, reflect.Float64:
+			if v, ok := data.(string); ok {
+				return strconv.ParseFloat(v, 64)
+			}
+		}
+		return data, nil
+	}
+}
+
 // SliceToMapHookFunc returns a function that converts a slice to a map[string]interface{}
 // if and only if the output is struct
 func StringToMapHookFunc() mapstructure.DecodeHookFunc {
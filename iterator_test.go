@@ -1,6 +1,8 @@
 package grsearch_test
 
 import (
+	"context"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
@@ -72,3 +74,199 @@ var _ = Describe("Iterator", Label("search", "hash", "ft.search", "iterator"), f
 	})
 
 })
+
+var _ = Describe("Search after iterator", Label("search", "hash", "ft.search", "iterator", "searchafter"), func() {
+
+	It("can return sort keys", func() {
+		options := grsearch.NewQueryBuilder().
+			SortBy("balance").
+			WithSortKeys().
+			Options()
+		cmd := client.FTSearchHash(ctx, "hcustomers", `@id:{1121175}`, options)
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		Expect(cmd.Val()).To(HaveLen(1))
+		Expect(cmd.Val()[0].SortKey).To(Equal("#927"))
+		Expect(cmd.Val()[0].Values["balance"]).To(Equal("927.00"))
+	})
+
+	It("will fail if the search is not sorted", func() {
+		cmd := client.FTSearchHash(ctx, "hcustomers", `@country:{UK}`, nil)
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		iterator := cmd.SearchAfter(ctx)
+		Expect(iterator.Next(ctx)).To(BeFalse())
+		Expect(iterator.Err()).To(MatchError(grsearch.ErrSortByRequired))
+	})
+
+	It("will fail if the command has no options", func() {
+		cmd := grsearch.NewQueryCmd(ctx, nil, true, "FT.SEARCH", "hcustomers", "*")
+		iterator := cmd.SearchAfter(ctx)
+		Expect(iterator.Next(ctx)).To(BeFalse())
+		Expect(iterator.Err()).To(MatchError(grsearch.ErrSortByRequired))
+	})
+
+	It("can iterate over a search sorted on a text field", func() {
+		all := grsearch.NewQueryBuilder().
+			SortBy("email").
+			NoContent().
+			Limit(0, 100).
+			Options()
+		expected := client.FTSearchHash(ctx, "hcustomers", `*`, all)
+		Expect(expected.Err()).NotTo(HaveOccurred())
+
+		options := grsearch.NewQueryBuilder().
+			SortBy("email").
+			NoContent().
+			Limit(0, 4).
+			Options()
+		cmd := client.FTSearchHash(ctx, "hcustomers", `*`, options)
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		iterator := cmd.SearchAfter(ctx)
+		keys := []string{}
+		for iterator.Next(ctx) {
+			keys = append(keys, iterator.Val().Key)
+		}
+		Expect(iterator.Err()).NotTo(HaveOccurred())
+		Expect(keys).To(Equal(expected.Keys()))
+	})
+
+	It("can iterate over a search returned in multiple calls", func() {
+		options := grsearch.NewQueryOptions()
+		options.SortBy = "balance"
+		options.Limit = &grsearch.Limit{Offset: 0, Num: 2}
+		cmd := client.FTSearchHash(ctx, "hcustomers", `@country:{UK}`, options)
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		iterator := cmd.SearchAfter(ctx)
+		keys := []string{}
+		for iterator.Next(ctx) {
+			keys = append(keys, iterator.Val().Key)
+		}
+		Expect(iterator.Err()).NotTo(HaveOccurred())
+		Expect(keys).To(HaveLen(4))
+		Expect(keys[0]).To(Equal("haccount:1888382"))
+		Expect(keys[1:3]).To(ConsistOf("haccount:419113", "haccount:1371128"))
+		Expect(keys[3]).To(Equal("haccount:1952347"))
+	})
+
+	It("can iterate in descending order with many equal sort keys", func() {
+		options := grsearch.NewQueryBuilder().
+			SortBy("balance").
+			Descending().
+			NoContent().
+			WithSortKeys().
+			Limit(0, 3).
+			Options()
+		cmd := client.FTSearchHash(ctx, "hcustomers", `*`, options)
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		iterator := cmd.SearchAfter(ctx)
+		keys := []string{}
+		for iterator.Next(ctx) {
+			keys = append(keys, iterator.Val().Key)
+		}
+		Expect(iterator.Err()).NotTo(HaveOccurred())
+		Expect(keys).To(HaveLen(25))
+		Expect(keys[0]).To(Equal("haccount:1952347"))
+		Expect(keys[24]).To(Equal("haccount:376460"))
+	})
+
+})
+
+var _ = Describe("Prefetch iterator", Label("search", "hash", "ft.search", "iterator", "prefetch"), func() {
+
+	It("returns the same results in the same order as a single search", func() {
+		all := grsearch.NewQueryBuilder().
+			SortBy("email").
+			NoContent().
+			Limit(0, 100).
+			Options()
+		expected := client.FTSearchHash(ctx, "hcustomers", `*`, all)
+		Expect(expected.Err()).NotTo(HaveOccurred())
+
+		options := grsearch.NewQueryBuilder().
+			SortBy("email").
+			NoContent().
+			Limit(0, 4).
+			Options()
+		cmd := client.FTSearchHash(ctx, "hcustomers", `*`, options)
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		iterator := cmd.Prefetch(ctx, 3)
+		defer iterator.Close()
+		keys := []string{}
+		for iterator.Next(ctx) {
+			keys = append(keys, iterator.Val().Key)
+		}
+		Expect(iterator.Err()).NotTo(HaveOccurred())
+		Expect(keys).To(Equal(expected.Keys()))
+	})
+
+	It("can be created for a command without options", func() {
+		cmd := grsearch.NewQueryCmd(ctx, nil, true, "FT.SEARCH", "hcustomers", "*")
+		iterator := cmd.Prefetch(ctx, 2)
+		defer iterator.Close()
+		Expect(iterator.Next(ctx)).To(BeFalse())
+		Expect(iterator.Err()).NotTo(HaveOccurred())
+	})
+
+	It("stops when the context is cancelled", func() {
+		options := grsearch.NewQueryBuilder().
+			SortBy("email").
+			NoContent().
+			Limit(0, 2).
+			Options()
+		cmd := client.FTSearchHash(ctx, "hcustomers", `*`, options)
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		cctx, cancel := context.WithCancel(ctx)
+		iterator := cmd.Prefetch(cctx, 2)
+		Expect(iterator.Next(cctx)).To(BeTrue())
+		cancel()
+		Expect(iterator.Next(cctx)).To(BeTrue())
+		Expect(iterator.Next(cctx)).To(BeFalse())
+		Expect(iterator.Err()).To(MatchError(context.Canceled))
+	})
+
+	It("can be read with a different context from the iterator's", func() {
+		options := grsearch.NewQueryBuilder().
+			SortBy("email").
+			NoContent().
+			Limit(0, 4).
+			Options()
+		cmd := client.FTSearchHash(ctx, "hcustomers", `*`, options)
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		cctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		iterator := cmd.Prefetch(cctx, 2)
+		count := 0
+		for iterator.Next(context.Background()) {
+			count++
+		}
+		Expect(iterator.Err()).NotTo(HaveOccurred())
+		Expect(count).To(Equal(25))
+	})
+
+	It("stops when the iterator's context is cancelled with pages still unscheduled", func() {
+		options := grsearch.NewQueryBuilder().
+			SortBy("email").
+			NoContent().
+			Limit(0, 1).
+			Options()
+		cmd := client.FTSearchHash(ctx, "hcustomers", `*`, options)
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		cctx, cancel := context.WithCancel(ctx)
+		iterator := cmd.Prefetch(cctx, 1)
+		Expect(iterator.Next(context.Background())).To(BeTrue())
+		cancel()
+
+		done := make(chan int)
+		go func() {
+			count := 1
+			for iterator.Next(context.Background()) {
+				count++
+			}
+			done <- count
+		}()
+		var count int
+		Eventually(done).Should(Receive(&count))
+		Expect(count).To(BeNumerically("<", 25))
+		Expect(iterator.Err()).To(MatchError(context.Canceled))
+	})
+
+})
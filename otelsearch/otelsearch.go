@@ -0,0 +1,353 @@
+// Package otelsearch traces RediSearch and RedisJSON commands with [OpenTelemetry] and records
+// their latency. It is kept separate from grsearch so that the OpenTelemetry dependency is only
+// needed if it is used.
+//
+// The hook is added with [grsearch.Client.AddHook] so that it sees parsed results and covers
+// commands run in pipelines as well as single commands:
+//
+//	hook, err := otelsearch.NewHook(nil)
+//	if err != nil {
+//		...
+//	}
+//	client.AddHook(hook)
+//
+// Each FT.* and JSON.* command gets a client span named after the command with the index (or
+// JSON key), query, dialect and limit as attributes and, once the command completes, the number
+// of results, the total number of results and any warnings. Other commands are passed through
+// untouched so the hook can be combined with the go-redis instrumentation.
+//
+// [OpenTelemetry]: https://opentelemetry.io/
+package otelsearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goslogan/grsearch"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/goslogan/grsearch/otelsearch"
+
+// Attributes added to spans. The index and pipeline attributes are also used for the latency histogram.
+const (
+	IndexKey        = attribute.Key("redisearch.index")
+	QueryKey        = attribute.Key("redisearch.query")
+	DialectKey      = attribute.Key("redisearch.dialect")
+	OffsetKey       = attribute.Key("redisearch.limit.offset")
+	LimitKey        = attribute.Key("redisearch.limit.num")
+	ResultsKey      = attribute.Key("redisearch.results")
+	TotalResultsKey = attribute.Key("redisearch.total_results")
+	WarningsKey     = attribute.Key("redisearch.warnings")
+	TimedOutKey     = attribute.Key("redisearch.timed_out")
+	PipelineKey     = attribute.Key("redisearch.pipeline")
+	JSONKey         = attribute.Key("redisjson.key")
+)
+
+// Options configures a [Hook].
+type Options struct {
+	TracerProvider trace.TracerProvider // Defaults to the global tracer provider
+	MeterProvider  metric.MeterProvider // Defaults to the global meter provider
+	// Query formats the query recorded on spans. The query is recorded unchanged if this is nil.
+	// Use [Redact] to hide the terms and values in queries or return "" to leave them out.
+	Query func(query string) string
+}
+
+// Hook is a [redis.Hook] tracing search and JSON commands.
+type Hook struct {
+	tracer   trace.Tracer
+	duration metric.Float64Histogram
+	query    func(string) string
+}
+
+var _ redis.Hook = (*Hook)(nil)
+
+// NewHook returns a hook using the given options, which may be nil.
+func NewHook(options *Options) (*Hook, error) {
+	if options == nil {
+		options = &Options{}
+	}
+	tracerProvider, meterProvider := options.TracerProvider, options.MeterProvider
+	if tracerProvider == nil {
+		tracerProvider = otel.GetTracerProvider()
+	}
+	if meterProvider == nil {
+		meterProvider = otel.GetMeterProvider()
+	}
+
+	duration, err := meterProvider.Meter(instrumentationName).Float64Histogram("redisearch.client.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of search and JSON commands."))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Hook{
+		tracer:   tracerProvider.Tracer(instrumentationName),
+		duration: duration,
+		query:    options.Query,
+	}, nil
+}
+
+// DialHook implements [redis.Hook].
+func (h *Hook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+// ProcessHook implements [redis.Hook].
+func (h *Hook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if !traced(cmd) {
+			return next(ctx, cmd)
+		}
+
+		start := time.Now()
+		ctx, span := h.start(ctx, cmd, start, false)
+		err := next(ctx, cmd)
+		h.end(ctx, span, cmd, start, time.Now(), false)
+		return err
+	}
+}
+
+// ProcessPipelineHook implements [redis.Hook]. Each search command in the pipeline gets its own
+// span covering the whole pipeline.
+func (h *Hook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		spans := map[redis.Cmder]trace.Span{}
+		for _, cmd := range cmds {
+			if traced(cmd) {
+				_, spans[cmd] = h.start(ctx, cmd, start, true)
+			}
+		}
+		if len(spans) == 0 {
+			return next(ctx, cmds)
+		}
+
+		err := next(ctx, cmds)
+		end := time.Now()
+		for _, cmd := range cmds {
+			if span, ok := spans[cmd]; ok {
+				h.end(ctx, span, cmd, start, end, true)
+			}
+		}
+		return err
+	}
+}
+
+// traced reports whether a command is a search or JSON command.
+func traced(cmd redis.Cmder) bool {
+	name := cmd.Name()
+	return strings.HasPrefix(name, "ft.") || strings.HasPrefix(name, "json.")
+}
+
+// start starts the span for a command and sets the attributes known before it runs.
+func (h *Hook) start(ctx context.Context, cmd redis.Cmder, start time.Time, pipeline bool) (context.Context, trace.Span) {
+	operation := strings.ToUpper(cmd.Name())
+	attributes := []attribute.KeyValue{
+		semconv.DBSystemRedis,
+		semconv.DBOperation(operation),
+	}
+	if pipeline {
+		attributes = append(attributes, PipelineKey.Bool(true))
+	}
+
+	args := cmd.Args()
+	if strings.HasPrefix(operation, "JSON.") {
+		if len(args) > 1 {
+			attributes = append(attributes, JSONKey.String(fmt.Sprint(args[1])))
+		}
+	} else if index := indexArg(operation, args); index != "" {
+		attributes = append(attributes, IndexKey.String(index))
+	}
+
+	if query, ok := queryArg(operation, args); ok {
+		if h.query != nil {
+			query = h.query(query)
+		}
+		if query != "" {
+			attributes = append(attributes, QueryKey.String(query))
+		}
+	}
+
+	switch c := cmd.(type) {
+	case *grsearch.QueryCmd:
+		if options := c.Options(); options != nil {
+			if options.Dialect != 0 {
+				attributes = append(attributes, DialectKey.Int(int(options.Dialect)))
+			}
+			limit := options.Limit
+			if limit == nil {
+				limit = grsearch.NewLimit(grsearch.DefaultOffset, grsearch.DefaultLimit)
+			}
+			attributes = append(attributes, OffsetKey.Int64(limit.Offset), LimitKey.Int64(limit.Num))
+		}
+	case *grsearch.AggregateCmd:
+		if options := c.Options(); options != nil && options.Dialect != 0 {
+			attributes = append(attributes, DialectKey.Int(int(options.Dialect)))
+		}
+	}
+
+	return h.tracer.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(attributes...))
+}
+
+// end records the outcome of a command, ends its span and records its duration.
+func (h *Hook) end(ctx context.Context, span trace.Span, cmd redis.Cmder, start, end time.Time, pipeline bool) {
+	var warnings []interface{}
+	switch c := cmd.(type) {
+	case *grsearch.QueryCmd:
+		span.SetAttributes(ResultsKey.Int(len(c.Val())), TotalResultsKey.Int64(c.TotalResults()))
+		if data := c.RESP3Data(); data != nil {
+			warnings = data.Warnings
+		}
+	case *grsearch.AggregateCmd:
+		span.SetAttributes(ResultsKey.Int(len(c.Val())))
+		if data := c.RESP3Data(); data != nil {
+			warnings = data.Warnings
+		}
+	}
+
+	timedOut := false
+	if len(warnings) > 0 {
+		messages := make([]string, len(warnings))
+		for n, w := range warnings {
+			messages[n] = fmt.Sprint(w)
+			timedOut = timedOut || isTimeout(messages[n])
+		}
+		span.SetAttributes(WarningsKey.StringSlice(messages))
+	}
+
+	if err := cmd.Err(); err != nil && !errors.Is(err, redis.Nil) {
+		timedOut = timedOut || isTimeout(err.Error())
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	if timedOut {
+		span.SetAttributes(TimedOutKey.Bool(true))
+	}
+	span.End(trace.WithTimestamp(end))
+
+	attributes := []attribute.KeyValue{semconv.DBOperation(strings.ToUpper(cmd.Name()))}
+	if index := indexArg(strings.ToUpper(cmd.Name()), cmd.Args()); index != "" {
+		attributes = append(attributes, IndexKey.String(index))
+	}
+	if pipeline {
+		attributes = append(attributes, PipelineKey.Bool(true))
+	}
+	h.duration.Record(ctx, end.Sub(start).Seconds(), metric.WithAttributes(attributes...))
+}
+
+// isTimeout reports whether a warning or error is the server's query timeout.
+func isTimeout(message string) bool {
+	return strings.Contains(strings.ToLower(message), "timeout")
+}
+
+// indexArg returns the index used by an FT command, or "" for commands not using one.
+func indexArg(operation string, args []interface{}) string {
+	position := 1
+	switch operation {
+	case "FT._LIST", "FT.CONFIG", "FT.DICTADD", "FT.DICTDEL", "FT.DICTDUMP", "FT.ALIASDEL":
+		return ""
+	case "FT.ALIASADD", "FT.ALIASUPDATE", "FT.CURSOR":
+		position = 2
+	}
+	if position < len(args) {
+		return fmt.Sprint(args[position])
+	}
+	return ""
+}
+
+// queryArg returns the query of commands which have one.
+func queryArg(operation string, args []interface{}) (string, bool) {
+	switch operation {
+	case "FT.SEARCH", "FT.AGGREGATE", "FT.EXPLAIN", "FT.EXPLAINCLI", "FT.SPELLCHECK":
+		if len(args) > 2 {
+			return fmt.Sprint(args[2]), true
+		}
+	case "FT.PROFILE":
+		for n := 3; n < len(args)-1; n++ {
+			if strings.EqualFold(fmt.Sprint(args[n]), "query") {
+				return fmt.Sprint(args[n+1]), true
+			}
+		}
+	}
+	return "", false
+}
+
+// Redact replaces the terms, phrases, tags, ranges and values in a query with "?", keeping
+// attribute names, parameter references and operators, so that
+//
+//	@name:(john|"jane doe") @age:[18 +inf] @tags:{a|b}
+//
+// becomes
+//
+//	@name:(?|"?") @age:[?] @tags:{?}
+func Redact(query string) string {
+	var b strings.Builder
+	runes := []rune(query)
+
+	// skipTo returns the position of the closing rune, allowing for escapes.
+	skipTo := func(n int, closing rune) int {
+		for ; n < len(runes) && runes[n] != closing; n++ {
+			if runes[n] == '\\' {
+				n++
+			}
+		}
+		return n
+	}
+
+	for n := 0; n < len(runes); n++ {
+		r := runes[n]
+		switch {
+		case r == '@' || r == '$':
+			b.WriteRune(r)
+			for n+1 < len(runes) && isNameRune(runes[n+1]) {
+				n++
+				b.WriteRune(runes[n])
+			}
+		case r == '"' || r == '\'':
+			n = skipTo(n+1, r)
+			b.WriteString(string([]rune{r, '?', r}))
+		case r == '{':
+			n = skipTo(n+1, '}')
+			b.WriteString("{?}")
+		case r == '[':
+			n = skipTo(n+1, ']')
+			b.WriteString("[?]")
+		case isTermRune(r):
+			for n < len(runes) && isTermRune(runes[n]) {
+				if runes[n] == '\\' {
+					n++
+				}
+				n++
+			}
+			n--
+			b.WriteRune('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+func isNameRune(r rune) bool {
+	return r == '_' || r == '.' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
+
+// isTermRune reports whether a rune can be part of a term, escapes included.
+func isTermRune(r rune) bool {
+	return !strings.ContainsRune(" \t\r\n()|-~*%:@$\"'{}[]=<>!,;", r)
+}
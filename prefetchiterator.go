@@ -0,0 +1,199 @@
+package grsearch
+
+// prefetching - SearchIterator only fetches the next page once the current one has been read. For
+// large exports we know how many results there are (TotalResults) after the first search so we can
+// work out the offset of every page up front and fetch several of them concurrently.
+// How...
+//  1 the first page is the one already held by the QueryCmd.
+//  2 a scheduler goroutine starts a fetch for each remaining page in order, but only when it can
+//    take a token from a channel holding one token per page we allow to be fetched ahead.
+//  3 each page is delivered on its own channel so Next can read them back in order. A token is
+//    returned as soon as Next takes a page which was fetched.
+//  4 the first error cancels all outstanding fetches and is reported by Err. Pages which were
+//    never scheduled are delivered with the error but without a token, so Next must not wait
+//    for one.
+
+import (
+	"context"
+	"sync"
+)
+
+// PrefetchIterator is used to iterate over the results of a search, fetching pages ahead of the
+// caller with a bounded number of concurrent searches.
+type PrefetchIterator struct {
+	options  *QueryOptions
+	index    string
+	query    string
+	onHash   bool
+	process  cmdable
+	offset   int64
+	cancel   context.CancelFunc
+	tokens   chan struct{}
+	pages    []chan prefetchPage
+	page     int
+	current  []*SearchResult
+	pos      int
+	val      *SearchResult
+	err      error
+	errOnce  sync.Once
+	firstErr error
+}
+
+type prefetchPage struct {
+	results   []*SearchResult
+	err       error
+	scheduled bool // a token was taken to fetch the page
+}
+
+// NewPrefetchIterator returns a configured prefetching iterator for QueryCmd. No more than ahead
+// pages will be fetched (or held) before they are read. The default options are used if the command
+// has none, as they are by [NewSearchIterator].
+func NewPrefetchIterator(ctx context.Context, cmd *QueryCmd, process cmdable, ahead int) *PrefetchIterator {
+	options := *NewQueryOptions()
+	if cmd.options != nil {
+		options = *cmd.options
+	}
+	options.rewritten = cmd.rewritten
+	if options.Limit == nil || options.Limit.Num <= 0 {
+		options.Limit = NewLimit(DefaultOffset, DefaultLimit)
+	}
+	if ahead < 1 {
+		ahead = 1
+	}
+
+	it := &PrefetchIterator{
+		options: &options,
+		index:   cmd.Args()[1].(string),
+		query:   cmd.Args()[2].(string),
+		onHash:  cmd.onHash,
+		process: process,
+		offset:  options.Limit.Offset,
+		current: cmd.Val(),
+		tokens:  make(chan struct{}, ahead),
+	}
+
+	if cmd.Err() != nil {
+		it.err = cmd.Err()
+		return it
+	}
+
+	remaining := cmd.TotalResults() - options.Limit.Offset - int64(len(cmd.Val()))
+	if remaining > 0 && int64(len(cmd.Val())) == options.Limit.Num {
+		count := (remaining + options.Limit.Num - 1) / options.Limit.Num
+		it.pages = make([]chan prefetchPage, count)
+		for n := range it.pages {
+			it.pages[n] = make(chan prefetchPage, 1)
+		}
+	}
+
+	ctx, it.cancel = context.WithCancel(ctx)
+	go it.schedule(ctx)
+
+	return it
+}
+
+// Err returns the first error seen by the iterator, if any.
+func (it *PrefetchIterator) Err() error {
+	return it.err
+}
+
+// Next advances the cursor and returns true if more values can be read.
+func (it *PrefetchIterator) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+
+	for {
+		if it.pos < len(it.current) {
+			it.val = it.current[it.pos]
+			it.pos++
+			return true
+		}
+
+		it.val = nil
+		if it.page >= len(it.pages) {
+			it.Close()
+			return false
+		}
+
+		if err := ctx.Err(); err != nil {
+			it.err = err
+			it.Close()
+			return false
+		}
+
+		select {
+		case p := <-it.pages[it.page]:
+			if p.scheduled {
+				<-it.tokens
+			}
+			it.page++
+			if p.err != nil {
+				it.err = it.firstErr
+				it.Close()
+				return false
+			}
+			it.current = p.results
+			it.pos = 0
+		case <-ctx.Done():
+			it.err = ctx.Err()
+			it.Close()
+			return false
+		}
+	}
+}
+
+// Val returns the result at the current cursor position.
+func (it *PrefetchIterator) Val() *SearchResult {
+	return it.val
+}
+
+// Close stops any outstanding fetches. It must be called if the caller stops
+// iterating before Next returns false.
+func (it *PrefetchIterator) Close() {
+	if it.cancel != nil {
+		it.cancel()
+	}
+}
+
+// schedule starts a fetch for each page in turn, waiting for a token before each one.
+func (it *PrefetchIterator) schedule(ctx context.Context) {
+	for n := range it.pages {
+		select {
+		case it.tokens <- struct{}{}:
+			go it.fetch(ctx, n)
+		case <-ctx.Done():
+			it.fail(ctx.Err())
+			for ; n < len(it.pages); n++ {
+				it.pages[n] <- prefetchPage{err: ctx.Err()}
+			}
+			return
+		}
+	}
+}
+
+// fetch runs the search for a single page.
+func (it *PrefetchIterator) fetch(ctx context.Context, n int) {
+	options := *it.options
+	options.Limit = NewLimit(it.offset+int64(n+1)*it.options.Limit.Num, it.options.Limit.Num)
+
+	var cmd *QueryCmd
+	if it.onHash {
+		cmd = it.process.FTSearchHash(ctx, it.index, it.query, &options)
+	} else {
+		cmd = it.process.FTSearchJSON(ctx, it.index, it.query, &options)
+	}
+
+	if cmd.Err() != nil {
+		it.fail(cmd.Err())
+	}
+	it.pages[n] <- prefetchPage{results: cmd.Val(), err: cmd.Err(), scheduled: true}
+}
+
+// fail records the first error and cancels everything else.
+func (it *PrefetchIterator) fail(err error) {
+	it.errOnce.Do(func() {
+		it.firstErr = err
+		it.cancel()
+	})
+}
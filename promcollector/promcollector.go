@@ -0,0 +1,252 @@
+// Package promcollector exports RediSearch index statistics from FT.INFO as [Prometheus] metrics.
+// It is kept separate from grsearch so that the Prometheus dependency is only needed if it is used.
+//
+// The collector reads FT.LIST and FT.INFO in the background (see [Collector.Run]) and serves the
+// last values read when it is scraped so that scrapes never wait on Redis. Every metric has an
+// index label. Sizes are converted from the megabytes reported by FT.INFO to bytes. An index
+// which can't be read is left out of a refresh and counted by index_refresh_failures_total,
+// whilst the other indexes are still reported.
+//
+// [Prometheus]: https://prometheus.io/
+package promcollector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/goslogan/grsearch"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	DefaultNamespace = "redisearch"     // default prefix for the metric names
+	DefaultInterval  = 30 * time.Second // default time between refreshes
+)
+
+// Options configures a [Collector].
+type Options struct {
+	Namespace   string            // Metric name prefix, defaults to [DefaultNamespace]
+	Interval    time.Duration     // Time between refreshes in Run, defaults to [DefaultInterval]
+	Indexes     []string          // Indexes to report. If empty every index returned by FT.LIST is reported
+	ConstLabels prometheus.Labels // Labels added to every metric, for example to identify the server
+}
+
+// Collector is a [prometheus.Collector] reporting the statistics of RediSearch indexes.
+type Collector struct {
+	client        *grsearch.Client
+	options       Options
+	lock          sync.RWMutex
+	infos         map[string]*grsearch.Info
+	failures      float64
+	indexFailures map[string]float64
+	up            float64
+	metrics       []metric
+	upDesc        *prometheus.Desc
+	errDesc       *prometheus.Desc
+	indexErrDesc  *prometheus.Desc
+	dialects      *prometheus.Desc
+}
+
+// metric describes how one Info field is exported.
+type metric struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+	value     func(*grsearch.Info) float64
+}
+
+var _ prometheus.Collector = (*Collector)(nil)
+
+const megabyte = 1024 * 1024
+
+// NewCollector returns a collector for the client. Call [Collector.Refresh] or start
+// [Collector.Run] to read the statistics.
+func NewCollector(client *grsearch.Client, options *Options) *Collector {
+	c := &Collector{client: client, infos: map[string]*grsearch.Info{}, indexFailures: map[string]float64{}}
+	if options != nil {
+		c.options = *options
+	}
+	if c.options.Namespace == "" {
+		c.options.Namespace = DefaultNamespace
+	}
+	if c.options.Interval <= 0 {
+		c.options.Interval = DefaultInterval
+	}
+
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(c.options.Namespace, "", name), help,
+			append([]string{"index"}, labels...), c.options.ConstLabels)
+	}
+	gauge := func(name, help string, value func(*grsearch.Info) float64) metric {
+		return metric{desc: desc(name, help), valueType: prometheus.GaugeValue, value: value}
+	}
+	counter := func(name, help string, value func(*grsearch.Info) float64) metric {
+		return metric{desc: desc(name, help), valueType: prometheus.CounterValue, value: value}
+	}
+
+	c.metrics = []metric{
+		gauge("index_documents", "Number of documents in the index.",
+			func(i *grsearch.Info) float64 { return float64(i.NumDocs) }),
+		gauge("index_terms", "Number of distinct terms in the index.",
+			func(i *grsearch.Info) float64 { return float64(i.NumTerms) }),
+		gauge("index_records", "Number of records in the inverted index.",
+			func(i *grsearch.Info) float64 { return float64(i.NumRecords) }),
+		gauge("index_inverted_size_bytes", "Size of the inverted index.",
+			func(i *grsearch.Info) float64 { return i.InvertedSize * megabyte }),
+		gauge("index_vector_size_bytes", "Size of the vector indexes.",
+			func(i *grsearch.Info) float64 { return i.VectorIndexSize * megabyte }),
+		gauge("index_doc_table_size_bytes", "Size of the document table.",
+			func(i *grsearch.Info) float64 { return i.DocTableSize * megabyte }),
+		gauge("index_sortable_values_size_bytes", "Size of the sortable values.",
+			func(i *grsearch.Info) float64 { return i.SortableValuesSize * megabyte }),
+		gauge("index_key_table_size_bytes", "Size of the key table.",
+			func(i *grsearch.Info) float64 { return i.KeyTableSize * megabyte }),
+		gauge("index_indexing", "1 if the index is being built in the background.",
+			func(i *grsearch.Info) float64 { return i.Indexing }),
+		gauge("index_percent_indexed", "Fraction of the documents indexed (0 to 1).",
+			func(i *grsearch.Info) float64 { return i.PercentIndexed }),
+		counter("index_hash_indexing_failures_total", "Documents which could not be indexed.",
+			func(i *grsearch.Info) float64 { return float64(i.HashIndexingFailures) }),
+		counter("index_uses_total", "Number of times the index has been used.",
+			func(i *grsearch.Info) float64 { return float64(i.NumberOfUses) }),
+		counter("gc_bytes_collected_total", "Bytes collected by the garbage collector.",
+			func(i *grsearch.Info) float64 { return float64(i.GCStats.BytesCollected) }),
+		counter("gc_cycles_total", "Garbage collection cycles run.",
+			func(i *grsearch.Info) float64 { return float64(i.GCStats.TotalCycles) }),
+		counter("gc_run_seconds_total", "Time spent in garbage collection.",
+			func(i *grsearch.Info) float64 { return i.GCStats.TotalMsRun.Seconds() }),
+		gauge("gc_average_cycle_seconds", "Average garbage collection cycle time.",
+			func(i *grsearch.Info) float64 { return i.GCStats.AverageCycleTime.Seconds() }),
+		gauge("gc_last_run_seconds", "Duration of the last garbage collection cycle.",
+			func(i *grsearch.Info) float64 { return i.GCStats.LastRunTime.Seconds() }),
+		counter("gc_numeric_trees_missed_total", "Numeric trees missed by the garbage collector.",
+			func(i *grsearch.Info) float64 { return float64(i.GCStats.GCNumericTreesMissed) }),
+		counter("gc_blocks_denied_total", "Blocks denied by the garbage collector.",
+			func(i *grsearch.Info) float64 { return float64(i.GCStats.GCBlocksDenied) }),
+		gauge("cursors_global_idle", "Idle cursors across all indexes.",
+			func(i *grsearch.Info) float64 { return float64(i.CursorStats.GlobalIdle) }),
+		gauge("cursors_global_total", "Open cursors across all indexes.",
+			func(i *grsearch.Info) float64 { return float64(i.CursorStats.GlobalTotal) }),
+		gauge("cursors_index_capacity", "Maximum number of cursors for the index.",
+			func(i *grsearch.Info) float64 { return float64(i.CursorStats.IndexCapacity) }),
+		gauge("cursors_index_total", "Open cursors for the index.",
+			func(i *grsearch.Info) float64 { return float64(i.CursorStats.IndexTotal) }),
+	}
+
+	c.dialects = desc("dialect_uses_total", "Queries run against the index using each dialect.", "dialect")
+	c.upDesc = prometheus.NewDesc(prometheus.BuildFQName(c.options.Namespace, "", "up"),
+		"1 if the last refresh succeeded.", nil, c.options.ConstLabels)
+	c.errDesc = prometheus.NewDesc(prometheus.BuildFQName(c.options.Namespace, "", "refresh_failures_total"),
+		"Refreshes which failed.", nil, c.options.ConstLabels)
+	c.indexErrDesc = desc("index_refresh_failures_total", "Refreshes which could not read the index.")
+
+	return c
+}
+
+// Refresh reads the statistics for every index. An index which can't be read is skipped and
+// the others are still reported; the errors are returned together. If FT._LIST fails, or no
+// index can be read, the previous values are kept.
+func (c *Collector) Refresh(ctx context.Context) error {
+	indexes := c.options.Indexes
+	if len(indexes) == 0 {
+		var err error
+		if indexes, err = c.client.FTList(ctx).Result(); err != nil {
+			c.failed()
+			return err
+		}
+	}
+
+	infos := make(map[string]*grsearch.Info, len(indexes))
+	failed := []string{}
+	errs := []error{}
+	for _, index := range indexes {
+		info, err := c.client.FTInfo(ctx, index).Result()
+		if err != nil {
+			failed = append(failed, index)
+			errs = append(errs, fmt.Errorf("%s: %w", index, err))
+			continue
+		}
+		infos[index] = info
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, index := range failed {
+		c.indexFailures[index]++
+	}
+	if len(infos) == 0 && len(failed) > 0 {
+		c.failures++
+		c.up = 0
+	} else {
+		c.infos = infos
+		c.up = 1
+	}
+	return errors.Join(errs...)
+}
+
+// Run refreshes the statistics immediately and then at the configured interval until the
+// context is cancelled. Refresh errors are reported by the up and refresh failure metrics.
+func (c *Collector) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		_ = c.Refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Collector) failed() {
+	c.lock.Lock()
+	c.failures++
+	c.up = 0
+	c.lock.Unlock()
+}
+
+// Describe implements [prometheus.Collector].
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range c.metrics {
+		ch <- m.desc
+	}
+	ch <- c.dialects
+	ch <- c.upDesc
+	ch <- c.errDesc
+	ch <- c.indexErrDesc
+}
+
+// Collect implements [prometheus.Collector].
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	ch <- prometheus.MustNewConstMetric(c.upDesc, prometheus.GaugeValue, c.up)
+	ch <- prometheus.MustNewConstMetric(c.errDesc, prometheus.CounterValue, c.failures)
+	for index, failures := range c.indexFailures {
+		ch <- prometheus.MustNewConstMetric(c.indexErrDesc, prometheus.CounterValue, failures, index)
+	}
+
+	indexes := make([]string, 0, len(c.infos))
+	for index := range c.infos {
+		indexes = append(indexes, index)
+	}
+	sort.Strings(indexes)
+
+	for _, index := range indexes {
+		info := c.infos[index]
+		for _, m := range c.metrics {
+			ch <- prometheus.MustNewConstMetric(m.desc, m.valueType, m.value(info), index)
+		}
+		dialects := []int64{info.DialectStats.Dialect1, info.DialectStats.Dialect2, info.DialectStats.Dialect3, info.DialectStats.Dialect4}
+		for n, uses := range dialects {
+			ch <- prometheus.MustNewConstMetric(c.dialects, prometheus.CounterValue, float64(uses), index, strconv.Itoa(n+1))
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package promcollector_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goslogan/grsearch"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
+)
+
+var client *grsearch.Client
+var ctx = context.Background()
+
+func TestPromcollector(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Prometheus Collector Suite")
+}
+
+// The suite uses its own index and keys so that it can run alongside the grsearch suite.
+var _ = BeforeSuite(func() {
+	client = grsearch.NewClient(&redis.Options{})
+	Expect(client.Ping(ctx).Err()).NotTo(HaveOccurred())
+
+	client.FTDropIndex(ctx, "pcitems", true)
+	Expect(client.FTCreate(ctx, "pcitems", grsearch.NewIndexBuilder().
+		Prefix("pcitem:").
+		Schema(&grsearch.NumericAttribute{Name: "value", Sortable: true}).
+		Options()).Err()).NotTo(HaveOccurred())
+	for _, key := range []string{"pcitem:1", "pcitem:2", "pcitem:3"} {
+		Expect(client.HSet(ctx, key, "value", 1).Err()).NotTo(HaveOccurred())
+	}
+	time.Sleep(time.Second)
+})
+
+var _ = AfterSuite(func() {
+	client.FTDropIndex(ctx, "pcitems", true)
+	Expect(client.Close()).To(Succeed())
+})
@@ -0,0 +1,117 @@
+package promcollector_test
+
+import (
+	"github.com/goslogan/grsearch/promcollector"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var _ = Describe("Prometheus collector", Label("metrics", "ft.info"), func() {
+
+	// gathered returns the metrics for an index (or the unlabelled metrics if index is
+	// empty), keyed by metric name. Only dialect 2 is kept for the dialect metric.
+	gathered := func(registry *prometheus.Registry, index string) map[string]float64 {
+		families, err := registry.Gather()
+		Expect(err).NotTo(HaveOccurred())
+
+		values := map[string]float64{}
+		for _, family := range families {
+			for _, m := range family.Metric {
+				if !hasLabel(m, "index", index) || !(hasLabel(m, "dialect", "") || hasLabel(m, "dialect", "2")) {
+					continue
+				}
+				switch {
+				case m.Gauge != nil:
+					values[family.GetName()] = m.Gauge.GetValue()
+				case m.Counter != nil:
+					values[family.GetName()] = m.Counter.GetValue()
+				}
+			}
+		}
+		return values
+	}
+
+	// dialects returns the dialect labels reported for an index.
+	dialects := func(registry *prometheus.Registry, index string) []string {
+		families, err := registry.Gather()
+		Expect(err).NotTo(HaveOccurred())
+
+		labels := []string{}
+		for _, family := range families {
+			if family.GetName() != "redisearch_dialect_uses_total" {
+				continue
+			}
+			for _, m := range family.Metric {
+				for _, label := range m.Label {
+					if label.GetName() == "dialect" && hasLabel(m, "index", index) {
+						labels = append(labels, label.GetValue())
+					}
+				}
+			}
+		}
+		return labels
+	}
+
+	It("exports the statistics of the selected indexes", func() {
+		collector := promcollector.NewCollector(client, &promcollector.Options{Indexes: []string{"pcitems"}})
+		registry := prometheus.NewRegistry()
+		Expect(registry.Register(collector)).To(Succeed())
+
+		Expect(collector.Refresh(ctx)).To(Succeed())
+		info, err := client.FTInfo(ctx, "pcitems").Result()
+		Expect(err).NotTo(HaveOccurred())
+
+		values := gathered(registry, "pcitems")
+		Expect(values).To(HaveKeyWithValue("redisearch_up", 1.0))
+		Expect(values).To(HaveKeyWithValue("redisearch_index_documents", float64(info.NumDocs)))
+		Expect(values).To(HaveKeyWithValue("redisearch_index_records", float64(info.NumRecords)))
+		Expect(values).To(HaveKeyWithValue("redisearch_index_percent_indexed", 1.0))
+		Expect(values).To(HaveKey("redisearch_gc_cycles_total"))
+		Expect(values).To(HaveKey("redisearch_cursors_index_capacity"))
+		Expect(values).To(HaveKey("redisearch_dialect_uses_total"))
+		Expect(dialects(registry, "pcitems")).To(ConsistOf("1", "2", "3", "4"))
+	})
+
+	It("reports every index when none are given", func() {
+		collector := promcollector.NewCollector(client, &promcollector.Options{Namespace: "test"})
+		registry := prometheus.NewRegistry()
+		Expect(registry.Register(collector)).To(Succeed())
+		Expect(collector.Refresh(ctx)).To(Succeed())
+
+		Expect(gathered(registry, "pcitems")).To(HaveKey("test_index_documents"))
+	})
+
+	It("reports the other indexes when one can't be read", func() {
+		collector := promcollector.NewCollector(client, &promcollector.Options{Indexes: []string{"nosuchindex", "pcitems"}})
+		registry := prometheus.NewRegistry()
+		Expect(registry.Register(collector)).To(Succeed())
+
+		Expect(collector.Refresh(ctx)).To(MatchError(ContainSubstring("nosuchindex")))
+		Expect(gathered(registry, "")).To(HaveKeyWithValue("redisearch_up", 1.0))
+		Expect(gathered(registry, "nosuchindex")).To(Equal(map[string]float64{"redisearch_index_refresh_failures_total": 1.0}))
+		Expect(gathered(registry, "pcitems")).To(HaveKey("redisearch_index_documents"))
+	})
+
+	It("reports failed refreshes", func() {
+		collector := promcollector.NewCollector(client, &promcollector.Options{Indexes: []string{"nosuchindex"}})
+		registry := prometheus.NewRegistry()
+		Expect(registry.Register(collector)).To(Succeed())
+
+		Expect(collector.Refresh(ctx)).NotTo(Succeed())
+		values := gathered(registry, "")
+		Expect(values).To(HaveKeyWithValue("redisearch_up", 0.0))
+		Expect(values).To(HaveKeyWithValue("redisearch_refresh_failures_total", 1.0))
+	})
+})
+
+// hasLabel reports whether a metric has a label with the value, a missing label matching "".
+func hasLabel(m *dto.Metric, name, value string) bool {
+	for _, label := range m.Label {
+		if label.GetName() == name {
+			return label.GetValue() == value
+		}
+	}
+	return value == ""
+}
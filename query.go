@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"regexp"
+	"sort"
 	"time"
 
 	"github.com/goslogan/grsearch/internal"
@@ -37,6 +39,7 @@ type QueryOptions struct {
 	GeoFilters   []GeoFilter
 	Params       map[string]interface{}
 	json         bool
+	rewritten    bool // set on an iterator's own copy if the search it pages through was rewritten
 }
 
 const (
@@ -130,12 +133,7 @@ func (q *QueryOptions) serialize() []interface{} {
 		args = append(args, q.Limit.serialize()...)
 	}
 
-	if len(q.Params) != 0 {
-		args = append(args, "params", len(q.Params))
-		for n, v := range q.Params {
-			args = append(args, n, v)
-		}
-	}
+	args = append(args, serializeParams(q.Params)...)
 
 	if q.Dialect != defaultDialect {
 		args = append(args, "DIALECT", q.Dialect)
@@ -144,6 +142,40 @@ func (q *QueryOptions) serialize() []interface{} {
 	return args
 }
 
+// serializeParams serializes query parameters in name order. The count is of names and values.
+// Slices of float32 and float64 are converted to the binary form of FLOAT32 and FLOAT64 vectors,
+// and times to unix timestamps in seconds.
+func serializeParams(params map[string]interface{}) []interface{} {
+	if len(params) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(params))
+	for n := range params {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	args := []interface{}{"params", 2 * len(params)}
+	for _, n := range names {
+		args = append(args, n, paramValue(params[n]))
+	}
+	return args
+}
+
+// paramValue converts a parameter to the form the server expects.
+func paramValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []float32:
+		return vectorValue(&VectorAttribute{Type: "FLOAT32"}, v)
+	case []float64:
+		return vectorValue(&VectorAttribute{Type: "FLOAT64"}, v)
+	case time.Time:
+		return v.Unix()
+	}
+	return value
+}
+
 func (q *QueryOptions) serializeReturn() []interface{} {
 	if len(q.Return) > 0 {
 		fields := []interface{}{}
@@ -169,6 +201,10 @@ func (q *QueryOptions) resultSize() int {
 		count += 1
 	}
 
+	if q.WithSortKeys { // one more if returning sort keys
+		count += 1
+	}
+
 	if q.NoContent { // one less if not content
 		count -= 1
 	}
@@ -228,6 +264,14 @@ func FilterValue(val float64, exclusive bool) interface{} {
 	}
 }
 
+var escapeChars = regexp.MustCompile(`([,.<>{}\[\]"':;!@#$%^&*()\-+=~|/\\ ])`)
+
+// Escape prefixes punctuation and spaces in a value with a backslash so that it is
+// treated as a single token when it is used in a query on a TEXT or TAG attribute.
+func Escape(value string) string {
+	return escapeChars.ReplaceAllString(value, `\$1`)
+}
+
 // serialize converts a filter list to an array of interface{} objects for execution
 func (q *QueryFilter) serialize() []interface{} {
 	return []interface{}{"FILTER", q.Attribute, q.Min, q.Max}
@@ -337,6 +381,7 @@ type SearchResult struct {
 	Key         string
 	Score       float64
 	Explanation interface{}
+	SortKey     string // raw sort key (# prefix for numbers, $ for strings) if WithSortKeys is set
 	Values      map[string]string
 }
 
@@ -2,6 +2,7 @@ package grsearch_test
 
 import (
 	"math"
+	"time"
 
 	grsearch "github.com/goslogan/grsearch"
 	. "github.com/onsi/ginkgo/v2"
@@ -64,6 +65,34 @@ var _ = Describe("Query basics", Label("hash", "query", "ft.search"), func() {
 
 var _ = Describe("Query options", Label("hash", "query", "ft.search"), func() {
 
+	It("sends parameters in name order with their count", func() {
+		when := time.Unix(1700000000, 0)
+		cmd := offline.FTSearchHash(ctx, "hcustomers", "@balance:[$min $max]", grsearch.NewQueryBuilder().
+			Param("min", 0).
+			Param("max", 1000).
+			Param("since", when).
+			Param("vec", []float32{1, 2}).
+			Options())
+		Expect(cmd.Err()).To(MatchError(errOffline))
+		Expect(cmd.Args()[3:]).To(Equal([]interface{}{
+			"params", 8,
+			"max", 1000,
+			"min", 0,
+			"since", int64(1700000000),
+			"vec", []byte{0, 0, 0x80, 0x3f, 0, 0, 0, 0x40},
+		}))
+	})
+
+	It("can search with more than one parameter", func() {
+		cmd := client.FTSearchHash(ctx, "hcustomers", "@balance:[$min $max]", grsearch.NewQueryBuilder().
+			Param("min", 0).
+			Param("max", 1000).
+			Dialect(2).
+			Options())
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		Expect(cmd.TotalResults()).To(Equal(client.FTSearchHash(ctx, "hcustomers", "@balance:[0 1000]", nil).TotalResults()))
+	})
+
 	It("will return empty results - NOCONTENT", func() {
 		opts := grsearch.NewQueryOptions()
 		opts.NoContent = true
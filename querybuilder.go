@@ -130,6 +130,12 @@ func (q *QueryBuilder) WithPayloads() *QueryBuilder {
 	return q
 }
 
+// WithSortKeys sets the WITHSORTKEYS option for searches
+func (q *QueryBuilder) WithSortKeys() *QueryBuilder {
+	q.opts.WithSortKeys = true
+	return q
+}
+
 // Verbatim disables stemming.
 func (q *QueryBuilder) Verbatim() *QueryBuilder {
 	q.opts.Verbatim = true
@@ -0,0 +1,407 @@
+// Package querylang parses RediSearch queries into an abstract syntax tree which can be
+// inspected, transformed and rendered back into a query string with String.
+//
+// Rendering adds parentheses wherever the grouping of the tree would otherwise depend on the
+// dialect, so a rendered query means the same thing whichever dialect it is run with. Terms,
+// tags and other values are kept exactly as written, escapes included.
+package querylang
+
+import (
+	"strings"
+)
+
+// Node is a node in a query tree.
+type Node interface {
+	String() string
+	node()
+}
+
+// Intersection matches documents matching all of its children (terms separated by spaces).
+type Intersection struct {
+	Children []Node
+}
+
+// Union matches documents matching any of its children (terms separated by |).
+type Union struct {
+	Children []Node
+}
+
+// Not excludes documents matching its child (-term).
+type Not struct {
+	Child Node
+}
+
+// Optional ranks documents matching its child higher without requiring a match (~term).
+type Optional struct {
+	Child Node
+}
+
+// Group is a parenthesised expression.
+type Group struct {
+	Child Node
+}
+
+// Field restricts its child to one or more attributes (@title|body:child).
+type Field struct {
+	Names []string
+	Child Node
+}
+
+// TermKind distinguishes the forms a term can take.
+type TermKind int
+
+const (
+	TermExact    TermKind = iota // hello
+	TermPrefix                   // hel*
+	TermSuffix                   // *llo
+	TermInfix                    // *ell*
+	TermFuzzy                    // %hallo%, with Distance set to the number of %s
+	TermWildcard                 // w'h?l*o'
+)
+
+// Term is a single word, possibly with prefix, suffix, infix, fuzzy or wildcard matching.
+type Term struct {
+	Value    string
+	Kind     TermKind
+	Distance int // Levenshtein distance for fuzzy terms
+}
+
+// Phrase is a quoted exact phrase. Value is the text between the quotes.
+type Phrase struct {
+	Value string
+}
+
+// Param is a reference to a query parameter ($name).
+type Param struct {
+	Name string
+}
+
+// Wildcard matches every document (*).
+type Wildcard struct{}
+
+// TagList matches any of a set of tags ({a | b}). Values are as written, so may be
+// escaped, quoted, prefixes or parameters.
+type TagList struct {
+	Values []string
+}
+
+// Bound is one end of a numeric range. Value may be a number, -inf, +inf or a parameter.
+type Bound struct {
+	Value     string
+	Exclusive bool
+}
+
+func (b Bound) String() string {
+	if b.Exclusive {
+		return "(" + b.Value
+	}
+	return b.Value
+}
+
+// NumericRange matches numeric attributes between two bounds ([min max]).
+type NumericRange struct {
+	Min, Max Bound
+}
+
+// GeoRadius matches geo attributes within a radius of a point ([lon lat radius unit]).
+type GeoRadius struct {
+	Lon, Lat, Radius, Unit string
+}
+
+// GeoShape matches geometry attributes with a spatial relation to a shape, usually given
+// as a parameter ([WITHIN $shape]).
+type GeoShape struct {
+	Operator string
+	Shape    string
+}
+
+// VectorRange matches vectors within a radius of a vector ([VECTOR_RANGE radius $vector]).
+type VectorRange struct {
+	Radius string
+	Vector string
+}
+
+// Attribute is a query attribute such as $weight or $slop.
+type Attribute struct {
+	Name  string // Without the leading $
+	Value string
+}
+
+// Attributes applies query attributes to its child (child=>{$weight: 2}).
+type Attributes struct {
+	Child      Node
+	Attributes []Attribute
+}
+
+// KNN is a vector similarity query (filter=>[KNN k @field $vector]). Options are the
+// tokens after the vector, such as EF_RUNTIME 10 or AS distance.
+type KNN struct {
+	Filter     Node
+	K          string
+	Field      string
+	Vector     string
+	Options    []string
+	Attributes []Attribute
+}
+
+func (*Intersection) node() {}
+func (*Union) node()        {}
+func (*Not) node()          {}
+func (*Optional) node()     {}
+func (*Group) node()        {}
+func (*Field) node()        {}
+func (*Term) node()         {}
+func (*Phrase) node()       {}
+func (*Param) node()        {}
+func (*Wildcard) node()     {}
+func (*TagList) node()      {}
+func (*NumericRange) node() {}
+func (*GeoRadius) node()    {}
+func (*GeoShape) node()     {}
+func (*VectorRange) node()  {}
+func (*Attributes) node()   {}
+func (*KNN) node()          {}
+
+/* ---- RENDERING */
+
+// grouped renders a node, adding parentheses if it is an intersection or union.
+func grouped(n Node) string {
+	switch n.(type) {
+	case *Intersection, *Union:
+		return "(" + n.String() + ")"
+	}
+	return n.String()
+}
+
+func (n *Intersection) String() string {
+	return renderList(n.Children, " ", func(child Node) bool {
+		_, ok := child.(*Union)
+		return ok
+	})
+}
+
+func (n *Union) String() string {
+	return renderList(n.Children, " | ", func(child Node) bool {
+		_, ok := child.(*Intersection)
+		return ok
+	})
+}
+
+// renderList joins the children of an intersection or union. Children are bracketed if
+// needsGroup says so, or if they end with an attribute modifier and aren't last, as in
+// dialect 1 the modifier would apply to the children after them as well.
+func renderList(children []Node, sep string, needsGroup func(Node) bool) string {
+	parts := make([]string, len(children))
+	for i, child := range children {
+		if needsGroup(child) || i < len(children)-1 && endsWithField(child) {
+			parts[i] = "(" + child.String() + ")"
+		} else {
+			parts[i] = child.String()
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+// endsWithField reports whether a node is rendered ending with an attribute modifier which,
+// in dialect 1, would take in what follows it. Tag lists and ranges end the modifier.
+func endsWithField(n Node) bool {
+	switch v := n.(type) {
+	case *Field:
+		switch v.Child.(type) {
+		case *TagList, *NumericRange, *GeoRadius, *GeoShape, *VectorRange:
+			return false
+		}
+		return true
+	case *Not:
+		return endsWithField(v.Child)
+	case *Optional:
+		return endsWithField(v.Child)
+	case *Attributes:
+		return endsWithField(v.Child)
+	}
+	return false
+}
+
+func (n *Not) String() string {
+	return "-" + grouped(n.Child)
+}
+
+func (n *Optional) String() string {
+	return "~" + grouped(n.Child)
+}
+
+func (n *Group) String() string {
+	return "(" + n.Child.String() + ")"
+}
+
+func (n *Field) String() string {
+	return "@" + strings.Join(n.Names, "|") + ":" + grouped(n.Child)
+}
+
+func (n *Term) String() string {
+	switch n.Kind {
+	case TermPrefix:
+		return n.Value + "*"
+	case TermSuffix:
+		return "*" + n.Value
+	case TermInfix:
+		return "*" + n.Value + "*"
+	case TermFuzzy:
+		fuzz := strings.Repeat("%", n.Distance)
+		return fuzz + n.Value + fuzz
+	case TermWildcard:
+		return "w'" + n.Value + "'"
+	}
+	return n.Value
+}
+
+func (n *Phrase) String() string {
+	return `"` + n.Value + `"`
+}
+
+func (n *Param) String() string {
+	return "$" + n.Name
+}
+
+func (n *Wildcard) String() string {
+	return "*"
+}
+
+func (n *TagList) String() string {
+	return "{" + strings.Join(n.Values, " | ") + "}"
+}
+
+func (n *NumericRange) String() string {
+	return "[" + n.Min.String() + " " + n.Max.String() + "]"
+}
+
+func (n *GeoRadius) String() string {
+	return "[" + n.Lon + " " + n.Lat + " " + n.Radius + " " + n.Unit + "]"
+}
+
+func (n *GeoShape) String() string {
+	return "[" + n.Operator + " " + n.Shape + "]"
+}
+
+func (n *VectorRange) String() string {
+	return "[VECTOR_RANGE " + n.Radius + " " + n.Vector + "]"
+}
+
+func renderAttributes(attributes []Attribute) string {
+	parts := make([]string, len(attributes))
+	for i, a := range attributes {
+		parts[i] = "$" + a.Name + ": " + a.Value
+	}
+	return "=>{" + strings.Join(parts, "; ") + "}"
+}
+
+func (n *Attributes) String() string {
+	return grouped(n.Child) + renderAttributes(n.Attributes)
+}
+
+func (n *KNN) String() string {
+	var filter string
+	switch n.Filter.(type) {
+	case *Wildcard, *Group:
+		filter = n.Filter.String()
+	default:
+		filter = "(" + n.Filter.String() + ")"
+	}
+
+	clause := []string{"KNN", n.K, "@" + n.Field, n.Vector}
+	clause = append(clause, n.Options...)
+	rendered := filter + "=>[" + strings.Join(clause, " ") + "]"
+	if len(n.Attributes) != 0 {
+		rendered += renderAttributes(n.Attributes)
+	}
+	return rendered
+}
+
+/* ---- TRAVERSAL */
+
+// Children returns the direct children of a node.
+func Children(n Node) []Node {
+	switch v := n.(type) {
+	case *Intersection:
+		return v.Children
+	case *Union:
+		return v.Children
+	case *Not:
+		return []Node{v.Child}
+	case *Optional:
+		return []Node{v.Child}
+	case *Group:
+		return []Node{v.Child}
+	case *Field:
+		return []Node{v.Child}
+	case *Attributes:
+		return []Node{v.Child}
+	case *KNN:
+		return []Node{v.Filter}
+	}
+	return nil
+}
+
+// Walk calls fn for each node in the tree in depth-first order. The children of a node
+// are skipped if fn returns false.
+func Walk(n Node, fn func(Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, child := range Children(n) {
+		Walk(child, fn)
+	}
+}
+
+// Transform rebuilds a tree bottom up, replacing each node with the result of fn. Nodes
+// are modified in place where their children change.
+func Transform(n Node, fn func(Node) Node) Node {
+	switch v := n.(type) {
+	case *Intersection:
+		for i, child := range v.Children {
+			v.Children[i] = Transform(child, fn)
+		}
+	case *Union:
+		for i, child := range v.Children {
+			v.Children[i] = Transform(child, fn)
+		}
+	case *Not:
+		v.Child = Transform(v.Child, fn)
+	case *Optional:
+		v.Child = Transform(v.Child, fn)
+	case *Group:
+		v.Child = Transform(v.Child, fn)
+	case *Field:
+		v.Child = Transform(v.Child, fn)
+	case *Attributes:
+		v.Child = Transform(v.Child, fn)
+	case *KNN:
+		v.Filter = Transform(v.Filter, fn)
+	}
+	return fn(n)
+}
+
+// Fields returns the names of the attributes referenced in a query, in the order they
+// first appear. Vector attributes used in KNN clauses are included.
+func Fields(n Node) []string {
+	seen := map[string]bool{}
+	fields := []string{}
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			fields = append(fields, name)
+		}
+	}
+	Walk(n, func(n Node) bool {
+		switch v := n.(type) {
+		case *Field:
+			for _, name := range v.Names {
+				add(name)
+			}
+		case *KNN:
+			add(v.Field)
+		}
+		return true
+	})
+	return fields
+}
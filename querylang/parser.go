@@ -0,0 +1,617 @@
+package querylang
+
+// The parser works directly on the runes of the query. Dialects 2 to 4 share a grammar; dialect 1
+// differs in two ways: | binds more tightly than the implicit AND between terms, and an attribute
+// modifier (@title:) applies to everything after it up to the end of the enclosing group
+// rather than to a single term. Punctuation which has no meaning in a query is skipped, as
+// the server does.
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// SyntaxError reports a query which can't be parsed.
+type SyntaxError struct {
+	Offset  int // Offset in runes from the start of the query
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("querylang: %s at offset %d", e.Message, e.Offset)
+}
+
+// Parse parses a query written for the given dialect (1 to 4). Zero is treated as dialect 2,
+// the default used by grsearch.
+func Parse(query string, dialect uint8) (Node, error) {
+	if dialect == 0 {
+		dialect = 2
+	}
+	if dialect > 4 {
+		return nil, fmt.Errorf("querylang: unsupported dialect %d", dialect)
+	}
+
+	p := &parser{runes: []rune(query), dialect: dialect}
+	p.skipSpace()
+	if p.eof() {
+		return nil, p.errorf("empty query")
+	}
+
+	n, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peekString("=>") {
+		pos := p.pos
+		p.pos += 2
+		p.skipSpace()
+		if p.peek() != '[' {
+			p.pos = pos
+			return nil, p.errorf("unexpected =>")
+		}
+		if n, err = p.parseKNN(n); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+	}
+
+	if !p.eof() {
+		return nil, p.errorf("unexpected %q", p.peek())
+	}
+	return n, nil
+}
+
+// MustParse is like Parse but panics if the query can't be parsed.
+func MustParse(query string, dialect uint8) Node {
+	n, err := Parse(query, dialect)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+type parser struct {
+	runes   []rune
+	pos     int
+	dialect uint8
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Offset: p.pos, Message: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.runes)
+}
+
+func (p *parser) peek() rune {
+	if p.eof() {
+		return 0
+	}
+	return p.runes[p.pos]
+}
+
+func (p *parser) peekAt(offset int) rune {
+	if p.pos+offset >= len(p.runes) {
+		return 0
+	}
+	return p.runes[p.pos+offset]
+}
+
+func (p *parser) peekString(s string) bool {
+	for i, r := range []rune(s) {
+		if p.peekAt(i) != r {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *parser) skipSpace() {
+	for !p.eof() && unicode.IsSpace(p.peek()) {
+		p.pos++
+	}
+}
+
+func (p *parser) expect(r rune) error {
+	p.skipSpace()
+	if p.peek() != r {
+		if p.eof() {
+			return p.errorf("expected %q at end of query", r)
+		}
+		return p.errorf("expected %q, found %q", r, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+// atEnd reports whether the current expression has ended.
+func (p *parser) atEnd() bool {
+	p.skipSpace()
+	return p.eof() || p.peek() == ')' || p.peekString("=>")
+}
+
+// isTermRune reports whether r can appear in a term without being escaped.
+func isTermRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || r > unicode.MaxASCII && !unicode.IsSpace(r) && !unicode.IsPunct(r)
+}
+
+// isIgnored reports whether r is punctuation with no meaning in a query.
+func isIgnored(r rune) bool {
+	return !isTermRune(r) && !unicode.IsSpace(r) && !strings.ContainsRune(`()|-~@"$*%{}[]\`, r)
+}
+
+func (p *parser) parseExpr() (Node, error) {
+	if p.dialect == 1 {
+		return p.parseSequence(p.parseUnion)
+	}
+	return p.parseUnionOf(func() (Node, error) { return p.parseSequence(p.parseUnary) })
+}
+
+// parseUnion parses unary expressions separated by | (used for dialect 1).
+func (p *parser) parseUnion() (Node, error) {
+	return p.parseUnionOf(p.parseUnary)
+}
+
+// parseUnionOf parses operands separated by |, returning a single operand unchanged.
+func (p *parser) parseUnionOf(operand func() (Node, error)) (Node, error) {
+	first, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	children := []Node{first}
+	for {
+		p.skipSpace()
+		if p.peek() != '|' {
+			break
+		}
+		p.pos++
+		next, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, next)
+	}
+	if len(children) == 1 {
+		return first, nil
+	}
+	return &Union{Children: children}, nil
+}
+
+// parseSequence parses operands separated by spaces, returning a single operand unchanged.
+func (p *parser) parseSequence(operand func() (Node, error)) (Node, error) {
+	children := []Node{}
+	for {
+		p.skipIgnored()
+		if p.atEnd() || p.peek() == '|' {
+			break
+		}
+		next, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, next)
+	}
+	switch len(children) {
+	case 0:
+		if p.eof() {
+			return nil, p.errorf("unexpected end of query")
+		}
+		return nil, p.errorf("unexpected %q", p.peek())
+	case 1:
+		return children[0], nil
+	}
+	return &Intersection{Children: children}, nil
+}
+
+func (p *parser) skipIgnored() {
+	for {
+		p.skipSpace()
+		if p.eof() || !isIgnored(p.peek()) || p.peekString("=>") {
+			return
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	p.skipIgnored()
+	switch p.peek() {
+	case '-':
+		p.pos++
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Child: child}, nil
+	case '~':
+		p.pos++
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Optional{Child: child}, nil
+	}
+	return p.parsePostfix()
+}
+
+// parsePostfix parses an atom followed by any number of attribute lists.
+func (p *parser) parsePostfix() (Node, error) {
+	n, err := p.parseAtom()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		save := p.pos
+		p.skipSpace()
+		if !p.peekString("=>") {
+			p.pos = save
+			return n, nil
+		}
+		p.pos += 2
+		p.skipSpace()
+		if p.peek() != '{' {
+			// a KNN clause, which applies to the whole query
+			p.pos = save
+			return n, nil
+		}
+		attributes, err := p.parseAttributes()
+		if err != nil {
+			return nil, err
+		}
+		n = &Attributes{Child: n, Attributes: attributes}
+	}
+}
+
+func (p *parser) parseAtom() (Node, error) {
+	p.skipSpace()
+	switch r := p.peek(); {
+	case r == '(':
+		p.pos++
+		p.skipSpace()
+		child, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(')'); err != nil {
+			return nil, err
+		}
+		return &Group{Child: child}, nil
+	case r == '@':
+		return p.parseField()
+	case r == '"':
+		value, err := p.scanQuoted()
+		if err != nil {
+			return nil, err
+		}
+		return &Phrase{Value: value}, nil
+	case r == '$':
+		p.pos++
+		name := p.scanWord()
+		if name == "" {
+			return nil, p.errorf("expected a parameter name")
+		}
+		return &Param{Name: name}, nil
+	case r == '*' && p.wildcardAt(1):
+		p.pos++
+		return &Wildcard{}, nil
+	case r == '%':
+		return p.parseFuzzy()
+	case r == 'w' && p.peekAt(1) == '\'':
+		p.pos += 2
+		start := p.pos
+		for !p.eof() && p.peek() != '\'' {
+			if p.peek() == '\\' {
+				p.pos++
+			}
+			p.pos++
+		}
+		if p.eof() {
+			return nil, p.errorf("unterminated wildcard pattern")
+		}
+		value := string(p.runes[start:p.pos])
+		p.pos++
+		return &Term{Value: value, Kind: TermWildcard}, nil
+	case r == '*' || r == '\\' || isTermRune(r):
+		return p.parseTerm()
+	case r == 0:
+		return nil, p.errorf("unexpected end of query")
+	}
+	return nil, p.errorf("unexpected %q", p.peek())
+}
+
+// wildcardAt reports whether the * before offset stands alone.
+func (p *parser) wildcardAt(offset int) bool {
+	r := p.peekAt(offset)
+	return r == 0 || unicode.IsSpace(r) || r == ')' || r == '|' || r == '='
+}
+
+func (p *parser) parseTerm() (Node, error) {
+	leading := false
+	if p.peek() == '*' {
+		leading = true
+		p.pos++
+	}
+	value := p.scanWord()
+	if value == "" {
+		return nil, p.errorf("expected a term")
+	}
+	trailing := false
+	if p.peek() == '*' {
+		trailing = true
+		p.pos++
+	}
+
+	term := &Term{Value: value}
+	switch {
+	case leading && trailing:
+		term.Kind = TermInfix
+	case leading:
+		term.Kind = TermSuffix
+	case trailing:
+		term.Kind = TermPrefix
+	}
+	return term, nil
+}
+
+func (p *parser) parseFuzzy() (Node, error) {
+	distance := 0
+	for p.peek() == '%' {
+		distance++
+		p.pos++
+	}
+	if distance > 3 {
+		return nil, p.errorf("fuzzy matching is limited to a distance of 3")
+	}
+	value := p.scanWord()
+	if value == "" {
+		return nil, p.errorf("expected a term")
+	}
+	for i := 0; i < distance; i++ {
+		if p.peek() != '%' {
+			return nil, p.errorf("unbalanced %% in fuzzy term")
+		}
+		p.pos++
+	}
+	return &Term{Value: value, Kind: TermFuzzy, Distance: distance}, nil
+}
+
+// scanWord reads a term, keeping escapes. Numbers may contain a decimal point and exponent.
+func (p *parser) scanWord() string {
+	start := p.pos
+	numeric := true
+	for !p.eof() {
+		r := p.peek()
+		switch {
+		case r == '\\' && p.pos+1 < len(p.runes):
+			p.pos += 2
+			numeric = false
+			continue
+		case isTermRune(r):
+			if !unicode.IsDigit(r) && !(numeric && (r == 'e' || r == 'E') && p.pos > start) {
+				numeric = false
+			}
+		case numeric && p.pos > start && unicode.IsDigit(p.peekAt(1)) && (r == '.' || r == '-' && unicode.ToLower(p.runes[p.pos-1]) == 'e'):
+		default:
+			return string(p.runes[start:p.pos])
+		}
+		p.pos++
+	}
+	return string(p.runes[start:p.pos])
+}
+
+// scanQuoted reads a quoted string, returning the text between the quotes.
+func (p *parser) scanQuoted() (string, error) {
+	p.pos++
+	start := p.pos
+	for !p.eof() && p.peek() != '"' {
+		if p.peek() == '\\' {
+			p.pos++
+		}
+		p.pos++
+	}
+	if p.eof() {
+		return "", p.errorf("unterminated quoted string")
+	}
+	value := string(p.runes[start:p.pos])
+	p.pos++
+	return value, nil
+}
+
+func (p *parser) parseField() (Node, error) {
+	p.pos++
+	names := []string{}
+	for {
+		name := p.scanWord()
+		if name == "" {
+			return nil, p.errorf("expected an attribute name")
+		}
+		names = append(names, name)
+		if p.peek() != '|' {
+			break
+		}
+		p.pos++
+	}
+	if err := p.expect(':'); err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+
+	var child Node
+	var err error
+	switch p.peek() {
+	case '{':
+		child, err = p.parseTags()
+	case '[':
+		child, err = p.parseRange()
+	default:
+		if p.dialect == 1 {
+			child, err = p.parseExpr()
+		} else {
+			child, err = p.parseUnary()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Field{Names: names, Child: child}, nil
+}
+
+// scanDelimited returns the text up to an unescaped closing delimiter, skipping quoted
+// strings, and moves past the delimiter.
+func (p *parser) scanDelimited(close rune) (string, error) {
+	p.pos++
+	start := p.pos
+	for !p.eof() && p.peek() != close {
+		switch p.peek() {
+		case '\\':
+			p.pos++
+		case '"':
+			if _, err := p.scanQuoted(); err != nil {
+				return "", err
+			}
+			continue
+		}
+		p.pos++
+	}
+	if p.eof() {
+		return "", p.errorf("expected %q at end of query", close)
+	}
+	content := string(p.runes[start:p.pos])
+	p.pos++
+	return content, nil
+}
+
+// splitUnescaped splits s at each unescaped, unquoted separator.
+func splitUnescaped(s string, sep rune) []string {
+	parts := []string{}
+	runes := []rune(s)
+	start := 0
+	quoted := false
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				parts = append(parts, string(runes[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, string(runes[start:]))
+}
+
+func (p *parser) parseTags() (Node, error) {
+	content, err := p.scanDelimited('}')
+	if err != nil {
+		return nil, err
+	}
+	tags := &TagList{}
+	for _, tag := range splitUnescaped(content, '|') {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags.Values = append(tags.Values, tag)
+		}
+	}
+	if len(tags.Values) == 0 {
+		return nil, p.errorf("empty tag list")
+	}
+	return tags, nil
+}
+
+func (p *parser) parseRange() (Node, error) {
+	start := p.pos
+	content, err := p.scanDelimited(']')
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(content)
+	if len(fields) == 0 {
+		p.pos = start
+		return nil, p.errorf("empty range")
+	}
+
+	switch keyword := strings.ToUpper(fields[0]); {
+	case keyword == "VECTOR_RANGE" && len(fields) == 3:
+		return &VectorRange{Radius: fields[1], Vector: fields[2]}, nil
+	case (keyword == "WITHIN" || keyword == "CONTAINS" || keyword == "INTERSECTS" || keyword == "DISJOINT") && len(fields) == 2:
+		return &GeoShape{Operator: keyword, Shape: fields[1]}, nil
+	case len(fields) == 4:
+		return &GeoRadius{Lon: fields[0], Lat: fields[1], Radius: fields[2], Unit: fields[3]}, nil
+	case len(fields) == 2:
+		return &NumericRange{Min: parseBound(fields[0]), Max: parseBound(fields[1])}, nil
+	}
+	p.pos = start
+	return nil, p.errorf("invalid range [%s]", content)
+}
+
+func parseBound(s string) Bound {
+	if strings.HasPrefix(s, "(") {
+		return Bound{Value: s[1:], Exclusive: true}
+	}
+	return Bound{Value: s}
+}
+
+// parseAttributes parses {$name: value; ...}.
+func (p *parser) parseAttributes() ([]Attribute, error) {
+	start := p.pos
+	content, err := p.scanDelimited('}')
+	if err != nil {
+		return nil, err
+	}
+	attributes := []Attribute{}
+	for _, part := range splitUnescaped(content, ';') {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, ":")
+		name = strings.TrimSpace(name)
+		if !ok || !strings.HasPrefix(name, "$") {
+			p.pos = start
+			return nil, p.errorf("invalid query attribute %q", part)
+		}
+		attributes = append(attributes, Attribute{Name: name[1:], Value: strings.TrimSpace(value)})
+	}
+	return attributes, nil
+}
+
+// parseKNN parses [KNN k @field $vector options...] and any attributes after it.
+func (p *parser) parseKNN(filter Node) (Node, error) {
+	start := p.pos
+	content, err := p.scanDelimited(']')
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(content)
+	if len(fields) < 4 || !strings.EqualFold(fields[0], "KNN") || !strings.HasPrefix(fields[2], "@") {
+		p.pos = start
+		return nil, p.errorf("invalid vector query [%s]", content)
+	}
+	knn := &KNN{
+		Filter:  filter,
+		K:       fields[1],
+		Field:   fields[2][1:],
+		Vector:  fields[3],
+		Options: fields[4:],
+	}
+
+	save := p.pos
+	p.skipSpace()
+	if p.peekString("=>") {
+		p.pos += 2
+		p.skipSpace()
+		if p.peek() != '{' {
+			return nil, p.errorf("expected query attributes after vector query")
+		}
+		if knn.Attributes, err = p.parseAttributes(); err != nil {
+			return nil, err
+		}
+	} else {
+		p.pos = save
+	}
+	return knn, nil
+}
@@ -0,0 +1,70 @@
+package grsearch_test
+
+import (
+	"errors"
+
+	"github.com/goslogan/grsearch/querylang"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Query parser", Label("querylang"), func() {
+
+	DescribeTable("renders parsed queries",
+		func(query string, dialect int, expected string) {
+			n, err := querylang.Parse(query, uint8(dialect))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n.String()).To(Equal(expected))
+
+			again, err := querylang.Parse(expected, 2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(again.String()).To(Equal(expected))
+		},
+		Entry("terms", "hello world", 2, "hello world"),
+		Entry("union in dialect 2", "hello world | foo", 2, "(hello world) | foo"),
+		Entry("union in dialect 1", "hello world | foo", 1, "hello (world | foo)"),
+		Entry("modifier in dialect 2", "@title:hello world", 2, "(@title:hello) world"),
+		Entry("modifier in dialect 1", "@title:hello world", 1, "@title:(hello world)"),
+		Entry("tags and negation", `@title|body:(hello world) -@id:{a\-b | c*} ~opt`, 2, `(@title|body:(hello world)) -@id:{a\-b | c*} ~opt`),
+		Entry("ranges", "@price:[(10 +inf] @loc:[-122.4 37.7 5 km] @shape:[WITHIN $poly]", 2, "@price:[(10 +inf] @loc:[-122.4 37.7 5 km] @shape:[WITHIN $poly]"),
+		Entry("term forms", `"exact phrase" hel* *llo *ell* %hallo% %%%x%%% w'h?l*o'`, 2, `"exact phrase" hel* *llo *ell* %hallo% %%%x%%% w'h?l*o'`),
+		Entry("attributes", "(foo bar)=>{$weight: 2.0; $slop: 1}", 3, "(foo bar)=>{$weight: 2.0; $slop: 1}"),
+		Entry("vector range", "@v:[VECTOR_RANGE 0.2 $b]=>{$yield_distance_as: d}", 4, "@v:[VECTOR_RANGE 0.2 $b]=>{$yield_distance_as: d}"),
+		Entry("knn", "*=>[KNN 10 @vec $blob AS dist]", 2, "*=>[KNN 10 @vec $blob AS dist]"),
+		Entry("hybrid knn", "@tag:{x}=>[KNN $k @vec $blob]=>{$yield_distance_as: dist}", 2, "(@tag:{x})=>[KNN $k @vec $blob]=>{$yield_distance_as: dist}"),
+		Entry("punctuation", "3.14 foo.bar, baz", 2, "3.14 foo bar baz"),
+	)
+
+	It("builds a tree which can be inspected and transformed", func() {
+		n := querylang.MustParse("@owner:{lara\\.croft} (%helo% | world)=>[KNN 5 @vec $v]", 2)
+		Expect(querylang.Fields(n)).To(Equal([]string{"vec", "owner"}))
+
+		knn, ok := n.(*querylang.KNN)
+		Expect(ok).To(BeTrue())
+		Expect(knn.K).To(Equal("5"))
+		Expect(knn.Filter).To(BeAssignableToTypeOf(&querylang.Intersection{}))
+
+		n = querylang.Transform(n, func(n querylang.Node) querylang.Node {
+			if t, ok := n.(*querylang.Term); ok && t.Kind == querylang.TermFuzzy {
+				return &querylang.Term{Value: t.Value, Kind: querylang.TermPrefix}
+			}
+			return n
+		})
+		Expect(n.String()).To(Equal("(@owner:{lara\\.croft} (helo* | world))=>[KNN 5 @vec $v]"))
+	})
+
+	DescribeTable("reports syntax errors",
+		func(query string, offset int) {
+			_, err := querylang.Parse(query, 2)
+			var syntax *querylang.SyntaxError
+			Expect(errors.As(err, &syntax)).To(BeTrue())
+			Expect(syntax.Offset).To(Equal(offset))
+		},
+		Entry("empty", "", 0),
+		Entry("unclosed group", "(a", 2),
+		Entry("unclosed tags", "@f:{", 4),
+		Entry("trailing union", "a |", 3),
+		Entry("missing attribute", "@:x", 1),
+		Entry("nested knn", "(a=>[KNN 1 @v $b])", 2),
+	)
+})
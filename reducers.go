@@ -0,0 +1,153 @@
+package grsearch
+
+// reducer catalog - the reducers the server supports and the arguments each takes. Reducers
+// added with GroupByBuilder.Reduce are checked against the catalog and have their property
+// names prefixed with @, so that mistakes are reported before the aggregate is sent rather
+// than as a syntax error from the server.
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/goslogan/grsearch/internal"
+)
+
+// MaxRandomSampleSize is the largest sample the RANDOM_SAMPLE reducer accepts.
+const MaxRandomSampleSize = 1000
+
+// ReducerError reports a reducer which the server would reject.
+type ReducerError struct {
+	Reducer string
+	Message string
+}
+
+func (e *ReducerError) Error() string {
+	return fmt.Sprintf("grsearch: invalid %s reducer: %s", e.Reducer, e.Message)
+}
+
+// reducerArg is the kind of an argument to a reducer.
+type reducerArg int
+
+const (
+	argProperty   reducerArg = iota // a property name, prefixed with @ if needed
+	argQuantile                     // a number from 0 to 1
+	argSampleSize                   // an integer from 1 to MaxRandomSampleSize
+	argBy                           // the BY keyword
+	argOrder                        // ASC or DESC
+)
+
+// reducerCatalog lists the arguments taken by each reducer. FIRST_VALUE takes a variable
+// number of arguments so is handled by firstValueArgs.
+var reducerCatalog = map[string][]reducerArg{
+	"count":             {},
+	"count_distinct":    {argProperty},
+	"count_distinctish": {argProperty},
+	"sum":               {argProperty},
+	"min":               {argProperty},
+	"max":               {argProperty},
+	"avg":               {argProperty},
+	"stddev":            {argProperty},
+	"quantile":          {argProperty, argQuantile},
+	"tolist":            {argProperty},
+	"random_sample":     {argProperty, argSampleSize},
+}
+
+// firstValueArgs returns the arguments expected by FIRST_VALUE: a property, optionally
+// followed by BY, the property to sort by and an order.
+func firstValueArgs(args []interface{}) ([]reducerArg, error) {
+	switch len(args) {
+	case 1:
+		return []reducerArg{argProperty}, nil
+	case 3:
+		return []reducerArg{argProperty, argBy, argProperty}, nil
+	case 4:
+		return []reducerArg{argProperty, argBy, argProperty, argOrder}, nil
+	}
+	return nil, fmt.Errorf("takes 1, 3 or 4 arguments, got %d", len(args))
+}
+
+// normalize checks a reducer against the catalog, returning a copy with a lower case name,
+// property names prefixed with @ and numbers converted to float64 or int64.
+func (r AggregateReducer) normalize() (AggregateReducer, error) {
+	name := strings.ToLower(r.Name)
+	fail := func(format string, a ...interface{}) (AggregateReducer, error) {
+		return r, &ReducerError{Reducer: name, Message: fmt.Sprintf(format, a...)}
+	}
+
+	kinds, ok := reducerCatalog[name]
+	if name == "first_value" {
+		var err error
+		if kinds, err = firstValueArgs(r.Args); err != nil {
+			return fail("%s", err)
+		}
+	} else if !ok {
+		return fail("unknown reducer")
+	} else if len(r.Args) != len(kinds) {
+		return fail("takes %d arguments, got %d", len(kinds), len(r.Args))
+	}
+
+	var args []interface{}
+	if len(kinds) > 0 {
+		args = make([]interface{}, len(kinds))
+	}
+	for n, kind := range kinds {
+		arg := r.Args[n]
+		switch kind {
+		case argProperty:
+			property, ok := arg.(string)
+			property = strings.TrimPrefix(property, "@")
+			if !ok || property == "" {
+				return fail("argument %d must be a property name, got %v", n+1, arg)
+			}
+			args[n] = "@" + property
+		case argQuantile:
+			quantile, ok := numberArg(arg)
+			if !ok || quantile < 0 || quantile > 1 {
+				return fail("quantile must be a number from 0 to 1, got %v", arg)
+			}
+			args[n] = quantile
+		case argSampleSize:
+			size, ok := numberArg(arg)
+			if !ok || size != float64(int64(size)) || size < 1 || size > MaxRandomSampleSize {
+				return fail("sample size must be an integer from 1 to %d, got %v", MaxRandomSampleSize, arg)
+			}
+			args[n] = int64(size)
+		case argBy:
+			if by, ok := arg.(string); !ok || !strings.EqualFold(by, "BY") {
+				return fail("expected BY, got %v", arg)
+			}
+			args[n] = "BY"
+		case argOrder:
+			order, _ := arg.(string)
+			order = strings.ToUpper(order)
+			if order != SortAsc && order != SortDesc {
+				return fail("order must be ASC or DESC, got %v", arg)
+			}
+			args[n] = order
+		}
+	}
+
+	r.Name = name
+	r.Args = args
+	return r, nil
+}
+
+// numberArg converts a numeric reducer argument, which may be a string, to a float64.
+func numberArg(arg interface{}) (float64, bool) {
+	switch v := arg.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	}
+	f, err := internal.Float64(arg)
+	return f, err == nil
+}
@@ -0,0 +1,208 @@
+package grsearch
+
+// query rewriting - rewriters are run by Client.Process on FT.SEARCH and FT.AGGREGATE commands
+// before they are sent, so they apply however the command was created (FTSearchHash, the
+// stream and iterator helpers, or the command line tool). The rewriters are given copies of the
+// query options, and the command's arguments are rebuilt from the result. The command is marked
+// as rewritten, never the options, so that options read back with Options() and used again are
+// rewritten again. Iterators copy the mark onto their own copy of the options so that the later
+// pages they read, which already have the rewritten query, aren't rewritten a second time.
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/goslogan/grsearch/querylang"
+	"github.com/redis/go-redis/v9"
+)
+
+// QueryRewrite is the search or aggregate passed to a [QueryRewriter]. Exactly one of
+// Search and Aggregate is set; both are copies which the rewriter may change.
+type QueryRewrite struct {
+	Index     string
+	Query     string
+	Search    *QueryOptions
+	Aggregate *AggregateOptions
+}
+
+// Dialect returns the dialect the query will be run with.
+func (q *QueryRewrite) Dialect() uint8 {
+	if q.Search != nil {
+		return q.Search.Dialect
+	}
+	return q.Aggregate.Dialect
+}
+
+// SetParam sets a query parameter for the search or aggregate.
+func (q *QueryRewrite) SetParam(name string, value interface{}) {
+	if q.Search != nil {
+		q.Search.Params[name] = value
+	} else {
+		q.Aggregate.Params[name] = value
+	}
+}
+
+// And adds a clause which every result must match. KNN queries keep their vector clause
+// and have the clause added to their filter.
+func (q *QueryRewrite) And(clause string) error {
+	query, err := andQuery(q.Query, q.Dialect(), clause)
+	if err != nil {
+		return err
+	}
+	q.Query = query
+	return nil
+}
+
+// andQuery adds clauses to a query which every result must match.
+func andQuery(query string, dialect uint8, clauses ...string) (string, error) {
+	original, err := querylang.Parse(query, dialect)
+	if err != nil {
+		return "", err
+	}
+
+	added := []querylang.Node{}
+	for _, clause := range clauses {
+		n, err := querylang.Parse(clause, dialect)
+		if err != nil {
+			return "", err
+		}
+		if _, ok := n.(*querylang.Union); ok {
+			n = &querylang.Group{Child: n}
+		}
+		added = append(added, n)
+	}
+	if len(added) == 0 {
+		return query, nil
+	}
+
+	if knn, ok := original.(*querylang.KNN); ok {
+		knn.Filter = andNodes(added, knn.Filter)
+	} else {
+		original = andNodes(added, original)
+	}
+	return original.String(), nil
+}
+
+// andNodes adds clauses to a query tree, dropping a wildcard.
+func andNodes(clauses []querylang.Node, query querylang.Node) querylang.Node {
+	switch query.(type) {
+	case *querylang.Wildcard:
+		if len(clauses) == 1 {
+			return clauses[0]
+		}
+		return &querylang.Intersection{Children: clauses}
+	case *querylang.Intersection, *querylang.Union:
+		query = &querylang.Group{Child: query}
+	}
+	return &querylang.Intersection{Children: append(clauses, query)}
+}
+
+// QueryRewriter changes a search or aggregate before it is sent. Returning an error stops
+// the command from being sent; the command fails with the error.
+type QueryRewriter func(ctx context.Context, q *QueryRewrite) error
+
+// RequireTag returns a rewriter which restricts every query to documents where a TAG attribute
+// has the value returned by value, for example a tenant identifier held in the context. The
+// command fails if value returns an error or an empty string.
+func RequireTag(attribute string, value func(ctx context.Context) (string, error)) QueryRewriter {
+	return func(ctx context.Context, q *QueryRewrite) error {
+		tag, err := value(ctx)
+		if err != nil {
+			return err
+		}
+		if tag == "" {
+			return fmt.Errorf("grsearch: no value for required tag @%s", attribute)
+		}
+		return q.And(fmt.Sprintf("@%s:{%s}", attribute, Escape(tag)))
+	}
+}
+
+// rewriters holds the query rewriters registered with a client.
+type rewriters struct {
+	mu    sync.RWMutex
+	funcs []QueryRewriter
+}
+
+// AddQueryRewriter registers a rewriter which is run, in the order added, on every FT.SEARCH
+// and FT.AGGREGATE sent by the client.
+func (c *Client) AddQueryRewriter(rewriter QueryRewriter) {
+	c.rewriters.mu.Lock()
+	defer c.rewriters.mu.Unlock()
+	c.rewriters.funcs = append(c.rewriters.funcs, rewriter)
+}
+
+// rewrite runs the rewriters on a search or aggregate, rebuilding its arguments.
+func (c *Client) rewrite(ctx context.Context, cmd redis.Cmder) error {
+	c.rewriters.mu.RLock()
+	funcs := c.rewriters.funcs
+	c.rewriters.mu.RUnlock()
+	if len(funcs) == 0 {
+		return nil
+	}
+
+	switch cmd := cmd.(type) {
+	case *QueryCmd:
+		if cmd.options == nil || cmd.rewritten {
+			return nil
+		}
+		if cmd.options.rewritten {
+			cmd.rewritten = true
+			return nil
+		}
+		q := &QueryRewrite{Index: cmd.Args()[1].(string), Query: cmd.Args()[2].(string), Search: cmd.options.clone()}
+		if err := runRewriters(ctx, funcs, q); err != nil {
+			return err
+		}
+		cmd.rewritten = true
+		cmd.options = q.Search
+		cmd.Cmd = *redis.NewCmd(ctx, append([]interface{}{"FT.SEARCH", q.Index, q.Query}, q.Search.serialize()...)...)
+	case *AggregateCmd:
+		if cmd.options == nil || cmd.rewritten {
+			return nil
+		}
+		q := &QueryRewrite{Index: cmd.index, Query: cmd.Args()[2].(string), Aggregate: cmd.options.clone()}
+		if err := runRewriters(ctx, funcs, q); err != nil {
+			return err
+		}
+		cmd.rewritten = true
+		cmd.options = q.Aggregate
+		cmd.index = q.Index
+		cmd.Cmd = *redis.NewCmd(ctx, append([]interface{}{"FT.AGGREGATE", q.Index, q.Query}, q.Aggregate.serialize()...)...)
+	}
+	return nil
+}
+
+func runRewriters(ctx context.Context, funcs []QueryRewriter, q *QueryRewrite) error {
+	for _, rewriter := range funcs {
+		if err := rewriter(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clone copies the options so that they can be changed without affecting the original.
+func (q *QueryOptions) clone() *QueryOptions {
+	c := *q
+	c.Return = append([]QueryReturn(nil), q.Return...)
+	c.Filters = append([]QueryFilter(nil), q.Filters...)
+	c.GeoFilters = append([]GeoFilter(nil), q.GeoFilters...)
+	c.Params = make(map[string]interface{}, len(q.Params))
+	for k, v := range q.Params {
+		c.Params[k] = v
+	}
+	return &c
+}
+
+// clone copies the options so that they can be changed without affecting the original.
+func (a *AggregateOptions) clone() *AggregateOptions {
+	c := *a
+	c.Load = append([]AggregateLoad(nil), a.Load...)
+	c.Steps = append([]AggregateStep(nil), a.Steps...)
+	c.Params = make(map[string]interface{}, len(a.Params))
+	for k, v := range a.Params {
+		c.Params[k] = v
+	}
+	return &c
+}
@@ -0,0 +1,100 @@
+package grsearch_test
+
+import (
+	"context"
+	"errors"
+
+	grsearch "github.com/goslogan/grsearch"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
+)
+
+type tenantKey struct{}
+
+var _ = Describe("Query rewriting", Label("rewrite"), func() {
+	var tenanted *grsearch.Client
+
+	BeforeEach(func() {
+		tenanted = grsearch.NewClient(&redis.Options{})
+		tenanted.AddQueryRewriter(grsearch.RequireTag("owner", func(ctx context.Context) (string, error) {
+			owner, _ := ctx.Value(tenantKey{}).(string)
+			return owner, nil
+		}))
+		DeferCleanup(tenanted.Close)
+	})
+
+	It("adds the required tag to searches", func() {
+		tctx := context.WithValue(ctx, tenantKey{}, "lara.croft")
+		options := grsearch.NewQueryBuilder().Limit(0, 2).Options()
+		cmd := tenanted.FTSearchHash(tctx, "hcustomers", "*", options)
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		Expect(cmd.Args()[2]).To(Equal(`@owner:{lara\.croft}`))
+		Expect(cmd.TotalResults()).To(Equal(client.FTSearchHash(ctx, "hcustomers", `@owner:{lara\.croft}`, nil).TotalResults()))
+
+		seen := int64(0)
+		iterator := cmd.Iterator(tctx)
+		for iterator.Next(tctx) {
+			Expect(iterator.Val().Values["account_owner"]).To(Equal("lara.croft"))
+			seen++
+		}
+		Expect(iterator.Err()).NotTo(HaveOccurred())
+		Expect(seen).To(Equal(cmd.TotalResults()))
+		Expect(options.Params).To(BeEmpty())
+	})
+
+	It("adds the required tag to aggregates", func() {
+		tctx := context.WithValue(ctx, tenantKey{}, "ellen.ripley")
+		options := grsearch.NewAggregateBuilder().Load("owner", "").Options()
+		cmd := tenanted.FTAggregate(tctx, "hcustomers", "@country:{GB | US}", options)
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		Expect(cmd.Args()[2]).To(Equal(`@owner:{ellen\.ripley} @country:{GB | US}`))
+		for _, row := range cmd.Val() {
+			Expect(row["owner"]).To(Equal("ellen.ripley"))
+		}
+	})
+
+	It("rewrites searches and aggregates run again with the options of an earlier one", func() {
+		tctx := context.WithValue(ctx, tenantKey{}, "lara.croft")
+		first := tenanted.FTSearchHash(tctx, "hcustomers", "*", grsearch.NewQueryBuilder().Limit(0, 2).Options())
+		Expect(first.Err()).NotTo(HaveOccurred())
+
+		other := context.WithValue(ctx, tenantKey{}, "ellen.ripley")
+		second := tenanted.FTSearchHash(other, "hcustomers", "*", first.Options())
+		Expect(second.Err()).NotTo(HaveOccurred())
+		Expect(second.Args()[2]).To(Equal(`@owner:{ellen\.ripley}`))
+		for _, hit := range second.Val() {
+			Expect(hit.Values["account_owner"]).To(Equal("ellen.ripley"))
+		}
+
+		aggregate := tenanted.FTAggregate(tctx, "hcustomers", "*", grsearch.NewAggregateBuilder().Load("owner", "").Options())
+		Expect(aggregate.Err()).NotTo(HaveOccurred())
+		again := tenanted.FTAggregate(other, "hcustomers", "*", aggregate.Options())
+		Expect(again.Err()).NotTo(HaveOccurred())
+		Expect(again.Args()[2]).To(Equal(`@owner:{ellen\.ripley}`))
+	})
+
+	It("refuses to run queries without a tenant", func() {
+		cmd := tenanted.FTSearchHash(ctx, "hcustomers", "*", nil)
+		Expect(cmd.Err()).To(MatchError(ContainSubstring("@owner")))
+	})
+
+	It("adds clauses to the filter of KNN queries", func() {
+		q := &grsearch.QueryRewrite{Query: "(a | b)=>[KNN 5 @vec $v]", Search: grsearch.NewQueryOptions()}
+		Expect(q.And("@owner:{x}")).To(Succeed())
+		Expect(q.Query).To(Equal("(@owner:{x} (a | b))=>[KNN 5 @vec $v]"))
+
+		q = &grsearch.QueryRewrite{Query: "a b", Search: grsearch.NewQueryOptions()}
+		Expect(q.And("@owner:{x}")).To(Succeed())
+		Expect(q.Query).To(Equal("@owner:{x} (a b)"))
+	})
+
+	It("stops the command if a rewriter fails", func() {
+		failed := errors.New("no access")
+		tenanted.AddQueryRewriter(func(ctx context.Context, q *grsearch.QueryRewrite) error {
+			return failed
+		})
+		cmd := tenanted.FTSearchHash(context.WithValue(ctx, tenantKey{}, "x"), "hcustomers", "*", nil)
+		Expect(cmd.Err()).To(MatchError(failed))
+	})
+})
@@ -12,7 +12,9 @@ type SearchCmdAble interface {
 	FTAggregate(ctx context.Context, index string, query string, options *AggregateOptions) *QueryCmd
 	FTDropIndex(ctx context.Context, index string, dropDocuments bool) *redis.BoolCmd
 	FTCreateIndex(ctx context.Context, index string)
+	FTAlter(ctx context.Context, index string, skipInitialScan bool, attributes ...SchemaAttribute) *redis.BoolCmd
 	FTConfigGet(ctx context.Context, keys ...string) *ConfigGetCmd
+	FTConfigGetAll(ctx context.Context) *SearchConfigCmd
 	FTConfigSet(ctx context.Context, name, value string) *redis.BoolCmd
 	FTTagVals(ctx context.Context, index, tag string) *redis.StringSliceCmd
 	FTList(ctx context.Context) *redis.StringSliceCmd
@@ -26,3 +28,9 @@ type SearchCmdAble interface {
 	FTAliasDel(ctx context.Context, alias string) *redis.BoolCmd
 	FTAliasUpdate(ctx context.Context, alias, index string) *redis.BoolCmd
 }
+
+// CursorCmdAble is implemented by clients which can read aggregate results from a cursor.
+type CursorCmdAble interface {
+	FTCursorRead(ctx context.Context, index string, cursorId int64, count uint64) *AggregateCmd
+	FTCursorDel(ctx context.Context, index string, cursorId int64) *redis.StatusCmd
+}
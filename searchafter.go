@@ -0,0 +1,253 @@
+package grsearch
+
+// search after iteration - SearchIterator pages by moving the offset forward which gets slower
+// the deeper we go, fails once we pass MAXSEARCHRESULTS and skips or repeats documents if the
+// index changes whilst we iterate. Instead we ask for sort keys (WITHSORTKEYS) and remember the
+// sort key of the last document read.
+// How...
+//  1 if the last sort key is numeric (#nnn) we add a FILTER on the sort field starting at that
+//    value (inclusive) and set the offset to the number of documents we've already read with
+//    that value.
+//  2 if the sort key is a string ($xxx) we move the offset forward as SearchIterator does.
+//    FT.SEARCH has no way to express a lexical range, so the server still has to skip the
+//    documents already read and the MAXSEARCHRESULTS limit still applies. Results are compared
+//    with the last sort key instead (byte order, as the server compares normalised values) so
+//    documents which move back past it because the index changed are skipped, not repeated.
+//  3 results with the same sort key as the last one returned are checked against the keys we
+//    have already seen (the tiebreaker) so they are not returned twice.
+// Documents with no value for the sort field are dropped by the filter once a numeric bound is in
+// use.
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrSortByRequired is returned by a [SearchAfterIterator] if the query has no SortBy field.
+var ErrSortByRequired = errors.New("grsearch: search after iteration requires SortBy to be set")
+
+// SearchAfterIterator is used to iterate over the results of a sorted search using the sort key of
+// the last result rather than the offset to fetch each page. Searches sorted on a text or tag field
+// are still paged by offset, with results sorting before the last one skipped by the client, so
+// they cost as much as a [SearchIterator] on the server.
+type SearchAfterIterator struct {
+	options *QueryOptions
+	filters []QueryFilter
+	bound   *QueryFilter
+	index   string
+	query   string
+	onHash  bool
+	process cmdable
+	page    []*SearchResult
+	pos     int
+	offset  int64
+	done    bool
+	lastKey string
+	tied    map[string]bool
+	val     *SearchResult
+	err     error
+}
+
+// NewSearchAfterIterator returns a configured keyset iterator for QueryCmd. If the command was run
+// with WithSortKeys set its results are used as the first page, otherwise the first page is fetched
+// again when Next is first called. ErrSortByRequired is returned by Err if the command has no
+// options.
+func NewSearchAfterIterator(ctx context.Context, cmd *QueryCmd, process cmdable) *SearchAfterIterator {
+	if cmd.options == nil {
+		return &SearchAfterIterator{err: ErrSortByRequired}
+	}
+
+	options := *cmd.options
+	options.rewritten = cmd.rewritten
+	options.WithSortKeys = true
+	if options.Limit == nil || options.Limit.Num <= 0 {
+		options.Limit = NewLimit(DefaultOffset, DefaultLimit)
+	} else {
+		options.Limit = NewLimit(options.Limit.Offset, options.Limit.Num)
+	}
+
+	it := &SearchAfterIterator{
+		options: &options,
+		filters: cmd.options.Filters,
+		index:   cmd.Args()[1].(string),
+		query:   cmd.Args()[2].(string),
+		onHash:  cmd.onHash,
+		process: process,
+		offset:  options.Limit.Offset,
+		tied:    map[string]bool{},
+	}
+
+	if options.SortBy == "" {
+		it.err = ErrSortByRequired
+	} else if cmd.Err() != nil {
+		it.err = cmd.Err()
+	} else if cmd.options.WithSortKeys {
+		it.setPage(cmd.Val())
+	}
+
+	return it
+}
+
+// Err returns the last iterator error, if any.
+func (it *SearchAfterIterator) Err() error {
+	return it.err
+}
+
+// Next advances the cursor and returns true if more values can be read.
+func (it *SearchAfterIterator) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+
+	for {
+		for it.pos < len(it.page) {
+			r := it.page[it.pos]
+			it.pos++
+			if it.seen(r) {
+				continue
+			}
+			it.remember(r)
+			it.val = r
+			return true
+		}
+
+		it.val = nil
+		if it.done {
+			return false
+		}
+
+		if it.page != nil {
+			it.advance()
+		}
+
+		if !it.fetch(ctx) {
+			return false
+		}
+	}
+}
+
+// Val returns the result at the current cursor position.
+func (it *SearchAfterIterator) Val() *SearchResult {
+	return it.val
+}
+
+// setPage stores a page of results and checks if it is the last one.
+func (it *SearchAfterIterator) setPage(page []*SearchResult) {
+	if page == nil {
+		page = []*SearchResult{}
+	}
+	it.page = page
+	it.pos = 0
+	it.done = int64(len(page)) < it.options.Limit.Num
+}
+
+// advance uses the last result in the current page to set the bound and offset
+// for the next one. String sort keys and results without a value for the sort
+// field (which sort last) can't be used as a bound, so for them we can only move
+// the offset forward.
+func (it *SearchAfterIterator) advance() {
+	last := it.page[len(it.page)-1]
+
+	if !strings.HasPrefix(last.SortKey, "#") {
+		it.offset += int64(len(it.page))
+		return
+	}
+
+	value := last.SortKey[1:]
+	bound := QueryFilter{Attribute: strings.TrimPrefix(it.options.SortBy, "@")}
+	if it.descending() {
+		bound.Min, bound.Max = "-inf", value
+	} else {
+		bound.Min, bound.Max = value, "+inf"
+	}
+
+	if it.bound != nil && *it.bound == bound {
+		it.offset += int64(len(it.page))
+		return
+	}
+
+	it.bound = &bound
+	it.offset = 0
+	for _, r := range it.page {
+		if r.SortKey == last.SortKey {
+			it.offset++
+		}
+	}
+}
+
+// fetch runs the search for the next page.
+func (it *SearchAfterIterator) fetch(ctx context.Context) bool {
+	options := *it.options
+	options.Limit = NewLimit(it.offset, it.options.Limit.Num)
+	options.Filters = append([]QueryFilter{}, it.filters...)
+	if it.bound != nil {
+		options.Filters = append(options.Filters, *it.bound)
+	}
+
+	var cmd *QueryCmd
+	if it.onHash {
+		cmd = it.process.FTSearchHash(ctx, it.index, it.query, &options)
+	} else {
+		cmd = it.process.FTSearchJSON(ctx, it.index, it.query, &options)
+	}
+
+	if cmd.Err() != nil {
+		it.err = cmd.Err()
+		return false
+	}
+
+	it.setPage(cmd.Val())
+	return it.err == nil
+}
+
+// seen returns true if the result has already been returned or sorts before
+// the last result returned.
+func (it *SearchAfterIterator) seen(r *SearchResult) bool {
+	if r.SortKey == it.lastKey {
+		return it.tied[r.Key]
+	}
+	return it.before(r.SortKey, it.lastKey)
+}
+
+// remember records the result as the last one returned.
+func (it *SearchAfterIterator) remember(r *SearchResult) {
+	if r.SortKey != it.lastKey {
+		it.lastKey = r.SortKey
+		it.tied = map[string]bool{}
+	}
+	it.tied[r.Key] = true
+}
+
+// before compares two numeric or two string sort keys in the sort order of the
+// query. Keys of other types are never considered to be out of order.
+func (it *SearchAfterIterator) before(a, b string) bool {
+	if len(a) == 0 || len(b) == 0 || a[0] != b[0] {
+		return false
+	}
+
+	var less, greater bool
+	switch a[0] {
+	case '#':
+		x, errA := strconv.ParseFloat(a[1:], 64)
+		y, errB := strconv.ParseFloat(b[1:], 64)
+		if errA != nil || errB != nil {
+			return false
+		}
+		less, greater = x < y, x > y
+	case '$':
+		less, greater = a < b, a > b
+	default:
+		return false
+	}
+
+	if it.descending() {
+		return greater
+	}
+	return less
+}
+
+func (it *SearchAfterIterator) descending() bool {
+	return strings.EqualFold(it.options.SortOrder, SortDesc)
+}
@@ -22,7 +22,22 @@ func (c cmdable) FTCreate(ctx context.Context, index string, options *IndexOptio
 	args := []interface{}{"FT.CREATE", index}
 	args = append(args, options.serialize()...)
 	cmd := redis.NewBoolCmd(ctx, args...)
-	_ = c(ctx, cmd)
+	_ = c(ctx, gate(cmd, options.requiredFeatures()))
+	return cmd
+}
+
+// FTAlter adds attributes to the schema of an existing index.
+func (c cmdable) FTAlter(ctx context.Context, index string, skipInitialScan bool, attributes ...SchemaAttribute) *redis.BoolCmd {
+	args := []interface{}{"FT.ALTER", index}
+	if skipInitialScan {
+		args = append(args, "SKIPINITIALSCAN")
+	}
+	args = append(args, "SCHEMA", "ADD")
+	for _, a := range attributes {
+		args = append(args, a.serialize()...)
+	}
+	cmd := redis.NewBoolCmd(ctx, args...)
+	_ = c(ctx, gate(cmd, attributeFeatures(attributes)))
 	return cmd
 }
 
@@ -31,6 +46,35 @@ func (c cmdable) FTAggregate(ctx context.Context, index, query string, options *
 	args := []interface{}{"FT.AGGREGATE", index, query}
 	args = append(args, options.serialize()...)
 	cmd := NewAggregateCmd(ctx, args...)
+	cmd.options = options
+	cmd.index = index
+	cmd.process = c
+	if options.err != nil {
+		cmd.SetErr(options.err)
+		return cmd
+	}
+	_ = c(ctx, gate(cmd, options.requiredFeatures()))
+	return cmd
+}
+
+// FTCursorRead reads the next set of results from an aggregate cursor. If count is zero the count
+// given when the cursor was created is used.
+func (c cmdable) FTCursorRead(ctx context.Context, index string, cursorId int64, count uint64) *AggregateCmd {
+	args := []interface{}{"FT.CURSOR", "READ", index, cursorId}
+	if count != 0 {
+		args = append(args, "COUNT", count)
+	}
+	cmd := NewAggregateCmd(ctx, args...)
+	cmd.index = index
+	cmd.process = c
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+// FTCursorDel deletes an aggregate cursor before it has been read to the end.
+func (c cmdable) FTCursorDel(ctx context.Context, index string, cursorId int64) *redis.StatusCmd {
+	args := []interface{}{"FT.CURSOR", "DEL", index, cursorId}
+	cmd := redis.NewStatusCmd(ctx, args...)
 	_ = c(ctx, cmd)
 	return cmd
 }
@@ -46,7 +90,7 @@ func (c cmdable) FTSearchHash(ctx context.Context, index string, query string, q
 	cmd := NewQueryCmd(ctx, c, true, args...)
 	cmd.options = qryOptions
 
-	_ = c(ctx, cmd)
+	_ = c(ctx, gate(cmd, qryOptions.requiredFeatures()))
 	return cmd
 }
 
@@ -62,7 +106,7 @@ func (c cmdable) FTSearchJSON(ctx context.Context, index string, query string, q
 	cmd := NewQueryCmd(ctx, c, false, args...)
 	cmd.options = qryOptions
 
-	_ = c(ctx, cmd)
+	_ = c(ctx, gate(cmd, qryOptions.requiredFeatures()))
 	return cmd
 }
 
@@ -84,7 +128,15 @@ func (c cmdable) FTConfigGet(ctx context.Context, keys ...string) *ConfigGetCmd
 	return cmd
 }
 
-// FTConfigGet sets values in the search config
+// FTConfigGetAll retrieves the whole search config, decoded into a [SearchConfig]
+func (c cmdable) FTConfigGetAll(ctx context.Context) *SearchConfigCmd {
+	args := []interface{}{"FT.CONFIG", "GET", "*"}
+	cmd := NewSearchConfigCmd(ctx, args...)
+	_ = c(ctx, cmd)
+	return cmd
+}
+
+// FTConfigSet sets values in the search config
 func (c cmdable) FTConfigSet(ctx context.Context, name, value string) *redis.BoolCmd {
 	args := []interface{}{"FT.CONFIG", "SET", name, value}
 
@@ -24,11 +24,20 @@ type SearchIterator struct {
 	cmd     *QueryCmd
 }
 
-// NewSearchIterator returns a configured iterator for QueryCmd
+// NewSearchIterator returns a configured iterator for QueryCmd. The iterator pages with its own
+// copy of the command's options so the command can be iterated over again.
 func NewSearchIterator(ctx context.Context, cmd *QueryCmd, process cmdable) *SearchIterator {
+	options := *NewQueryOptions()
+	if cmd.options != nil {
+		options = *cmd.options
+	}
+	options.rewritten = cmd.rewritten
+	if options.Limit != nil {
+		options.Limit = NewLimit(options.Limit.Offset, options.Limit.Num)
+	}
 	return &SearchIterator{
 		cmd:     cmd,
-		options: cmd.options,
+		options: &options,
 		index:   cmd.Args()[1].(string),
 		query:   cmd.Args()[2].(string),
 		process: process,
@@ -87,7 +96,7 @@ func (it *SearchIterator) Next(ctx context.Context) bool {
 // Val returns the key/field at the current cursor position.
 func (it *SearchIterator) Val() *SearchResult {
 	var v *SearchResult
-	if it.cmd.Err() == nil && it.pos > 0 && it.pos <= it.options.Limit.Num {
+	if it.cmd.Err() == nil && it.pos > 0 && it.pos <= it.cmd.Len() {
 		v = it.cmd.Val()[it.pos-1]
 	}
 	return v
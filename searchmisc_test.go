@@ -1,10 +1,16 @@
 package grsearch_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	grsearch "github.com/goslogan/grsearch"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
 )
 
 var _ = Describe("Synonyms", Ordered, Label("synonyms", "search"), func() {
@@ -27,6 +33,15 @@ var _ = Describe("Synonyms", Ordered, Label("synonyms", "search"), func() {
 	})
 })
 
+var _ = Describe("Failed synonym dumps", Label("synonyms", "search", "ft.syndump"), func() {
+
+	It("reports the error without parsing the reply", func() {
+		cmd := offline.FTSynDump(ctx, "hcustomers")
+		Expect(cmd.Err()).To(MatchError(errOffline))
+		Expect(cmd.Val()).To(BeEmpty())
+	})
+})
+
 var _ = Describe("Tagvals", Label("search", "ft.tagvals"), func() {
 
 	It("can get tag values from the index", func() {
@@ -46,6 +61,45 @@ var _ = Describe("Info", Label("search", "ft.info"), func() {
 		Expect(cmd.Err()).NotTo(HaveOccurred())
 	})
 
+	It("reports errors without parsing the reply", func() {
+		cmd := client.FTInfo(ctx, "nosuchindex")
+		Expect(cmd.Err()).To(HaveOccurred())
+		Expect(cmd.Val()).To(BeNil())
+
+		cmd = offline.FTInfo(ctx, "hcustomers")
+		Expect(cmd.Err()).To(MatchError(errOffline))
+		Expect(cmd.Val()).To(BeNil())
+	})
+
+	It("reports indexing errors by attribute", Label("ft.create", "errors"), func() {
+		options := grsearch.NewIndexOptions()
+		options.Prefix = []string{"herrors:"}
+		options.Schema = []grsearch.SchemaAttribute{
+			&grsearch.TextAttribute{Name: "name"},
+			&grsearch.NumericAttribute{Name: "balance"},
+		}
+		Expect(client.FTCreate(ctx, "herrors", options).Err()).NotTo(HaveOccurred())
+		DeferCleanup(func() {
+			client.FTDropIndex(ctx, "herrors", true)
+		})
+		Expect(client.HSet(ctx, "herrors:1", "name", "fine", "balance", "1").Err()).NotTo(HaveOccurred())
+		Expect(client.HSet(ctx, "herrors:2", "name", "broken", "balance", "lots").Err()).NotTo(HaveOccurred())
+
+		info, err := client.FTInfo(ctx, "herrors").Result()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.IndexingFailures).To(Equal(int64(1)))
+		Expect(info.IndexErrors.IndexingFailures).To(Equal(int64(1)))
+		Expect(info.IndexErrors.LastErrorKey).To(Equal("herrors:2"))
+		Expect(info.IndexErrors.LastError).NotTo(BeEmpty())
+		Expect(info.FieldStats).To(HaveLen(2))
+		Expect(info.FieldStats[0].Errors.LastError).To(BeEmpty())
+
+		failed := info.FailedFields()
+		Expect(failed).To(HaveLen(1))
+		Expect(failed[0].Attribute).To(Equal("balance"))
+		Expect(failed[0].Errors.LastErrorKey).To(Equal("herrors:2"))
+	})
+
 	It("can recreate an index", Label("FT.CREATE", "FT.INFO", "rebuild"), func() {
 		cmd1 := client.FTInfo(ctx, "hcustomers")
 		Expect(cmd1.Err()).NotTo(HaveOccurred())
@@ -60,3 +114,124 @@ var _ = Describe("Info", Label("search", "ft.info"), func() {
 	})
 
 })
+
+var _ = Describe("Config", Ordered, Label("search", "ft.config"), func() {
+	var original *grsearch.SearchConfig
+
+	BeforeAll(func() {
+		var err error
+		original, err = client.FTConfigGetAll(ctx).Result()
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(func() {
+			_, err := client.FTConfigApply(ctx, func(config *grsearch.SearchConfig) error {
+				config.Timeout = original.Timeout
+				config.MinPrefix = original.MinPrefix
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	It("includes internal settings in FT.CONFIG GET", func() {
+		config, err := client.FTConfigGet(ctx).Result()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config).To(HaveKey(HavePrefix("_")))
+	})
+
+	It("decodes the config into typed values", func() {
+		Expect(original.Timeout).To(BeNumerically(">", 0))
+		Expect(original.OnTimeout).To(BeElementOf(grsearch.OnTimeoutReturn, grsearch.OnTimeoutFail))
+		Expect(original.DefaultDialect).To(BeNumerically(">=", 1))
+		Expect(original.MinPrefix).To(BeNumerically(">", 0))
+		Expect(original.Extra).To(HaveKey(HavePrefix("_")))
+	})
+
+	It("applies only the values which have changed", func() {
+		changed, err := client.FTConfigApply(ctx, func(config *grsearch.SearchConfig) error {
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeEmpty())
+
+		changed, err = client.FTConfigApply(ctx, func(config *grsearch.SearchConfig) error {
+			config.Timeout = original.Timeout + time.Second
+			config.MinPrefix = original.MinPrefix + 1
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(ConsistOf("MINPREFIX", "TIMEOUT"))
+
+		updated, err := client.FTConfigGetAll(ctx).Result()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.Timeout).To(Equal(original.Timeout + time.Second))
+		Expect(updated.MinPrefix).To(Equal(original.MinPrefix + 1))
+	})
+
+	It("sends only the settings which are changed", func() {
+		sent := &configSets{}
+		recorded := grsearch.NewClient(&redis.Options{})
+		DeferCleanup(recorded.Close)
+		recorded.AddHook(sent)
+
+		changed, err := recorded.FTConfigApply(ctx, func(config *grsearch.SearchConfig) error {
+			config.Timeout = original.Timeout + 2*time.Second
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(Equal([]string{"TIMEOUT"}))
+		Expect(sent.names).To(Equal([]string{"TIMEOUT"}))
+	})
+
+	It("rejects values the server won't accept", func() {
+		_, err := client.FTConfigApply(ctx, func(config *grsearch.SearchConfig) error {
+			config.OnTimeout = "retry"
+			return nil
+		})
+		Expect(err).To(MatchError(ContainSubstring("ON_TIMEOUT")))
+
+		_, err = client.FTConfigApply(ctx, func(config *grsearch.SearchConfig) error {
+			config.DefaultDialect = 9
+			return nil
+		})
+		Expect(err).To(MatchError(ContainSubstring("DEFAULT_DIALECT")))
+	})
+
+	It("changes nothing if the update fails", func() {
+		failed := errors.New("no change")
+		changed, err := client.FTConfigApply(ctx, func(config *grsearch.SearchConfig) error {
+			config.MinPrefix = original.MinPrefix + 5
+			return failed
+		})
+		Expect(err).To(MatchError(failed))
+		Expect(changed).To(BeEmpty())
+		Expect(client.FTConfigGetAll(ctx).Val().MinPrefix).To(Equal(original.MinPrefix))
+	})
+
+	It("round trips through Encode and DecodeSearchConfig", func() {
+		decoded, err := grsearch.DecodeSearchConfig(original.Encode())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(decoded).To(Equal(original))
+	})
+})
+
+// configSets records the names of the settings sent with FT.CONFIG SET.
+type configSets struct {
+	names []string
+}
+
+func (h *configSets) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *configSets) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if args := cmd.Args(); len(args) > 2 && cmd.Name() == "ft.config" && strings.EqualFold(fmt.Sprint(args[1]), "set") {
+			h.names = append(h.names, fmt.Sprint(args[2]))
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (h *configSets) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
@@ -0,0 +1,49 @@
+//go:build go1.23
+
+package grsearch
+
+// range over func iterators - these are built on ForEach so paging and the clean up of aggregate
+// cursors when the caller breaks out of the loop are shared with the streaming APIs.
+
+import (
+	"context"
+	"errors"
+	"iter"
+)
+
+// errStopIteration is used to stop ForEach when the caller breaks out of a range loop.
+var errStopIteration = errors.New("grsearch: iteration stopped")
+
+// All returns an iterator over every result of the search, fetching further pages as
+// required. If the search fails the error is yielded with a nil result and iteration stops.
+// Each range over the iterator pages from the start of the search again.
+func (cmd *QueryCmd) All(ctx context.Context) iter.Seq2[*SearchResult, error] {
+	return func(yield func(*SearchResult, error) bool) {
+		err := cmd.ForEach(ctx, func(r *SearchResult) error {
+			if !yield(r, nil) {
+				return errStopIteration
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errStopIteration) {
+			yield(nil, err)
+		}
+	}
+}
+
+// All returns an iterator over every row of the aggregate, reading from the cursor if one
+// was requested. The cursor is deleted if the caller stops early. If the aggregate fails
+// the error is yielded with a nil row and iteration stops.
+func (cmd *AggregateCmd) All(ctx context.Context) iter.Seq2[map[string]interface{}, error] {
+	return func(yield func(map[string]interface{}, error) bool) {
+		err := cmd.ForEach(ctx, func(row map[string]interface{}) error {
+			if !yield(row, nil) {
+				return errStopIteration
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errStopIteration) {
+			yield(nil, err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+//go:build go1.23
+
+package grsearch_test
+
+import (
+	grsearch "github.com/goslogan/grsearch"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Range over search results", Label("search", "hash", "ft.search", "iter"), func() {
+
+	It("can range over every result", func() {
+		options := grsearch.NewQueryBuilder().
+			NoContent().
+			Limit(0, 3).
+			Options()
+		keys := []string{}
+		for r, err := range client.FTSearchHash(ctx, "hcustomers", `@owner:{lara\.croft}`, options).All(ctx) {
+			Expect(err).NotTo(HaveOccurred())
+			keys = append(keys, r.Key)
+		}
+		Expect(keys).To(HaveLen(10))
+	})
+
+	It("can break out of the loop", func() {
+		options := grsearch.NewQueryBuilder().
+			NoContent().
+			Limit(0, 3).
+			Options()
+		count := 0
+		for _, err := range client.FTSearchHash(ctx, "hcustomers", `*`, options).All(ctx) {
+			Expect(err).NotTo(HaveOccurred())
+			count++
+			if count == 5 {
+				break
+			}
+		}
+		Expect(count).To(Equal(5))
+	})
+
+	It("can range over the same results more than once", func() {
+		options := grsearch.NewQueryBuilder().
+			NoContent().
+			Limit(0, 3).
+			Options()
+		all := client.FTSearchHash(ctx, "hcustomers", `@owner:{lara\.croft}`, options).All(ctx)
+		ranges := [][]string{}
+		for n := 0; n < 2; n++ {
+			keys := []string{}
+			for r, err := range all {
+				Expect(err).NotTo(HaveOccurred())
+				keys = append(keys, r.Key)
+			}
+			ranges = append(ranges, keys)
+		}
+		Expect(ranges[0]).To(HaveLen(10))
+		Expect(ranges[1]).To(Equal(ranges[0]))
+		Expect(options.Limit.Offset).To(BeZero())
+	})
+
+	It("yields search errors", func() {
+		count := 0
+		for r, err := range client.FTSearchHash(ctx, "nosuchindex", `*`, nil).All(ctx) {
+			Expect(r).To(BeNil())
+			Expect(err).To(HaveOccurred())
+			count++
+		}
+		Expect(count).To(Equal(1))
+	})
+})
+
+var _ = Describe("Range over aggregate results", Label("ft.aggregate", "iter", "cursor"), func() {
+
+	It("can range over every row read from a cursor", func() {
+		opts := grsearch.NewAggregateBuilder().
+			Load("customer", "").
+			Cursor(10, 0).
+			Options()
+		rows := 0
+		for row, err := range client.FTAggregate(ctx, "hcustomers", `*`, opts).All(ctx) {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(row).To(HaveKey("customer"))
+			rows++
+		}
+		Expect(rows).To(Equal(25))
+	})
+
+	It("deletes the cursor when we break out of the loop", func() {
+		opts := grsearch.NewAggregateBuilder().
+			Load("customer", "").
+			Cursor(5, 0).
+			Options()
+		before := client.FTInfo(ctx, "hcustomers")
+		Expect(before.Err()).NotTo(HaveOccurred())
+		for _, err := range client.FTAggregate(ctx, "hcustomers", `*`, opts).All(ctx) {
+			Expect(err).NotTo(HaveOccurred())
+			break
+		}
+		after := client.FTInfo(ctx, "hcustomers")
+		Expect(after.Err()).NotTo(HaveOccurred())
+		Expect(after.Val().CursorStats.IndexTotal).To(Equal(before.Val().CursorStats.IndexTotal))
+	})
+})
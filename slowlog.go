@@ -0,0 +1,452 @@
+package grsearch
+
+// slow query log - SlowLog is a redis hook timing FT.SEARCH and FT.AGGREGATE. Queries taking longer
+// than the threshold, and a random sample of the others, are passed to a sink. Slow queries can be
+// run again under FT.PROFILE using the next function in the hook chain so the plan can be recorded
+// along with the query. Profiling runs in the background on a context with the values of the
+// caller's, detached from its cancellation and bounded by a timeout, so it neither delays the
+// caller nor fails when the caller's context is cancelled; those queries reach the sink, with the
+// detached context, once their profile is done. Aggregates using a cursor are never profiled because
+// FT.PROFILE would leave another cursor open on the server. The hook is added with Client.AddHook
+// so that result counts are available.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SlowQuery describes a search or aggregate recorded by a [SlowLog].
+type SlowQuery struct {
+	Time         time.Time     `json:"time"`                    // When the query was started
+	Command      string        `json:"command"`                 // FT.SEARCH or FT.AGGREGATE
+	Index        string        `json:"index"`                   // The index queried
+	Query        string        `json:"query"`                   // The query string
+	Args         []interface{} `json:"args"`                    // The serialized query or aggregate options
+	Duration     time.Duration `json:"duration"`                // Time taken, in nanoseconds when encoded
+	Results      int64         `json:"results"`                 // Number of results returned
+	TotalResults int64         `json:"total_results"`           // Total number of results reported by the server
+	Error        string        `json:"error,omitempty"`         // The error returned, if any
+	Sampled      bool          `json:"sampled,omitempty"`       // The query was sampled rather than slow
+	Pipeline     bool          `json:"pipeline,omitempty"`      // The query was run in a pipeline; the duration is for the pipeline
+	Profile      interface{}   `json:"profile,omitempty"`       // The profile returned by FT.PROFILE
+	ProfileError string        `json:"profile_error,omitempty"` // Why the query could not be profiled
+}
+
+// SlowLogSink receives the queries recorded by a [SlowLog]. Record is called from the goroutine
+// running the query, or from a background goroutine for profiled queries, so it should be quick
+// and safe for concurrent use. ctx is the context of the query or, for profiled queries, a context
+// with its values which is never cancelled.
+type SlowLogSink interface {
+	Record(ctx context.Context, query *SlowQuery)
+}
+
+// SlowLogFunc adapts a function to a [SlowLogSink].
+type SlowLogFunc func(ctx context.Context, query *SlowQuery)
+
+// Record calls f.
+func (f SlowLogFunc) Record(ctx context.Context, query *SlowQuery) {
+	f(ctx, query)
+}
+
+// DefaultProfileTimeout is the time allowed to profile a slow query if [SlowLogOptions] doesn't set one.
+const DefaultProfileTimeout = 10 * time.Second
+
+// SlowLogOptions configures a [SlowLog].
+type SlowLogOptions struct {
+	Threshold      time.Duration // Queries taking at least this long are recorded
+	SampleRate     float64       // Fraction (0 to 1) of the queries under the threshold to record as well
+	Profile        bool          // Run slow queries again under FT.PROFILE in the background and record the profile
+	ProfileTimeout time.Duration // Time allowed for FT.PROFILE, DefaultProfileTimeout if zero
+	Sink           SlowLogSink   // Where queries are recorded (required)
+}
+
+// SlowLog is a [redis.Hook] recording slow searches and aggregates. Add it with [Client.AddHook].
+type SlowLog struct {
+	options   SlowLogOptions
+	lock      sync.Mutex
+	random    *rand.Rand
+	profiling sync.WaitGroup
+}
+
+var _ redis.Hook = (*SlowLog)(nil)
+
+// NewSlowLog returns a slow query log hook.
+func NewSlowLog(options *SlowLogOptions) *SlowLog {
+	return &SlowLog{
+		options: *options,
+		random:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// DialHook implements [redis.Hook].
+func (s *SlowLog) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+// ProcessHook implements [redis.Hook].
+func (s *SlowLog) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if !isSlowLogged(cmd) {
+			return next(ctx, cmd)
+		}
+
+		start := time.Now()
+		err := next(ctx, cmd)
+		if query := s.check(cmd, start, time.Since(start), false); query != nil {
+			if query.shouldProfile(s.options.Profile) {
+				s.profile(ctx, []*SlowQuery{query}, func(ctx context.Context, cmds []redis.Cmder) {
+					_ = next(ctx, cmds[0])
+				})
+			} else {
+				s.options.Sink.Record(ctx, query)
+			}
+		}
+		return err
+	}
+}
+
+// ProcessPipelineHook implements [redis.Hook]. Queries in a pipeline are timed using the
+// duration of the whole pipeline.
+func (s *SlowLog) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		duration := time.Since(start)
+
+		profiled := []*SlowQuery{}
+		for _, cmd := range cmds {
+			if !isSlowLogged(cmd) {
+				continue
+			}
+			if query := s.check(cmd, start, duration, true); query != nil {
+				if query.shouldProfile(s.options.Profile) {
+					profiled = append(profiled, query)
+				} else {
+					s.options.Sink.Record(ctx, query)
+				}
+			}
+		}
+
+		if len(profiled) > 0 {
+			s.profile(ctx, profiled, func(ctx context.Context, cmds []redis.Cmder) {
+				_ = next(ctx, cmds)
+			})
+		}
+		return err
+	}
+}
+
+// Wait blocks until the queries being profiled have been recorded.
+func (s *SlowLog) Wait() {
+	s.profiling.Wait()
+}
+
+// profile runs FT.PROFILE for the queries in the background and then records them. The profiles
+// are run by the run function on a context which has the values of the query's but isn't cancelled
+// with it and does time out.
+func (s *SlowLog) profile(ctx context.Context, queries []*SlowQuery, run func(ctx context.Context, cmds []redis.Cmder)) {
+	timeout := s.options.ProfileTimeout
+	if timeout <= 0 {
+		timeout = DefaultProfileTimeout
+	}
+
+	ctx = detachedContext{ctx}
+	s.profiling.Add(1)
+	go func() {
+		defer s.profiling.Done()
+
+		profileCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		cmds := make([]redis.Cmder, len(queries))
+		for n, query := range queries {
+			cmds[n] = query.profileCmd(profileCtx)
+		}
+		run(profileCtx, cmds)
+
+		for n, query := range queries {
+			query.setProfile(cmds[n].(*redis.Cmd))
+			s.options.Sink.Record(ctx, query)
+		}
+	}()
+}
+
+// detachedContext keeps the values of a context but is never cancelled and has no deadline.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (d detachedContext) Value(key interface{}) interface{} {
+	return d.parent.Value(key)
+}
+
+// isSlowLogged reports whether a command is a search or an aggregate.
+func isSlowLogged(cmd redis.Cmder) bool {
+	name := cmd.Name()
+	return (name == "ft.search" || name == "ft.aggregate") && len(cmd.Args()) >= 3
+}
+
+// check returns the query to record for a command, or nil if it isn't slow and isn't sampled.
+func (s *SlowLog) check(cmd redis.Cmder, start time.Time, duration time.Duration, pipeline bool) *SlowQuery {
+	sampled := false
+	if duration < s.options.Threshold {
+		if s.options.SampleRate <= 0 {
+			return nil
+		}
+		s.lock.Lock()
+		sampled = s.random.Float64() < s.options.SampleRate
+		s.lock.Unlock()
+		if !sampled {
+			return nil
+		}
+	}
+
+	args := cmd.Args()
+	query := &SlowQuery{
+		Time:     start,
+		Command:  strings.ToUpper(cmd.Name()),
+		Index:    fmt.Sprint(args[1]),
+		Query:    fmt.Sprint(args[2]),
+		Args:     args[3:],
+		Duration: duration,
+		Sampled:  sampled,
+		Pipeline: pipeline,
+	}
+
+	if err := cmd.Err(); err != nil {
+		query.Error = err.Error()
+	}
+	switch c := cmd.(type) {
+	case *QueryCmd:
+		query.Results = int64(len(c.Val()))
+		query.TotalResults = c.TotalResults()
+	case *AggregateCmd:
+		query.Results = int64(len(c.Val()))
+		query.TotalResults = c.TotalResults()
+	}
+
+	return query
+}
+
+// shouldProfile reports whether the query should be run under FT.PROFILE.
+func (q *SlowQuery) shouldProfile(profile bool) bool {
+	return profile && !q.Sampled && q.Error == "" && !q.usesCursor()
+}
+
+// usesCursor reports whether the query is an aggregate reading its results with a cursor.
+func (q *SlowQuery) usesCursor() bool {
+	if q.Command != "FT.AGGREGATE" {
+		return false
+	}
+	for _, arg := range q.Args {
+		if s, ok := arg.(string); ok && strings.EqualFold(s, "WITHCURSOR") {
+			return true
+		}
+	}
+	return false
+}
+
+// profileCmd returns the FT.PROFILE command for the query.
+func (q *SlowQuery) profileCmd(ctx context.Context) *redis.Cmd {
+	args := []interface{}{"FT.PROFILE", q.Index, strings.TrimPrefix(q.Command, "FT."), "QUERY", q.Query}
+	return redis.NewCmd(ctx, append(args, q.Args...)...)
+}
+
+// setProfile records the profile from an FT.PROFILE reply. RESP2 replies are the
+// results followed by the profile; RESP3 replies are a map.
+func (q *SlowQuery) setProfile(cmd *redis.Cmd) {
+	if err := cmd.Err(); err != nil {
+		q.ProfileError = err.Error()
+		return
+	}
+
+	switch r := cmd.Val().(type) {
+	case []interface{}:
+		if len(r) == 2 {
+			q.Profile = jsonValue(r[1])
+			return
+		}
+	case map[interface{}]interface{}:
+		for k, v := range r {
+			if strings.EqualFold(fmt.Sprint(k), "profile") {
+				q.Profile = jsonValue(v)
+				return
+			}
+		}
+	}
+	q.ProfileError = "unexpected FT.PROFILE response"
+}
+
+// jsonValue converts the maps in a reply to map[string]interface{} so it can be encoded as JSON.
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = jsonValue(value)
+		}
+		return m
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for n, value := range v {
+			values[n] = jsonValue(value)
+		}
+		return values
+	default:
+		return v
+	}
+}
+
+/******************************************************************************
+* Sinks
+******************************************************************************/
+
+// SlowLogRing keeps the most recent queries in memory.
+type SlowLogRing struct {
+	lock    sync.Mutex
+	entries []*SlowQuery
+	next    int
+	full    bool
+}
+
+// NewSlowLogRing returns a ring buffer holding up to size queries.
+func NewSlowLogRing(size int) *SlowLogRing {
+	return &SlowLogRing{entries: make([]*SlowQuery, size)}
+}
+
+// Record implements [SlowLogSink], replacing the oldest query once the buffer is full.
+func (r *SlowLogRing) Record(_ context.Context, query *SlowQuery) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if len(r.entries) == 0 {
+		return
+	}
+	r.entries[r.next] = query
+	r.next = (r.next + 1) % len(r.entries)
+	r.full = r.full || r.next == 0
+}
+
+// Queries returns the recorded queries, oldest first.
+func (r *SlowLogRing) Queries() []*SlowQuery {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.full {
+		return append([]*SlowQuery{}, r.entries[:r.next]...)
+	}
+	return append(append([]*SlowQuery{}, r.entries[r.next:]...), r.entries[:r.next]...)
+}
+
+// SlowLogFile writes queries to a file as JSON, one per line. When the file reaches its maximum
+// size it is renamed with a ".1" suffix, replacing any previous one, and a new file is started so
+// at most twice the maximum size is kept.
+type SlowLogFile struct {
+	lock    sync.Mutex
+	path    string
+	maxSize int64
+	file    *os.File
+	size    int64
+	err     error
+}
+
+// NewSlowLogFile opens (or creates) a slow log file, appending to it.
+func NewSlowLogFile(path string, maxSize int64) (*SlowLogFile, error) {
+	f := &SlowLogFile{path: path, maxSize: maxSize}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *SlowLogFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, stat.Size()
+	return nil
+}
+
+// Record implements [SlowLogSink]. Write errors are kept and returned by Err.
+func (f *SlowLogFile) Record(_ context.Context, query *SlowQuery) {
+	line, err := json.Marshal(query)
+	if err != nil {
+		f.setErr(err)
+		return
+	}
+	line = append(line, '\n')
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		return
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(line)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			f.err = err
+			return
+		}
+	}
+	n, err := f.file.Write(line)
+	f.size += int64(n)
+	if err != nil {
+		f.err = err
+	}
+}
+
+// rotate moves the current file aside and starts a new one.
+func (f *SlowLogFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	if err := os.Rename(f.path, f.path+".1"); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+func (f *SlowLogFile) setErr(err error) {
+	f.lock.Lock()
+	f.err = err
+	f.lock.Unlock()
+}
+
+// Err returns the last error writing to the file.
+func (f *SlowLogFile) Err() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.err
+}
+
+// Close closes the file.
+func (f *SlowLogFile) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,54 @@
+//go:build go1.21
+
+package grsearch
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlowLogSlog is a [SlowLogSink] writing queries to a structured logger.
+type SlowLogSlog struct {
+	Logger *slog.Logger // Defaults to slog.Default()
+	Level  slog.Level   // Level for slow queries; sampled queries are logged at debug level
+}
+
+// Record implements [SlowLogSink].
+func (s *SlowLogSlog) Record(ctx context.Context, query *SlowQuery) {
+	logger := s.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	level, message := s.Level, "slow query"
+	if query.Sampled {
+		level, message = slog.LevelDebug, "sampled query"
+	}
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("command", query.Command),
+		slog.String("index", query.Index),
+		slog.String("query", query.Query),
+		slog.Any("args", query.Args),
+		slog.Duration("duration", query.Duration),
+		slog.Int64("results", query.Results),
+		slog.Int64("total_results", query.TotalResults),
+	}
+	if query.Error != "" {
+		attrs = append(attrs, slog.String("error", query.Error))
+	}
+	if query.Pipeline {
+		attrs = append(attrs, slog.Bool("pipeline", true))
+	}
+	if query.Profile != nil {
+		attrs = append(attrs, slog.Any("profile", query.Profile))
+	}
+	if query.ProfileError != "" {
+		attrs = append(attrs, slog.String("profile_error", query.ProfileError))
+	}
+
+	logger.LogAttrs(ctx, level, message, attrs...)
+}
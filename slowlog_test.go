@@ -0,0 +1,169 @@
+package grsearch_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	grsearch "github.com/goslogan/grsearch"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ = Describe("Slow query log", Label("slowlog"), func() {
+	var logged *grsearch.Client
+	var slowLog *grsearch.SlowLog
+	var ring *grsearch.SlowLogRing
+
+	// withSlowLog creates a client logging to the ring with the given options.
+	withSlowLog := func(options grsearch.SlowLogOptions) {
+		ring = grsearch.NewSlowLogRing(2)
+		if options.Sink == nil {
+			options.Sink = ring
+		}
+		slowLog = grsearch.NewSlowLog(&options)
+		logged = grsearch.NewClient(&redis.Options{})
+		logged.AddHook(slowLog)
+	}
+
+	AfterEach(func() {
+		slowLog.Wait()
+		Expect(logged.Close()).To(Succeed())
+	})
+
+	It("records slow queries with their profile", func() {
+		withSlowLog(grsearch.SlowLogOptions{Profile: true})
+
+		cmd := logged.FTSearchHash(ctx, "hcustomers", "@owner:{lara\\.croft}", grsearch.NewQueryBuilder().
+			SortBy("balance").
+			Limit(0, 5).
+			Options())
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		slowLog.Wait()
+
+		queries := ring.Queries()
+		Expect(queries).To(HaveLen(1))
+		Expect(queries[0].Command).To(Equal("FT.SEARCH"))
+		Expect(queries[0].Index).To(Equal("hcustomers"))
+		Expect(queries[0].Args).To(Equal(cmd.Args()[3:]))
+		Expect(queries[0].Results).To(BeEquivalentTo(len(cmd.Val())))
+		Expect(queries[0].TotalResults).To(Equal(cmd.TotalResults()))
+		Expect(queries[0].ProfileError).To(BeEmpty())
+		Expect(queries[0].Profile).NotTo(BeNil())
+
+		_, err := json.Marshal(queries[0])
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("profiles queries after their context is cancelled", func() {
+		withSlowLog(grsearch.SlowLogOptions{Profile: true})
+
+		cctx, cancel := context.WithCancel(ctx)
+		Expect(logged.FTSearchHash(cctx, "hcustomers", "@owner:{lara\\.croft}", nil).Err()).NotTo(HaveOccurred())
+		cancel()
+		slowLog.Wait()
+
+		queries := ring.Queries()
+		Expect(queries).To(HaveLen(1))
+		Expect(queries[0].ProfileError).To(BeEmpty())
+		Expect(queries[0].Profile).NotTo(BeNil())
+	})
+
+	It("records profiled queries with a context which has the values of the query's", func() {
+		type key struct{}
+		var recordCtx context.Context
+		withSlowLog(grsearch.SlowLogOptions{Profile: true, Sink: grsearch.SlowLogFunc(func(ctx context.Context, query *grsearch.SlowQuery) {
+			recordCtx = ctx
+		})})
+
+		cctx, cancel := context.WithCancel(context.WithValue(ctx, key{}, "value"))
+		Expect(logged.FTSearchHash(cctx, "hcustomers", "*", nil).Err()).NotTo(HaveOccurred())
+		cancel()
+		slowLog.Wait()
+
+		Expect(recordCtx).NotTo(BeNil())
+		Expect(recordCtx.Err()).NotTo(HaveOccurred())
+		Expect(recordCtx.Value(key{})).To(Equal("value"))
+	})
+
+	It("records the error if profiling times out", func() {
+		withSlowLog(grsearch.SlowLogOptions{Profile: true, ProfileTimeout: time.Nanosecond})
+
+		Expect(logged.FTSearchHash(ctx, "hcustomers", "*", nil).Err()).NotTo(HaveOccurred())
+		slowLog.Wait()
+
+		queries := ring.Queries()
+		Expect(queries).To(HaveLen(1))
+		Expect(queries[0].Profile).To(BeNil())
+		Expect(queries[0].ProfileError).To(ContainSubstring("deadline"))
+	})
+
+	It("does not profile aggregates using a cursor", func() {
+		withSlowLog(grsearch.SlowLogOptions{Profile: true})
+
+		cmd := logged.FTAggregate(ctx, "hcustomers", "*", grsearch.NewAggregateBuilder().
+			Load("customer", "").
+			Cursor(5, 0).
+			Options())
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		DeferCleanup(func() {
+			Expect(client.FTCursorDel(ctx, "hcustomers", cmd.CursorId()).Err()).NotTo(HaveOccurred())
+		})
+		slowLog.Wait()
+
+		queries := ring.Queries()
+		Expect(queries).To(HaveLen(1))
+		Expect(queries[0].Command).To(Equal("FT.AGGREGATE"))
+		Expect(queries[0].Profile).To(BeNil())
+		Expect(queries[0].ProfileError).To(BeEmpty())
+	})
+
+	It("samples queries under the threshold and keeps the most recent", func() {
+		withSlowLog(grsearch.SlowLogOptions{Threshold: time.Hour, SampleRate: 1, Profile: true})
+
+		for _, query := range []string{"a*", "b*", "c*"} {
+			Expect(logged.FTAggregate(ctx, "hcustomers", query, grsearch.NewAggregateOptions()).Err()).NotTo(HaveOccurred())
+		}
+
+		queries := ring.Queries()
+		Expect(queries).To(HaveLen(2))
+		Expect(queries[0].Query).To(Equal("b*"))
+		Expect(queries[1].Query).To(Equal("c*"))
+		Expect(queries[1].Sampled).To(BeTrue())
+		Expect(queries[1].Profile).To(BeNil())
+	})
+
+	It("ignores queries under the threshold without sampling", func() {
+		withSlowLog(grsearch.SlowLogOptions{Threshold: time.Hour})
+		Expect(logged.FTSearchHash(ctx, "hcustomers", "*", nil).Err()).NotTo(HaveOccurred())
+		Expect(ring.Queries()).To(BeEmpty())
+	})
+
+	It("writes queries to a file", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "slow.log")
+		file, err := grsearch.NewSlowLogFile(path, 1)
+		Expect(err).NotTo(HaveOccurred())
+		withSlowLog(grsearch.SlowLogOptions{Sink: file})
+
+		Expect(logged.FTSearchHash(ctx, "hcustomers", "*", nil).Err()).NotTo(HaveOccurred())
+		Expect(logged.FTSearchHash(ctx, "hcustomers", "@owner:{lara\\.croft}", nil).Err()).NotTo(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+		Expect(file.Err()).NotTo(HaveOccurred())
+
+		for name, query := range map[string]string{path + ".1": "*", path: "@owner:{lara\\.croft}"} {
+			f, err := os.Open(name)
+			Expect(err).NotTo(HaveOccurred())
+			scanner := bufio.NewScanner(f)
+			Expect(scanner.Scan()).To(BeTrue())
+			recorded := grsearch.SlowQuery{}
+			Expect(json.Unmarshal(scanner.Bytes(), &recorded)).To(Succeed())
+			Expect(recorded.Query).To(Equal(query))
+			Expect(scanner.Scan()).To(BeFalse())
+			Expect(f.Close()).To(Succeed())
+		}
+	})
+})
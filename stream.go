@@ -0,0 +1,170 @@
+package grsearch
+
+// streaming - ForEach and Stream let callers process search and aggregate results one at a time
+// without holding the full result set. Searches are paged using SearchIterator and aggregates are
+// read from a cursor (FT.CURSOR READ) until it is exhausted.
+
+import (
+	"context"
+)
+
+// ForEach calls fn for every result of the search, fetching further pages as required. Iteration
+// stops at the first error returned by fn, which is returned.
+func (cmd *QueryCmd) ForEach(ctx context.Context, fn func(*SearchResult) error) error {
+	it := cmd.Iterator(ctx)
+	for it.Next(ctx) {
+		if err := fn(it.Val()); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+// Stream returns a channel delivering every result of the search and a channel which
+// receives an error if the search fails or the context is cancelled. Both are closed
+// when the search is complete.
+func (cmd *QueryCmd) Stream(ctx context.Context) (<-chan *SearchResult, <-chan error) {
+	results := make(chan *SearchResult)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(results)
+		err := cmd.ForEach(ctx, func(r *SearchResult) error {
+			select {
+			case results <- r:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			errs <- err
+		}
+	}()
+
+	return results, errs
+}
+
+// ForEach calls fn for every row of the aggregate, reading from the cursor if one was
+// requested. Iteration stops at the first error returned by fn or by a cursor read, which
+// is returned, and the cursor is deleted.
+func (cmd *AggregateCmd) ForEach(ctx context.Context, fn func(map[string]interface{}) error) error {
+	rows := newCursorRows(cmd)
+	for rows.next(ctx) {
+		if err := fn(rows.row()); err != nil {
+			rows.close()
+			return err
+		}
+	}
+	return rows.err
+}
+
+// Stream returns a channel delivering every row of the aggregate and a channel which
+// receives an error if the aggregate fails or the context is cancelled. Both are closed
+// when the aggregate is complete.
+func (cmd *AggregateCmd) Stream(ctx context.Context) (<-chan map[string]interface{}, <-chan error) {
+	results := make(chan map[string]interface{})
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(results)
+		err := cmd.ForEach(ctx, func(r map[string]interface{}) error {
+			select {
+			case results <- r:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			errs <- err
+		}
+	}()
+
+	return results, errs
+}
+
+// cursorRows reads the rows of an aggregate one at a time, following its cursor. The cursor
+// is deleted if a read fails; close must be called to delete it if reading stops early.
+type cursorRows struct {
+	cmd *AggregateCmd
+	pos int
+	err error
+}
+
+func newCursorRows(cmd *AggregateCmd) *cursorRows {
+	return &cursorRows{cmd: cmd, err: cmd.Err()}
+}
+
+// next moves to the next row, reading from the cursor when the current page is exhausted.
+func (r *cursorRows) next(ctx context.Context) bool {
+	if r.err != nil {
+		return false
+	}
+	for {
+		if r.pos < len(r.cmd.Val()) {
+			r.pos++
+			return true
+		}
+		if r.cmd.CursorId() == 0 || r.cmd.process == nil {
+			return false
+		}
+		next := r.cmd.process.FTCursorRead(ctx, r.cmd.index, r.cmd.CursorId(), 0)
+		if next.Err() != nil {
+			r.close()
+			r.err = next.Err()
+			return false
+		}
+		r.cmd, r.pos = next, 0
+	}
+}
+
+// row returns the current row.
+func (r *cursorRows) row() map[string]interface{} {
+	return r.cmd.Val()[r.pos-1]
+}
+
+// close deletes the cursor if there are still rows to be read.
+func (r *cursorRows) close() {
+	r.cmd.closeCursor()
+}
+
+// closeCursor deletes the cursor if there are still results to be read. The context used
+// to read the results may have been cancelled so we don't use it here.
+func (cmd *AggregateCmd) closeCursor() {
+	if cmd.CursorId() != 0 && cmd.process != nil {
+		cmd.process.FTCursorDel(context.Background(), cmd.index, cmd.CursorId())
+		cmd.SetCursorId(0)
+	}
+}
+
+// StreamSearchHash runs a search on hashes and streams the results. See [QueryCmd.Stream].
+func (c cmdable) StreamSearchHash(ctx context.Context, index, query string, qryOptions *QueryOptions) (<-chan *SearchResult, <-chan error) {
+	return c.FTSearchHash(ctx, index, query, qryOptions).Stream(ctx)
+}
+
+// StreamSearchJSON runs a search on JSON documents and streams the results. See [QueryCmd.Stream].
+func (c cmdable) StreamSearchJSON(ctx context.Context, index, query string, qryOptions *QueryOptions) (<-chan *SearchResult, <-chan error) {
+	return c.FTSearchJSON(ctx, index, query, qryOptions).Stream(ctx)
+}
+
+// StreamAggregate runs an aggregate with a cursor (adding a default one if none is set)
+// and streams the results. See [AggregateCmd.Stream].
+func (c cmdable) StreamAggregate(ctx context.Context, index, query string, options *AggregateOptions) (<-chan map[string]interface{}, <-chan error) {
+	return c.FTAggregate(ctx, index, query, withCursor(options)).Stream(ctx)
+}
+
+// withCursor returns a copy of the options with a cursor set if there isn't one already.
+func withCursor(options *AggregateOptions) *AggregateOptions {
+	if options == nil {
+		options = NewAggregateOptions()
+	}
+	if options.Cursor != nil {
+		return options
+	}
+	withCursor := *options
+	withCursor.Cursor = &AggregateCursor{}
+	return &withCursor
+}
@@ -0,0 +1,137 @@
+package grsearch_test
+
+import (
+	"context"
+	"errors"
+
+	grsearch "github.com/goslogan/grsearch"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Streaming search results", Label("search", "hash", "ft.search", "stream"), func() {
+
+	It("can call a function for every result", func() {
+		options := grsearch.NewQueryBuilder().
+			NoContent().
+			Limit(0, 3).
+			Options()
+		cmd := client.FTSearchHash(ctx, "hcustomers", `@owner:{lara\.croft}`, options)
+		keys := []string{}
+		Expect(cmd.ForEach(ctx, func(r *grsearch.SearchResult) error {
+			keys = append(keys, r.Key)
+			return nil
+		})).NotTo(HaveOccurred())
+		Expect(keys).To(HaveLen(10))
+	})
+
+	It("stops when the function returns an error", func() {
+		options := grsearch.NewQueryBuilder().
+			NoContent().
+			Limit(0, 3).
+			Options()
+		cmd := client.FTSearchHash(ctx, "hcustomers", `@owner:{lara\.croft}`, options)
+		stop := errors.New("stop")
+		count := 0
+		Expect(cmd.ForEach(ctx, func(r *grsearch.SearchResult) error {
+			count++
+			if count == 4 {
+				return stop
+			}
+			return nil
+		})).To(MatchError(stop))
+		Expect(count).To(Equal(4))
+	})
+
+	It("can stream results over a channel", func() {
+		options := grsearch.NewQueryBuilder().
+			Limit(0, 4).
+			Options()
+		results, errs := client.StreamSearchJSON(ctx, "jcustomers", `@owner:{sarah\.oconnor}`, options)
+		keys := []string{}
+		for r := range results {
+			keys = append(keys, r.Key)
+		}
+		Expect(<-errs).NotTo(HaveOccurred())
+		Expect(keys).To(HaveLen(14))
+	})
+
+	It("reports search errors on the error channel", func() {
+		results, errs := client.StreamSearchHash(ctx, "nosuchindex", `*`, nil)
+		Expect(results).To(BeClosed())
+		Expect(<-errs).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Streaming aggregate results", Label("ft.aggregate", "stream", "cursor"), func() {
+
+	It("can read results from a cursor", func() {
+		opts := grsearch.NewAggregateBuilder().
+			Load("customer", "").
+			Cursor(10, 0).
+			Options()
+		cmd := client.FTAggregate(ctx, "hcustomers", `*`, opts)
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		Expect(cmd.Val()).To(HaveLen(10))
+		Expect(cmd.CursorId()).NotTo(BeZero())
+		next := client.FTCursorRead(ctx, "hcustomers", cmd.CursorId(), 0)
+		Expect(next.Err()).NotTo(HaveOccurred())
+		Expect(next.Val()).To(HaveLen(10))
+		Expect(client.FTCursorDel(ctx, "hcustomers", next.CursorId()).Err()).NotTo(HaveOccurred())
+	})
+
+	It("can call a function for every row", func() {
+		opts := grsearch.NewAggregateBuilder().
+			Load("customer", "").
+			Cursor(7, 0).
+			Options()
+		rows := 0
+		Expect(client.FTAggregate(ctx, "hcustomers", `*`, opts).ForEach(ctx, func(row map[string]interface{}) error {
+			Expect(row).To(HaveKey("customer"))
+			rows++
+			return nil
+		})).NotTo(HaveOccurred())
+		Expect(rows).To(Equal(25))
+	})
+
+	It("deletes the cursor if a read fails", func() {
+		before := client.FTInfo(ctx, "hcustomers")
+		Expect(before.Err()).NotTo(HaveOccurred())
+
+		opts := grsearch.NewAggregateBuilder().
+			Load("customer", "").
+			Cursor(7, 0).
+			Options()
+		cctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		rows := 0
+		Expect(client.FTAggregate(cctx, "hcustomers", `*`, opts).ForEach(cctx, func(row map[string]interface{}) error {
+			rows++
+			if rows == 7 {
+				cancel()
+			}
+			return nil
+		})).To(MatchError(context.Canceled))
+		Expect(rows).To(Equal(7))
+
+		after := client.FTInfo(ctx, "hcustomers")
+		Expect(after.Err()).NotTo(HaveOccurred())
+		Expect(after.Val().CursorStats.IndexTotal).To(Equal(before.Val().CursorStats.IndexTotal))
+	})
+
+	It("can stream rows over a channel", func() {
+		opts := grsearch.NewAggregateBuilder().
+			GroupBy(grsearch.NewGroupByBuilder().
+				Property("@country").
+				Reduce(grsearch.ReduceCount("count")).
+				GroupBy()).
+			Options()
+		results, errs := client.StreamAggregate(ctx, "hcustomers", `*`, opts)
+		rows := 0
+		for range results {
+			rows++
+		}
+		Expect(<-errs).NotTo(HaveOccurred())
+		Expect(rows).To(Equal(8))
+	})
+})
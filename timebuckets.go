@@ -0,0 +1,237 @@
+package grsearch
+
+// time buckets - events are grouped by applying an expression which rounds their timestamp
+// down to the start of its bucket, grouping by the result and sorting by it. Fixed size
+// buckets use floor(@ts / size) * size; calendar buckets use the date functions, which work
+// in UTC. The server only returns buckets with events in them, so TimeBucket.Rows adds the
+// missing ones when asked to fill the gaps.
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/goslogan/grsearch/expr"
+	"github.com/goslogan/grsearch/internal"
+)
+
+// BucketUnit is a calendar unit for [TimeBucket].
+type BucketUnit string
+
+const (
+	BucketMinute BucketUnit = "minute"
+	BucketHour   BucketUnit = "hour"
+	BucketDay    BucketUnit = "day"
+	BucketWeek   BucketUnit = "week" // weeks start on Sunday
+	BucketMonth  BucketUnit = "month"
+)
+
+// DefaultBucketProperty is the name of the property holding the start of each bucket if
+// TimeBucket.As is not set.
+const DefaultBucketProperty = "bucket"
+
+// TimeBucket groups the results of an aggregate into time buckets with
+// [AggregateBuilder.TimeBuckets]. Exactly one of Size and Unit must be set.
+type TimeBucket struct {
+	Field    string        // NUMERIC property holding a unix timestamp in seconds
+	Size     time.Duration // Fixed size of the buckets, a whole number of seconds
+	Unit     BucketUnit    // Calendar unit for the buckets, in UTC
+	As       string        // Name of the bucket property, DefaultBucketProperty if empty
+	Reducers []AggregateReducer
+	From, To time.Time // Only include events from From up to but not including To if set
+	Limit    int64     // Maximum number of buckets; all buckets from From to To if zero and both are set, otherwise required
+	Fill     bool      // Add empty buckets for the periods without events to the rows
+}
+
+// TimeBucketRow is a bucket read with [TimeBucket.Rows].
+type TimeBucketRow struct {
+	Time   time.Time              // The start of the bucket
+	Values map[string]interface{} // Values of the reducers; empty for buckets added by Fill
+	Empty  bool                   // Set for buckets added by Fill
+}
+
+// TimeBuckets adds the steps to group results into time buckets: a FILTER for the time range
+// if it is set, an APPLY computing the start of each result's bucket, a GROUPBY with the
+// reducers and a SORTBY putting the buckets in time order. The SORTBY always has a MAX, as the
+// server would return only 10 buckets without one, so Limit must be set unless both From and
+// To are. If the bucket definition or one of its reducers is invalid the aggregate fails
+// without being sent.
+func (a *AggregateBuilder) TimeBuckets(bucket *TimeBucket) *AggregateBuilder {
+	start, err := bucket.expression()
+	if err != nil {
+		a.setErr(err)
+		return a
+	}
+
+	limit := bucket.Limit
+	if limit == 0 && !bucket.From.IsZero() && !bucket.To.IsZero() {
+		limit = int64(len(bucket.starts(bucket.From, bucket.To)))
+	}
+	if limit <= 0 {
+		a.setErr(fmt.Errorf("grsearch: time buckets need a limit unless both From and To are set"))
+		return a
+	}
+
+	field := expr.Property(bucket.Field)
+	switch {
+	case !bucket.From.IsZero() && !bucket.To.IsZero():
+		a.FilterExpr(field.Ge(expr.Int(bucket.From.Unix())).And(field.Lt(expr.Int(bucket.To.Unix()))))
+	case !bucket.From.IsZero():
+		a.FilterExpr(field.Ge(expr.Int(bucket.From.Unix())))
+	case !bucket.To.IsZero():
+		a.FilterExpr(field.Lt(expr.Int(bucket.To.Unix())))
+	}
+
+	group := NewGroupByBuilder().Property("@" + bucket.as())
+	for _, r := range bucket.Reducers {
+		group.Reduce(r)
+	}
+
+	return a.ApplyExpr(start, bucket.as()).
+		GroupBy(group.GroupBy()).
+		SortByMax([]AggregateSortKey{{Name: bucket.as(), Order: SortAsc}}, limit)
+}
+
+// Rows reads the buckets from an aggregate built with [AggregateBuilder.TimeBuckets], in time
+// order. If Fill is set, empty buckets are added for the periods without events between From
+// and To, or between the first and last buckets returned if they are not set.
+func (b *TimeBucket) Rows(cmd *AggregateCmd) ([]TimeBucketRow, error) {
+	if cmd.Err() != nil {
+		return nil, cmd.Err()
+	}
+
+	rows := make([]TimeBucketRow, 0, len(cmd.Val()))
+	for _, values := range cmd.Val() {
+		start, err := internal.Float64(values[b.as()])
+		if err != nil {
+			return nil, fmt.Errorf("grsearch: unable to read time bucket: %w", err)
+		}
+		row := TimeBucketRow{Time: time.Unix(int64(start), 0).UTC(), Values: map[string]interface{}{}}
+		for k, v := range values {
+			if k != b.as() {
+				row.Values[k] = v
+			}
+		}
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Time.Before(rows[j].Time) })
+
+	if !b.Fill {
+		return rows, nil
+	}
+
+	from, to := b.From, b.To
+	if from.IsZero() && len(rows) > 0 {
+		from = rows[0].Time
+	}
+	if to.IsZero() && len(rows) > 0 {
+		to = rows[len(rows)-1].Time.Add(time.Second)
+	}
+	if from.IsZero() || to.IsZero() {
+		return rows, nil
+	}
+
+	filled := []TimeBucketRow{}
+	next := 0
+	for _, start := range b.starts(from, to) {
+		for next < len(rows) && rows[next].Time.Before(start) {
+			filled = append(filled, rows[next])
+			next++
+		}
+		if next < len(rows) && rows[next].Time.Equal(start) {
+			filled = append(filled, rows[next])
+			next++
+		} else {
+			filled = append(filled, TimeBucketRow{Time: start, Values: map[string]interface{}{}, Empty: true})
+		}
+	}
+	return append(filled, rows[next:]...), nil
+}
+
+func (b *TimeBucket) as() string {
+	if b.As == "" {
+		return DefaultBucketProperty
+	}
+	return b.As
+}
+
+// expression returns the expression computing the start of the bucket holding the timestamp.
+func (b *TimeBucket) expression() (expr.Expr, error) {
+	if b.Field == "" {
+		return expr.Expr{}, fmt.Errorf("grsearch: time buckets need a timestamp field")
+	}
+	ts := expr.Property(b.Field)
+
+	if b.Unit == "" {
+		if b.Size < time.Second || b.Size%time.Second != 0 {
+			return expr.Expr{}, fmt.Errorf("grsearch: time bucket size must be a whole number of seconds, got %s", b.Size)
+		}
+		seconds := expr.Int(int64(b.Size / time.Second))
+		return expr.Floor(ts.Div(seconds)).Mul(seconds), nil
+	}
+	if b.Size != 0 {
+		return expr.Expr{}, fmt.Errorf("grsearch: time buckets can't have both a size and a unit")
+	}
+
+	switch b.Unit {
+	case BucketMinute:
+		return expr.Minute(ts), nil
+	case BucketHour:
+		return expr.Hour(ts), nil
+	case BucketDay:
+		return expr.Day(ts), nil
+	case BucketWeek:
+		return expr.Day(ts).Sub(expr.DayOfWeek(ts).Mul(expr.Int(86400))), nil
+	case BucketMonth:
+		return expr.Month(ts), nil
+	}
+	return expr.Expr{}, fmt.Errorf("grsearch: unknown time bucket unit %q", b.Unit)
+}
+
+// truncate returns the start of the bucket holding t.
+func (b *TimeBucket) truncate(t time.Time) time.Time {
+	t = t.UTC()
+	switch b.Unit {
+	case BucketMinute:
+		return t.Truncate(time.Minute)
+	case BucketHour:
+		return t.Truncate(time.Hour)
+	case BucketDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case BucketWeek:
+		return time.Date(t.Year(), t.Month(), t.Day()-int(t.Weekday()), 0, 0, 0, 0, time.UTC)
+	case BucketMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	seconds := int64(b.Size / time.Second)
+	return time.Unix(t.Unix()-((t.Unix()%seconds)+seconds)%seconds, 0).UTC()
+}
+
+// next returns the start of the bucket after the one starting at t.
+func (b *TimeBucket) next(t time.Time) time.Time {
+	switch b.Unit {
+	case BucketMinute:
+		return t.Add(time.Minute)
+	case BucketHour:
+		return t.Add(time.Hour)
+	case BucketDay:
+		return t.AddDate(0, 0, 1)
+	case BucketWeek:
+		return t.AddDate(0, 0, 7)
+	case BucketMonth:
+		return t.AddDate(0, 1, 0)
+	}
+	return t.Add(b.Size)
+}
+
+// starts returns the start of every bucket holding times from from up to but not including to.
+func (b *TimeBucket) starts(from, to time.Time) []time.Time {
+	if _, err := b.expression(); err != nil {
+		return nil
+	}
+	starts := []time.Time{}
+	for t := b.truncate(from); t.Before(to); t = b.next(t) {
+		starts = append(starts, t)
+	}
+	return starts
+}
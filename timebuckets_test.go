@@ -0,0 +1,131 @@
+package grsearch_test
+
+import (
+	"fmt"
+	"time"
+
+	grsearch "github.com/goslogan/grsearch"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Time buckets", Ordered, Label("timebuckets", "ft.aggregate"), func() {
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) // a Monday
+
+	BeforeAll(func() {
+		Expect(client.FTCreate(ctx, "hevents", grsearch.NewIndexBuilder().
+			Prefix("hevent:").
+			Schema(&grsearch.NumericAttribute{Name: "ts", Sortable: true}).
+			Schema(&grsearch.NumericAttribute{Name: "value"}).
+			Options()).Err()).NotTo(HaveOccurred())
+		DeferCleanup(func() {
+			client.FTDropIndex(ctx, "hevents", true)
+		})
+
+		// three events on the 1st, none on the 2nd, two on the 3rd and one on the 10th
+		events := []time.Time{
+			start.Add(time.Hour), start.Add(2 * time.Hour), start.Add(2*time.Hour + 30*time.Minute),
+			start.AddDate(0, 0, 2).Add(time.Hour), start.AddDate(0, 0, 2).Add(23 * time.Hour),
+			start.AddDate(0, 0, 9),
+		}
+		for n, ts := range events {
+			Expect(client.HSet(ctx, fmt.Sprintf("hevent:%d", n), "ts", ts.Unix(), "value", n).Err()).NotTo(HaveOccurred())
+		}
+	})
+
+	It("groups events into fixed size buckets", func() {
+		buckets := &grsearch.TimeBucket{
+			Field:    "ts",
+			Size:     time.Hour,
+			Reducers: []grsearch.AggregateReducer{grsearch.ReduceCount("events")},
+			From:     start,
+			To:       start.AddDate(0, 0, 1),
+		}
+		cmd := client.FTAggregate(ctx, "hevents", "*", grsearch.NewAggregateBuilder().TimeBuckets(buckets).Options())
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		Expect(cmd.Args()[3:]).To(Equal([]interface{}{
+			"filter", fmt.Sprintf("(@ts >= %d) && (@ts < %d)", start.Unix(), start.AddDate(0, 0, 1).Unix()),
+			"apply", "floor(@ts / 3600) * 3600", "as", "bucket",
+			"GROUPBY", 1, "@bucket", "reduce", "count", 0, "as", "events",
+			"SORTBY", 2, "@bucket", "ASC", "MAX", int64(24),
+		}))
+
+		rows, err := buckets.Rows(cmd)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rows).To(HaveLen(2))
+		Expect(rows[0].Time).To(Equal(start.Add(time.Hour)))
+		Expect(fmt.Sprint(rows[0].Values["events"])).To(Equal("1"))
+		Expect(rows[1].Time).To(Equal(start.Add(2 * time.Hour)))
+		Expect(fmt.Sprint(rows[1].Values["events"])).To(Equal("2"))
+	})
+
+	It("groups events by calendar day and fills the gaps", func() {
+		buckets := &grsearch.TimeBucket{
+			Field:    "ts",
+			Unit:     grsearch.BucketDay,
+			Reducers: []grsearch.AggregateReducer{grsearch.ReduceCount("events"), grsearch.ReduceSum("value", "total")},
+			From:     start,
+			To:       start.AddDate(0, 0, 4),
+			Fill:     true,
+		}
+		rows, err := buckets.Rows(client.FTAggregate(ctx, "hevents", "*", grsearch.NewAggregateBuilder().TimeBuckets(buckets).Options()))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rows).To(HaveLen(4))
+		for n, row := range rows {
+			Expect(row.Time).To(Equal(start.AddDate(0, 0, n)))
+		}
+		Expect(fmt.Sprint(rows[0].Values["events"])).To(Equal("3"))
+		Expect(rows[1].Empty).To(BeTrue())
+		Expect(fmt.Sprint(rows[2].Values["events"])).To(Equal("2"))
+		Expect(fmt.Sprint(rows[2].Values["total"])).To(Equal("7"))
+		Expect(rows[3].Empty).To(BeTrue())
+	})
+
+	It("groups events by week", func() {
+		buckets := &grsearch.TimeBucket{
+			Field:    "ts",
+			Unit:     grsearch.BucketWeek,
+			Reducers: []grsearch.AggregateReducer{grsearch.ReduceCount("events")},
+			Limit:    10,
+			Fill:     true,
+		}
+		rows, err := buckets.Rows(client.FTAggregate(ctx, "hevents", "*", grsearch.NewAggregateBuilder().TimeBuckets(buckets).Options()))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rows).To(HaveLen(2))
+		Expect(rows[0].Time).To(Equal(start.AddDate(0, 0, -1)))
+		Expect(fmt.Sprint(rows[0].Values["events"])).To(Equal("5"))
+		Expect(rows[1].Time).To(Equal(start.AddDate(0, 0, 6)))
+		Expect(fmt.Sprint(rows[1].Values["events"])).To(Equal("1"))
+	})
+
+	It("rejects invalid buckets without sending the aggregate", func() {
+		cmd := client.FTAggregate(ctx, "hevents", "*", grsearch.NewAggregateBuilder().
+			TimeBuckets(&grsearch.TimeBucket{Field: "ts", Size: 1500 * time.Millisecond}).
+			Options())
+		Expect(cmd.Err()).To(MatchError("grsearch: time bucket size must be a whole number of seconds, got 1.5s"))
+
+		cmd = client.FTAggregate(ctx, "hevents", "*", grsearch.NewAggregateBuilder().
+			TimeBuckets(&grsearch.TimeBucket{Field: "ts", Unit: "fortnight"}).
+			Options())
+		Expect(cmd.Err()).To(MatchError(`grsearch: unknown time bucket unit "fortnight"`))
+
+		cmd = client.FTAggregate(ctx, "hevents", "*", grsearch.NewAggregateBuilder().
+			TimeBuckets(&grsearch.TimeBucket{Field: "ts", Unit: grsearch.BucketDay, From: start}).
+			Options())
+		Expect(cmd.Err()).To(MatchError("grsearch: time buckets need a limit unless both From and To are set"))
+	})
+
+	It("sends the limit as the maximum number of buckets", func() {
+		cmd := offline.FTAggregate(ctx, "hevents", "*", grsearch.NewAggregateBuilder().
+			TimeBuckets(&grsearch.TimeBucket{Field: "ts", Unit: grsearch.BucketDay, From: start, Limit: 30}).
+			Options())
+		Expect(cmd.Err()).To(MatchError(errOffline))
+		Expect(cmd.Args()[3:]).To(Equal([]interface{}{
+			"filter", fmt.Sprintf("@ts >= %d", start.Unix()),
+			"apply", "day(@ts)", "as", "bucket",
+			"GROUPBY", 1, "@bucket",
+			"SORTBY", 2, "@bucket", "ASC", "MAX", int64(30),
+		}))
+	})
+})
@@ -0,0 +1,137 @@
+package grsearch_test
+
+import (
+	"context"
+
+	grsearch "github.com/goslogan/grsearch"
+	"github.com/goslogan/grsearch/otelsearch"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var _ = Describe("OpenTelemetry hook", Label("tracing"), func() {
+	var traced *grsearch.Client
+	var spans *tracetest.InMemoryExporter
+	var reader *sdkmetric.ManualReader
+
+	// attributes returns the attributes of a recorded span as a map.
+	attributes := func(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+		values := map[attribute.Key]attribute.Value{}
+		for _, a := range span.Attributes {
+			values[a.Key] = a.Value
+		}
+		return values
+	}
+
+	BeforeEach(func() {
+		spans = tracetest.NewInMemoryExporter()
+		reader = sdkmetric.NewManualReader()
+		hook, err := otelsearch.NewHook(&otelsearch.Options{
+			TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans)),
+			MeterProvider:  sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+			Query:          otelsearch.Redact,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		traced = grsearch.NewClient(&redis.Options{})
+		traced.AddHook(hook)
+	})
+
+	AfterEach(func() {
+		Expect(traced.Close()).To(Succeed())
+	})
+
+	It("traces searches with their results", func() {
+		cmd := traced.FTSearchHash(ctx, "hcustomers", "@owner:{lara\\.croft}", grsearch.NewQueryBuilder().
+			Limit(0, 5).
+			Options())
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+
+		Expect(spans.GetSpans()).To(HaveLen(1))
+		span := spans.GetSpans()[0]
+		Expect(span.Name).To(Equal("FT.SEARCH"))
+
+		values := attributes(span)
+		Expect(values[otelsearch.IndexKey].AsString()).To(Equal("hcustomers"))
+		Expect(values[otelsearch.QueryKey].AsString()).To(Equal("@owner:{?}"))
+		Expect(values[otelsearch.DialectKey].AsInt64()).To(BeEquivalentTo(2))
+		Expect(values[otelsearch.LimitKey].AsInt64()).To(BeEquivalentTo(5))
+		Expect(values[otelsearch.ResultsKey].AsInt64()).To(BeEquivalentTo(len(cmd.Val())))
+		Expect(values[otelsearch.TotalResultsKey].AsInt64()).To(Equal(cmd.TotalResults()))
+	})
+
+	It("records errors and latency by index", func() {
+		Expect(traced.FTInfo(ctx, "nosuchindex").Err()).To(HaveOccurred())
+		Expect(traced.FTInfo(ctx, "hcustomers").Err()).NotTo(HaveOccurred())
+
+		Expect(spans.GetSpans()).To(HaveLen(2))
+		Expect(spans.GetSpans()[0].Events).To(HaveLen(1))
+
+		data := metricdata.ResourceMetrics{}
+		Expect(reader.Collect(ctx, &data)).To(Succeed())
+		Expect(data.ScopeMetrics).To(HaveLen(1))
+		histogram := data.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[float64])
+		indexes := []string{}
+		for _, point := range histogram.DataPoints {
+			index, _ := point.Attributes.Value(otelsearch.IndexKey)
+			indexes = append(indexes, index.AsString())
+		}
+		Expect(indexes).To(ConsistOf("nosuchindex", "hcustomers"))
+	})
+
+	It("traces and parses commands in pipelines", func() {
+		pipe := traced.Pipeline()
+		cmd := grsearch.NewAggregateCmd(ctx, "FT.AGGREGATE", "hcustomers", "*", "GROUPBY", 1, "@owner")
+		Expect(pipe.Process(ctx, cmd)).To(Succeed())
+		pipe.Get(ctx, "nosuchkey")
+		_, _ = pipe.Exec(ctx)
+
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		Expect(cmd.Val()).To(HaveLen(3))
+
+		Expect(spans.GetSpans()).To(HaveLen(1))
+		values := attributes(spans.GetSpans()[0])
+		Expect(values[otelsearch.PipelineKey].AsBool()).To(BeTrue())
+		Expect(values[otelsearch.ResultsKey].AsInt64()).To(BeEquivalentTo(3))
+	})
+})
+
+// replacingHook replaces the results of every search with a single result once it has run.
+type replacingHook struct{}
+
+func (replacingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (replacingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		if search, ok := cmd.(*grsearch.QueryCmd); ok && err == nil {
+			search.SetVal([]*grsearch.SearchResult{{Key: "replaced"}})
+		}
+		return err
+	}
+}
+
+func (replacingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+var _ = Describe("Client hooks", func() {
+
+	It("see parsed results which are not parsed again afterwards", func() {
+		hooked := grsearch.NewClient(&redis.Options{})
+		DeferCleanup(hooked.Close)
+		hooked.AddHook(replacingHook{})
+
+		cmd := hooked.FTSearchHash(ctx, "hcustomers", "@owner:{lara\\.croft}", nil)
+		Expect(cmd.Err()).NotTo(HaveOccurred())
+		Expect(cmd.Keys()).To(Equal([]string{"replaced"}))
+	})
+})
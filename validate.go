@@ -0,0 +1,292 @@
+package grsearch
+
+// query validation - the query is scanned (not fully parsed) for attribute references and
+// parameters. An attribute reference is @name (or @a|b for several) followed by a colon and
+// then {tags}, [a range] or text; an @name with no colon appears in a KNN clause and must
+// be a vector. $name is a parameter unless it is followed by a colon, in which case it is a
+// query attribute such as $weight. Quoted strings and escaped characters are skipped.
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// QueryValidationError lists the problems found by [ValidateQuery].
+type QueryValidationError struct {
+	Problems []string
+}
+
+func (e *QueryValidationError) Error() string {
+	return "grsearch: invalid query: " + strings.Join(e.Problems, "; ")
+}
+
+// schemaAttribute is an attribute as seen from a query.
+type schemaAttribute struct {
+	attribType string
+	sortable   bool
+}
+
+// ValidateQuery checks a query and its options against the schema of an index, as returned
+// by [Client.FTInfo] in [Info.Index]. It checks that the attributes referenced exist, that
+// tag, range and text syntax is only used with attributes of the right type, that SortBy is
+// sortable, that Return, InFields, Summarize, HighLight, Filters and GeoFilters refer to
+// attributes in the schema and that every $parameter is given in Params. A
+// [QueryValidationError] listing all the problems is returned if any are found.
+func ValidateQuery(query string, opts *QueryOptions, schema *IndexOptions) error {
+	attributes := map[string]schemaAttribute{}
+	for _, a := range schema.Schema {
+		name, alias, attribType := attributeIdentity(a)
+		if alias != "" {
+			name = alias
+		}
+		attributes[name] = schemaAttribute{attribType: attribType, sortable: attributeSortable(a)}
+	}
+
+	v := &queryValidator{attributes: attributes}
+	refs, params := scanQuery(query)
+	for _, ref := range refs {
+		v.checkReference(ref)
+	}
+
+	if opts == nil {
+		opts = NewQueryOptions()
+	}
+
+	for _, param := range params {
+		if _, ok := opts.Params[param]; !ok {
+			v.problem("parameter $%s is not set in Params", param)
+		}
+	}
+
+	if opts.SortBy != "" {
+		if a, ok := v.lookup(opts.SortBy, "SortBy"); ok && !a.sortable {
+			v.problem("SortBy attribute %s is not sortable", opts.SortBy)
+		}
+	}
+	for _, r := range opts.Return {
+		// JSON paths can return anything in the document
+		if !strings.HasPrefix(r.Name, "$") {
+			v.lookup(r.Name, "Return")
+		}
+	}
+	for _, field := range opts.InFields {
+		v.lookupType(field, "InFields", "TEXT")
+	}
+	if opts.Summarize != nil {
+		for _, field := range opts.Summarize.Fields {
+			v.lookupType(field, "Summarize", "TEXT")
+		}
+	}
+	if opts.HighLight != nil {
+		for _, field := range opts.HighLight.Fields {
+			v.lookupType(field, "HighLight", "TEXT")
+		}
+	}
+	for _, filter := range opts.Filters {
+		v.lookupType(filter.Attribute, "Filters", "NUMERIC")
+	}
+	for _, filter := range opts.GeoFilters {
+		v.lookupType(filter.Attribute, "GeoFilters", "GEO")
+	}
+
+	if len(v.problems) != 0 {
+		return &QueryValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// ValidateQuery reads the schema of an index with FT.INFO and checks a query against it
+// with [ValidateQuery].
+func (c *Client) ValidateQuery(ctx context.Context, index, query string, opts *QueryOptions) error {
+	info, err := c.FTInfo(ctx, index).Result()
+	if err != nil {
+		return err
+	}
+	return ValidateQuery(query, opts, info.Index)
+}
+
+type queryValidator struct {
+	attributes map[string]schemaAttribute
+	problems   []string
+}
+
+func (v *queryValidator) problem(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+// lookup finds an attribute, recording a problem if it doesn't exist.
+func (v *queryValidator) lookup(name, where string) (schemaAttribute, bool) {
+	a, ok := v.attributes[strings.TrimPrefix(name, "@")]
+	if !ok {
+		v.problem("%s attribute %s is not in the schema", where, name)
+	}
+	return a, ok
+}
+
+// lookupType finds an attribute and checks its type.
+func (v *queryValidator) lookupType(name, where, attribType string) {
+	if a, ok := v.lookup(name, where); ok && a.attribType != attribType {
+		v.problem("%s attribute %s is %s, not %s", where, name, a.attribType, attribType)
+	}
+}
+
+func (v *queryValidator) checkReference(ref queryReference) {
+	for _, name := range ref.names {
+		a, ok := v.attributes[name]
+		if !ok {
+			v.problem("unknown attribute @%s", name)
+			continue
+		}
+		if !ref.accepts(a.attribType) {
+			v.problem("@%s is a %s attribute and can't be used with %s", name, a.attribType, ref.syntax)
+		}
+	}
+}
+
+// queryReference is a use of one or more attributes in a query.
+type queryReference struct {
+	names  []string
+	syntax string   // how the attributes are used, for error messages
+	types  []string // attribute types which can be used this way
+}
+
+func (r queryReference) accepts(attribType string) bool {
+	for _, t := range r.types {
+		if t == attribType {
+			return true
+		}
+	}
+	return false
+}
+
+// scanQuery returns the attribute references and parameters in a query.
+func scanQuery(query string) ([]queryReference, []string) {
+	refs := []queryReference{}
+	params := []string{}
+	seen := map[string]bool{}
+	runes := []rune(query)
+
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			i++
+		case '"':
+			i = skipQuoted(runes, i)
+		case '$':
+			name, end := scanName(runes, i+1)
+			next := skipSpace(runes, end)
+			if name != "" && (next >= len(runes) || runes[next] != ':') && !seen[name] {
+				seen[name] = true
+				params = append(params, name)
+			}
+			i = end - 1
+		case '@':
+			names := []string{}
+			end := i + 1
+			for {
+				var name string
+				name, end = scanName(runes, end)
+				if name != "" {
+					names = append(names, name)
+				}
+				if end >= len(runes) || runes[end] != '|' {
+					break
+				}
+				end++
+			}
+			if len(names) == 0 {
+				continue
+			}
+			refs = append(refs, classifyReference(names, runes, end))
+			i = end - 1
+		}
+	}
+
+	return refs, params
+}
+
+// classifyReference works out how attributes are used from what follows them.
+func classifyReference(names []string, runes []rune, pos int) queryReference {
+	ref := queryReference{names: names}
+	pos = skipSpace(runes, pos)
+	if pos >= len(runes) || runes[pos] != ':' {
+		ref.syntax, ref.types = "KNN", []string{"VECTOR"}
+		return ref
+	}
+
+	pos = skipSpace(runes, pos+1)
+	switch {
+	case pos < len(runes) && runes[pos] == '{':
+		ref.syntax, ref.types = "tag syntax {...}", []string{"TAG"}
+	case pos < len(runes) && runes[pos] == '[':
+		word, _ := scanName(runes, skipSpace(runes, pos+1))
+		switch strings.ToUpper(word) {
+		case "VECTOR_RANGE":
+			ref.syntax, ref.types = "VECTOR_RANGE", []string{"VECTOR"}
+		case "WITHIN", "CONTAINS", "INTERSECTS", "DISJOINT":
+			ref.syntax, ref.types = strings.ToUpper(word), []string{"GEOMETRY"}
+		default:
+			ref.syntax, ref.types = "range syntax [...]", []string{"NUMERIC", "GEO"}
+		}
+	default:
+		ref.syntax, ref.types = "text search", []string{"TEXT"}
+	}
+	return ref
+}
+
+// scanName reads an attribute or parameter name starting at pos, returning it and the
+// position after it. Escaped characters are included.
+func scanName(runes []rune, pos int) (string, int) {
+	var name strings.Builder
+	for pos < len(runes) {
+		r := runes[pos]
+		if r == '\\' && pos+1 < len(runes) {
+			name.WriteRune(runes[pos+1])
+			pos += 2
+			continue
+		}
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			break
+		}
+		name.WriteRune(r)
+		pos++
+	}
+	return name.String(), pos
+}
+
+// skipQuoted returns the position of the quote closing the string starting at pos.
+func skipQuoted(runes []rune, pos int) int {
+	for pos++; pos < len(runes); pos++ {
+		switch runes[pos] {
+		case '\\':
+			pos++
+		case '"':
+			return pos
+		}
+	}
+	return pos
+}
+
+func skipSpace(runes []rune, pos int) int {
+	for pos < len(runes) && unicode.IsSpace(runes[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// attributeSortable reports whether an attribute was created with SORTABLE.
+func attributeSortable(a SchemaAttribute) bool {
+	switch v := a.(type) {
+	case *TagAttribute:
+		return v.Sortable
+	case *TextAttribute:
+		return v.Sortable
+	case *NumericAttribute:
+		return v.Sortable
+	case *GeoAttribute:
+		return v.Sortable
+	}
+	return false
+}
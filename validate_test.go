@@ -0,0 +1,69 @@
+package grsearch_test
+
+import (
+	"errors"
+
+	grsearch "github.com/goslogan/grsearch"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Query validation", Label("validate"), func() {
+	schema := grsearch.NewIndexBuilder().
+		Schema(&grsearch.TagAttribute{Name: "account_id", Alias: "id", Sortable: true}).
+		Schema(&grsearch.TextAttribute{Name: "customer"}).
+		Schema(&grsearch.NumericAttribute{Name: "balance", Sortable: true}).
+		Schema(&grsearch.GeoAttribute{Name: "location"}).
+		Schema(&grsearch.VectorAttribute{Name: "embedding", Algorithm: "FLAT", Type: "FLOAT32", Dim: 2, DistanceMetric: "L2"}).
+		Options()
+
+	problems := func(err error) []string {
+		var invalid *grsearch.QueryValidationError
+		Expect(errors.As(err, &invalid)).To(BeTrue())
+		return invalid.Problems
+	}
+
+	It("accepts valid queries", func() {
+		options := grsearch.NewQueryOptions()
+		options.SortBy = "balance"
+		options.Params = map[string]interface{}{"min": 10, "blob": "xx"}
+		options.Return = []grsearch.QueryReturn{{Name: "customer"}, {Name: "$.anything"}}
+		Expect(grsearch.ValidateQuery(`@id:{1\@2} @customer:"@balance" @balance:[$min +inf] @location:[1 2 3 km]`, options, schema)).To(Succeed())
+		Expect(grsearch.ValidateQuery(`(@customer|customer:smith)=>{$weight: 2}=>[KNN 3 @embedding $blob]`, options, schema)).To(Succeed())
+	})
+
+	It("reports unknown attributes and missing parameters", func() {
+		err := grsearch.ValidateQuery("@acount_id:{1} @balance:[$min $max]", nil, schema)
+		Expect(problems(err)).To(ConsistOf(
+			"unknown attribute @acount_id",
+			"parameter $min is not set in Params",
+			"parameter $max is not set in Params",
+		))
+	})
+
+	It("reports syntax which doesn't match the attribute type", func() {
+		err := grsearch.ValidateQuery("@id:smith @customer:{smith} @balance:10 @customer:[1 2] =>[KNN 3 @balance $v]", grsearch.NewQueryBuilder().Params(map[string]interface{}{"v": ""}).Options(), schema)
+		Expect(problems(err)).To(HaveLen(5))
+		Expect(problems(err)[0]).To(Equal("@id is a TAG attribute and can't be used with text search"))
+	})
+
+	It("checks the attributes used in options", func() {
+		options := grsearch.NewQueryOptions()
+		options.SortBy = "customer"
+		options.InFields = []string{"balance"}
+		options.Return = []grsearch.QueryReturn{{Name: "nothere"}}
+		options.Summarize = &grsearch.QuerySummarize{Fields: []string{"customer"}}
+		options.GeoFilters = []grsearch.GeoFilter{{Attribute: "balance"}}
+		Expect(problems(grsearch.ValidateQuery("*", options, schema))).To(ConsistOf(
+			"SortBy attribute customer is not sortable",
+			"InFields attribute balance is NUMERIC, not TEXT",
+			"Return attribute nothere is not in the schema",
+			"GeoFilters attribute balance is NUMERIC, not GEO",
+		))
+	})
+
+	It("validates against an index on the server", Label("ft.info"), func() {
+		Expect(client.ValidateQuery(ctx, "hcustomers", "@owner:{lara\\.croft}", nil)).To(Succeed())
+		Expect(client.ValidateQuery(ctx, "hcustomers", "@owner:lara", nil)).To(HaveOccurred())
+	})
+})